@@ -3,6 +3,8 @@ package alertmanager
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -30,6 +32,22 @@ const (
 	AlertStateL3 AlertState = "l3"
 )
 
+var degradeLevels = map[AlertState]int{
+	AlertStateL0: 0,
+	AlertStateL1: 1,
+	AlertStateL2: 2,
+	AlertStateL3: 3,
+}
+
+// degradeStates 按级别排列的降级状态
+var degradeStates = []AlertState{AlertStateL0, AlertStateL1, AlertStateL2, AlertStateL3}
+
+// DegradeLevel 返回降级状态对应的数值级别，非降级状态返回 false
+func (s AlertState) DegradeLevel() (int, bool) {
+	level, ok := degradeLevels[s]
+	return level, ok
+}
+
 // AlertSnapshot 用于状态持久化
 type AlertSnapshot struct {
 	State          string                   `json:"state"`
@@ -37,6 +55,84 @@ type AlertSnapshot struct {
 	FiredAt        time.Time                `json:"firedAt"`
 	LastSentAt     time.Time                `json:"lastSentAt"`
 	StateEnteredAt map[AlertState]time.Time `json:"stateEnteredAt"`
+	ClearSince     time.Time                `json:"clearSince"`
+	LastSignalAt   time.Time                `json:"lastSignalAt"`
+}
+
+// DiffSnapshots 列出两个快照中取值不同的字段，便于排查状态持久化问题，相同时返回空
+func DiffSnapshots(a, b AlertSnapshot) []string {
+	var diffs []string
+	if a.State != b.State {
+		diffs = append(diffs, fmt.Sprintf("state: %q != %q", a.State, b.State))
+	}
+	diffTime := func(name string, x, y time.Time) {
+		if !x.Equal(y) {
+			diffs = append(diffs, fmt.Sprintf("%s: %s != %s", name, formatSnapshotTime(x), formatSnapshotTime(y)))
+		}
+	}
+	diffTime("activeAt", a.ActiveAt, b.ActiveAt)
+	diffTime("firedAt", a.FiredAt, b.FiredAt)
+	diffTime("lastSentAt", a.LastSentAt, b.LastSentAt)
+	diffTime("clearSince", a.ClearSince, b.ClearSince)
+	diffTime("lastSignalAt", a.LastSignalAt, b.LastSignalAt)
+
+	levels := make([]string, 0, len(a.StateEnteredAt)+len(b.StateEnteredAt))
+	for state := range a.StateEnteredAt {
+		levels = append(levels, string(state))
+	}
+	for state := range b.StateEnteredAt {
+		if _, ok := a.StateEnteredAt[state]; !ok {
+			levels = append(levels, string(state))
+		}
+	}
+	sort.Strings(levels)
+	for _, state := range levels {
+		diffTime("stateEnteredAt["+state+"]", a.StateEnteredAt[AlertState(state)], b.StateEnteredAt[AlertState(state)])
+	}
+	return diffs
+}
+
+// TimelineEntry 快照时间线中的一个事件
+type TimelineEntry struct {
+	Time        time.Time
+	Description string
+}
+
+// Timeline 按时间顺序列出快照中记录的状态变化，零值时间不列出，时间相同时按记录顺序排列
+func (s AlertSnapshot) Timeline() []TimelineEntry {
+	var entries []TimelineEntry
+	add := func(t time.Time, desc string) {
+		if !t.IsZero() {
+			entries = append(entries, TimelineEntry{Time: t, Description: desc})
+		}
+	}
+	add(s.ActiveAt, "became active")
+	add(s.FiredAt, "started firing")
+
+	levels := make([]string, 0, len(s.StateEnteredAt))
+	for state := range s.StateEnteredAt {
+		levels = append(levels, string(state))
+	}
+	sort.Strings(levels)
+	for _, state := range levels {
+		add(s.StateEnteredAt[AlertState(state)], "entered "+state)
+	}
+
+	add(s.ClearSince, "recovery condition held")
+	add(s.LastSignalAt, "last trigger signal")
+	add(s.LastSentAt, "last notification sent")
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries
+}
+
+func formatSnapshotTime(t time.Time) string {
+	if t.IsZero() {
+		return "<zero>"
+	}
+	return t.Format(time.RFC3339Nano)
 }
 
 type IAlert interface {
@@ -45,10 +141,23 @@ type IAlert interface {
 	State() AlertState
 	Snapshot() AlertSnapshot
 	Transition(ctx context.Context, firing bool, now time.Time) (shouldNotify bool, err error)
+	Resolve(ctx context.Context, now time.Time) (shouldNotify bool, err error)
+	// Reset 将状态机重置到初始状态，用于人工清除告警，不产生通知
+	Reset()
+	// Warn 序列仅满足预警条件时调用
+	Warn(ctx context.Context, now time.Time) (shouldNotify bool, err error)
+	TimeToResend(now time.Time) time.Duration
+	// Reason 最近一次状态决策的原因，用于排查告警为何未触发
+	Reason() string
 
 	SetValue(v float64)
 	GetValue() float64
 
+	// SetLabels 更新告警的标签，用于身份不变时刷新非身份标签
+	SetLabels(lbs labels.Labels)
+	SetMetadata(m map[string]string)
+	Metadata() map[string]string
+
 	Marshal() ([]byte, error)
 	Restore(data []byte, opt *AlertOpts) error
 }
@@ -58,12 +167,17 @@ type Alert struct {
 	typ    AlertType
 	opt    *AlertOpts
 
-	mtx   sync.RWMutex
-	Value float64
-	fsm   IFsm
+	mtx      sync.RWMutex
+	Value    float64
+	metadata map[string]string
+	fsm      IFsm
 }
 
 func NewAlert(typ AlertType, lbs labels.Labels, opt *AlertOpts) (*Alert, error) {
+	// 每个序列都会创建告警，这里只拒绝无法运行的配置，警告已在创建规则时记录
+	if _, err := checkOpts(typ, opt); err != nil {
+		return nil, err
+	}
 	fsm, err := NewFsm(typ)
 	if err != nil {
 		return nil, err
@@ -79,15 +193,56 @@ func NewAlert(typ AlertType, lbs labels.Labels, opt *AlertOpts) (*Alert, error)
 func (a *Alert) Transition(ctx context.Context, active bool, ts time.Time) (bool, error) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
+	// 配置了分级阈值时按当前值直接驱动到目标级别；
+	// 序列消失（active=false）时与逐级模式相同，走恢复逻辑
+	if d, ok := a.fsm.(*DegradeFsm); ok && active && len(a.opt.LevelThresholds) > 0 {
+		current, _ := d.State().DegradeLevel()
+		return d.TransitionTo(ctx, a.opt.targetLevel(a.Value, current), ts, a.opt)
+	}
 	return a.fsm.Transition(ctx, active, ts, a.opt)
 }
 
+func (a *Alert) Resolve(ctx context.Context, ts time.Time) (bool, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.fsm.Resolve(ctx, ts)
+}
+
+func (a *Alert) Reset() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.fsm.Reset()
+}
+
+// Warn 未触发的告警进入 pending 但不会升级为 firing，已触发的告警按恢复处理，仅支持 AlertTypeBasic
+func (a *Alert) Warn(ctx context.Context, ts time.Time) (bool, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	p, ok := a.fsm.(*PromAlertFsm)
+	if !ok {
+		return false, fmt.Errorf("%w: warn requires %s", ErrUnsupportedAlertType, AlertTypeBasic)
+	}
+	return p.Warn(ctx, ts)
+}
+
+func (a *Alert) TimeToResend(now time.Time) time.Duration {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.fsm.TimeToResend(now, a.opt)
+}
+
 func (a *Alert) State() AlertState {
 	a.mtx.RLock()
 	defer a.mtx.RUnlock()
 	return AlertState(a.fsm.State())
 }
 
+func (a *Alert) Reason() string {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.fsm.Reason()
+}
+
 func (a *Alert) SetValue(v float64) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
@@ -100,6 +255,24 @@ func (a *Alert) GetValue() float64 {
 	return a.Value
 }
 
+func (a *Alert) SetLabels(lbs labels.Labels) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.labels = lbs
+}
+
+func (a *Alert) SetMetadata(m map[string]string) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.metadata = m
+}
+
+func (a *Alert) Metadata() map[string]string {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.metadata
+}
+
 func (a *Alert) Labels() labels.Labels {
 	a.mtx.RLock()
 	defer a.mtx.RUnlock()
@@ -113,10 +286,11 @@ func (a *Alert) Snapshot() AlertSnapshot {
 }
 
 type alertPersisted struct {
-	Labels   labels.Labels `json:"labels"`
-	Value    float64       `json:"value"`
-	Snapshot AlertSnapshot `json:"machine"`
-	Typ      AlertType     `json:"type"`
+	Labels   labels.Labels     `json:"labels"`
+	Value    float64           `json:"value"`
+	Snapshot AlertSnapshot     `json:"machine"`
+	Typ      AlertType         `json:"type"`
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 func (a *Alert) Marshal() ([]byte, error) {
@@ -127,6 +301,7 @@ func (a *Alert) Marshal() ([]byte, error) {
 		Value:    a.Value,
 		Typ:      a.typ,
 		Snapshot: a.fsm.Snapshot(),
+		Metadata: a.metadata,
 	}
 	return json.Marshal(persisted)
 }
@@ -138,6 +313,7 @@ func (a *Alert) Restore(data []byte, opt *AlertOpts) error {
 	}
 	a.labels = persisted.Labels
 	a.Value = persisted.Value
+	a.metadata = persisted.Metadata
 	a.typ = persisted.Typ
 	a.opt = opt
 
@@ -113,6 +113,102 @@ func TestAlert_Transition_Firing_KeepFiring(t *testing.T) {
 	require.Equal(t, AlertStateInactive, alert.State())
 }
 
+func TestAlert_Transition_KeepFiringExtend(t *testing.T) {
+	keepFiring := 5 * time.Minute
+	opts := &AlertOpts{KeepFiringFor: keepFiring, KeepFiringMode: KeepFiringExtend}
+	alert, _ := NewAlert(AlertTypeBasic, labels.FromStrings("alertname", "TestAlert"), opts)
+
+	t0 := time.Now()
+	shouldSend, err := alert.Transition(context.Background(), true, t0)
+	require.NoError(t, err)
+	require.True(t, shouldSend)
+
+	// 条件持续满足时不会自动恢复
+	shouldSend, err = alert.Transition(context.Background(), true, t0.Add(2*keepFiring))
+	require.NoError(t, err)
+	require.False(t, shouldSend)
+	require.Equal(t, AlertStateFiring, alert.State())
+
+	// 条件消失后在 keepFiring 内保持触发
+	cleared := t0.Add(3 * keepFiring)
+	shouldSend, err = alert.Transition(context.Background(), false, cleared)
+	require.NoError(t, err)
+	require.False(t, shouldSend)
+	require.Equal(t, AlertStateFiring, alert.State())
+	require.Equal(t, "condition cleared, keep firing: 0s/5m0s", alert.Reason())
+	require.Equal(t, cleared, alert.Snapshot().ClearSince)
+
+	// 条件重新满足后计时清零
+	_, err = alert.Transition(context.Background(), true, cleared.Add(4*time.Minute))
+	require.NoError(t, err)
+	require.True(t, alert.Snapshot().ClearSince.IsZero())
+	cleared = cleared.Add(5 * time.Minute)
+	_, err = alert.Transition(context.Background(), false, cleared)
+	require.NoError(t, err)
+	shouldSend, err = alert.Transition(context.Background(), false, cleared.Add(keepFiring-time.Second))
+	require.NoError(t, err)
+	require.False(t, shouldSend)
+	require.Equal(t, AlertStateFiring, alert.State())
+
+	shouldSend, err = alert.Transition(context.Background(), false, cleared.Add(keepFiring))
+	require.NoError(t, err)
+	require.True(t, shouldSend, "resolves once the condition stays clear for keepFiring")
+	require.Equal(t, AlertStateInactive, alert.State())
+	require.Equal(t, "keep firing duration met, resolved", alert.Reason())
+	require.True(t, alert.Snapshot().ClearSince.IsZero())
+}
+
+func TestAlert_Transition_KeepFiringModes(t *testing.T) {
+	// 同一输入序列下两种模式的结果：条件在 0、3 分钟满足，6、12 分钟消失
+	offsets := []time.Duration{0, 3 * time.Minute, 6 * time.Minute, 12 * time.Minute}
+	run := func(mode KeepFiringMode) []AlertState {
+		opts := &AlertOpts{KeepFiringFor: 5 * time.Minute, KeepFiringMode: mode}
+		alert, err := NewAlert(AlertTypeBasic, labels.FromStrings("alertname", "TestAlert"), opts)
+		require.NoError(t, err)
+		t0 := time.Now()
+		var states []AlertState
+		for i, active := range []bool{true, true, false, false} {
+			_, err := alert.Transition(context.Background(), active, t0.Add(offsets[i]))
+			require.NoError(t, err)
+			states = append(states, alert.State())
+		}
+		return states
+	}
+	require.Equal(t, []AlertState{AlertStateFiring, AlertStateFiring, AlertStateInactive, AlertStateInactive}, run(""))
+	require.Equal(t, []AlertState{AlertStateFiring, AlertStateFiring, AlertStateInactive, AlertStateInactive}, run(KeepFiringAutoResolve))
+	require.Equal(t, []AlertState{AlertStateFiring, AlertStateFiring, AlertStateFiring, AlertStateInactive}, run(KeepFiringExtend))
+}
+
+func TestAlert_Reset(t *testing.T) {
+	opts := &AlertOpts{HoldDuration: time.Minute, ResendDelay: time.Minute}
+	alert, err := NewAlert(AlertTypeBasic, labels.FromStrings("instance", "test1"), opts)
+	require.NoError(t, err)
+	t0 := time.Now()
+	_, err = alert.Transition(context.Background(), true, t0)
+	require.NoError(t, err)
+	shouldSend, err := alert.Transition(context.Background(), true, t0.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, shouldSend)
+	require.Equal(t, AlertStateFiring, alert.State())
+
+	alert.Reset()
+	require.Equal(t, AlertStateInactive, alert.State())
+	require.Equal(t, AlertSnapshot{State: string(AlertStateInactive)}, alert.Snapshot())
+
+	// 重置后重新经历 pending 与 hold
+	t1 := t0.Add(time.Hour)
+	shouldSend, err = alert.Transition(context.Background(), true, t1)
+	require.NoError(t, err)
+	require.False(t, shouldSend)
+	require.Equal(t, AlertStatePending, alert.State())
+	require.Equal(t, t1, alert.Snapshot().ActiveAt)
+
+	shouldSend, err = alert.Transition(context.Background(), true, t1.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, shouldSend)
+	require.Equal(t, AlertStateFiring, alert.State())
+}
+
 func TestAlert_Transition_FiringToInactive_WhenResolved1(t *testing.T) {
 	opts := &AlertOpts{
 		HoldDuration:  1 * time.Minute,
@@ -248,6 +344,7 @@ func TestAlert_MarshalUnmarshal1(t *testing.T) {
 	require.Equal(t, alert.Labels(), restored.Labels())
 	require.Equal(t, alert.GetValue(), restored.GetValue())
 	require.Equal(t, alert.State(), restored.State())
+	require.Empty(t, DiffSnapshots(alert.Snapshot(), restored.Snapshot()))
 
 	// Check raw JSON content can be decoded
 	var decoded map[string]any
@@ -284,3 +381,91 @@ func TestAlert_MarshalUnmarshal2(t *testing.T) {
 	snap2, _ := newAlert.Marshal()
 	require.Equal(t, snap1, snap2)
 }
+
+func TestAlert_TimeToResend_Basic(t *testing.T) {
+	opts := &AlertOpts{HoldDuration: 0, ResendDelay: 5 * time.Minute}
+	alert, _ := NewAlert(AlertTypeBasic, labels.FromStrings("alertname", "TestAlert"), opts)
+
+	t0 := time.Now()
+	require.Equal(t, time.Duration(0), alert.TimeToResend(t0), "inactive alert has nothing scheduled")
+
+	_, err := alert.Transition(context.Background(), true, t0)
+	require.NoError(t, err)
+
+	prev := alert.TimeToResend(t0)
+	require.Equal(t, 5*time.Minute, prev)
+	for _, d := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		remaining := alert.TimeToResend(t0.Add(d))
+		require.Less(t, remaining, prev)
+		require.Equal(t, 5*time.Minute-d, remaining)
+		prev = remaining
+	}
+	require.Equal(t, time.Duration(0), alert.TimeToResend(t0.Add(6*time.Minute)), "overdue resend is due now")
+}
+
+func TestAlert_TimeToResend_Degrade(t *testing.T) {
+	opts := &AlertOpts{HoldDuration: time.Minute, ResendDelay: 3 * time.Minute}
+	alert, _ := NewAlert(AlertTypeMultiTier, labels.FromStrings("alertname", "TestAlert"), opts)
+
+	t0 := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := alert.Transition(context.Background(), true, t0.Add(time.Duration(i)*2*time.Minute))
+		require.NoError(t, err)
+	}
+	require.Equal(t, AlertStateL3, alert.State())
+
+	sentAt := t0.Add(6 * time.Minute)
+	require.Equal(t, 3*time.Minute, alert.TimeToResend(sentAt))
+	require.Equal(t, 2*time.Minute, alert.TimeToResend(sentAt.Add(time.Minute)))
+	require.Equal(t, time.Minute, alert.TimeToResend(sentAt.Add(2*time.Minute)))
+	require.Equal(t, time.Duration(0), alert.TimeToResend(sentAt.Add(3*time.Minute)))
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := AlertSnapshot{
+		State:          string(AlertStateL2),
+		ActiveAt:       t0,
+		LastSentAt:     t0.Add(time.Minute),
+		StateEnteredAt: map[AlertState]time.Time{AlertStateL1: t0, AlertStateL2: t0.Add(time.Minute)},
+	}
+	require.Empty(t, DiffSnapshots(a, a))
+
+	b := a
+	b.State = string(AlertStateL1)
+	b.LastSentAt = t0.Add(2 * time.Minute)
+	b.FiredAt = t0
+	b.StateEnteredAt = map[AlertState]time.Time{AlertStateL1: t0, AlertStateL3: t0}
+
+	require.Equal(t, []string{
+		`state: "l2" != "l1"`,
+		"firedAt: <zero> != 2024-01-01T00:00:00Z",
+		"lastSentAt: 2024-01-01T00:01:00Z != 2024-01-01T00:02:00Z",
+		"stateEnteredAt[l2]: 2024-01-01T00:01:00Z != <zero>",
+		"stateEnteredAt[l3]: <zero> != 2024-01-01T00:00:00Z",
+	}, DiffSnapshots(a, b))
+}
+
+func TestAlertSnapshot_Timeline(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := &AlertOpts{HoldDuration: time.Minute, RecoverDuration: 5 * time.Minute, SustainedRecover: true}
+	d := NewDegradeFsm()
+	for i := 0; i <= 2; i++ {
+		_, err := d.Transition(context.Background(), true, t0.Add(time.Duration(i)*time.Minute), opts)
+		require.NoError(t, err)
+	}
+	_, err := d.Transition(context.Background(), false, t0.Add(3*time.Minute), opts)
+	require.NoError(t, err)
+	require.Equal(t, AlertStateL2, d.State())
+
+	require.Equal(t, []TimelineEntry{
+		{Time: t0, Description: "entered l0"},
+		{Time: t0.Add(time.Minute), Description: "entered l1"},
+		{Time: t0.Add(2 * time.Minute), Description: "entered l2"},
+		{Time: t0.Add(2 * time.Minute), Description: "last trigger signal"},
+		{Time: t0.Add(2 * time.Minute), Description: "last notification sent"},
+		{Time: t0.Add(3 * time.Minute), Description: "recovery condition held"},
+	}, d.Snapshot().Timeline())
+
+	require.Empty(t, AlertSnapshot{}.Timeline())
+}
@@ -0,0 +1,47 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+)
+
+// evalBaseline 分别在 ts 和 ts-BaselineOffset 查询 Expr，按标签配对计算 当前值/基线值，
+// 返回比值超过 Factor 的序列，样本值为该比值。基线缺失或为 0 的序列无法计算比值，不参与判断
+func (r *Rule) evalBaseline(ctx context.Context, ts time.Time, query QueryFunc) (promql.Vector, error) {
+	current, err := query(ctx, r.Expr, ts)
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := query(ctx, r.Expr, ts.Add(-r.BaselineOffset))
+	if err != nil {
+		return nil, fmt.Errorf("baseline: %w", err)
+	}
+
+	base := make(map[uint64]float64, len(baseline))
+	for _, sample := range baseline {
+		v, err := r.sampleValue(sample)
+		if err != nil {
+			return nil, err
+		}
+		base[sample.Metric.Hash()] = v
+	}
+
+	var result promql.Vector
+	for _, sample := range current {
+		b, ok := base[sample.Metric.Hash()]
+		if !ok || b == 0 {
+			continue
+		}
+		v, err := r.sampleValue(sample)
+		if err != nil {
+			return nil, err
+		}
+		if ratio := v / b; ratio > r.Factor {
+			result = append(result, promql.Sample{Metric: sample.Metric, T: sample.T, F: ratio})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package alertmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeClock_Ticker(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(epoch)
+	ticker := clock.NewTicker(time.Minute)
+
+	clock.Advance(30 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired before its period elapsed")
+	default:
+	}
+
+	clock.Advance(30 * time.Second)
+	require.Equal(t, epoch.Add(time.Minute), <-ticker.C())
+	require.Equal(t, epoch.Add(time.Minute), clock.Now())
+
+	// 未消费的 tick 会被丢弃，与 time.Ticker 一致
+	clock.Advance(3 * time.Minute)
+	require.Equal(t, epoch.Add(2*time.Minute), <-ticker.C())
+	select {
+	case <-ticker.C():
+		t.Fatal("missed ticks should be dropped")
+	default:
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+func TestAlertManager_FakeClock(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(epoch)
+
+	evaluated := make(chan time.Time, 1)
+	queryFn := func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+		evaluated <- ts
+		return promql.Vector{{Metric: labels.FromStrings("instance", "a"), F: 1}}, nil
+	}
+
+	rule := newTestRule(t, "hold", "up", &AlertOpts{HoldDuration: 3 * time.Minute})
+	notifier := &recordNotifier{}
+	am := NewAlertManager([]*Rule{rule}, time.Minute, queryFn, notifier, NewMemoryStorage())
+	am.Clock = clock
+	require.NoError(t, am.Run())
+	defer am.Stop()
+
+	for i := 1; i <= 4; i++ {
+		clock.Advance(time.Minute)
+		require.Equal(t, epoch.Add(time.Duration(i)*time.Minute), <-evaluated)
+		am.evalWg.Wait()
+		if i < 4 {
+			require.Empty(t, notifier.All(), "tick %d: hold duration not yet reached", i)
+		}
+	}
+
+	all := notifier.All()
+	require.Len(t, all, 1)
+	require.Equal(t, string(AlertStateFiring), all[0].Status)
+	require.Equal(t, epoch.Add(4*time.Minute), all[0].StartsAt)
+}
+
+func TestAlertManager_AlignEvalTimestamp(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(epoch.Add(17 * time.Second)) // 启动时间不在周期边界上
+
+	evaluated := make(chan time.Time, 1)
+	queryFn := func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+		evaluated <- ts
+		return nil, nil
+	}
+
+	rule := newTestRule(t, "aligned", "up", &AlertOpts{})
+	am := NewAlertManager([]*Rule{rule}, time.Minute, queryFn, &recordNotifier{}, NewMemoryStorage())
+	am.Clock = clock
+	am.AlignEvalTimestamp = true
+	require.NoError(t, am.Run())
+	defer am.Stop()
+
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Minute)
+		ts := <-evaluated
+		am.evalWg.Wait()
+		require.Equal(t, epoch.Add(time.Duration(i)*time.Minute), ts)
+		require.Equal(t, ts, ts.Truncate(time.Minute), "evaluation timestamp should sit on an interval boundary")
+	}
+}
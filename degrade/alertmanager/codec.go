@@ -0,0 +1,29 @@
+package alertmanager
+
+// Codec 单条告警的持久化编码，存储层通过它与告警的序列化格式解耦
+type Codec interface {
+	Encode(alert IAlert) ([]byte, error)
+	Decode(data []byte, opts *AlertOpts) (IAlert, error)
+}
+
+// JSONCodec 使用 Alert.Marshal/Restore 的 JSON 编码，是存储的默认编码
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(alert IAlert) ([]byte, error) {
+	return alert.Marshal()
+}
+
+func (JSONCodec) Decode(data []byte, opts *AlertOpts) (IAlert, error) {
+	alert := &Alert{}
+	if err := alert.Restore(data, opts); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+func codecOrDefault(c Codec) Codec {
+	if c == nil {
+		return JSONCodec{}
+	}
+	return c
+}
@@ -0,0 +1,85 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+// versionedCodec 在 JSON 前加版本头，模拟带迁移层的编码
+type versionedCodec struct {
+	encoded, decoded int
+}
+
+var versionHeader = []byte("v2:")
+
+func (c *versionedCodec) Encode(alert IAlert) ([]byte, error) {
+	c.encoded++
+	data, err := JSONCodec{}.Encode(alert)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(nil), versionHeader...), data...), nil
+}
+
+func (c *versionedCodec) Decode(data []byte, opts *AlertOpts) (IAlert, error) {
+	c.decoded++
+	if !bytes.HasPrefix(data, versionHeader) {
+		return nil, errors.New("missing version header")
+	}
+	return JSONCodec{}.Decode(bytes.TrimPrefix(data, versionHeader), opts)
+}
+
+func TestStorage_Codec(t *testing.T) {
+	opts := &AlertOpts{}
+	rule := newTestRule(t, "disk", "disk_used > 0", opts)
+
+	var alerts []IAlert
+	for _, instance := range []string{"host1", "host2"} {
+		alert, err := NewAlert(AlertTypeBasic, labels.FromStrings("instance", instance), opts)
+		require.NoError(t, err)
+		_, err = alert.Transition(context.Background(), true, time.Now())
+		require.NoError(t, err)
+		alerts = append(alerts, alert)
+	}
+
+	fileStorage, err := NewFileStorage(t.TempDir())
+	require.NoError(t, err)
+	memStorage := NewMemoryStorage()
+
+	for _, c := range []struct {
+		name     string
+		storage  Storage
+		setCodec func(Codec)
+	}{
+		{"file", fileStorage, func(codec Codec) { fileStorage.Codec = codec }},
+		{"memory", memStorage, func(codec Codec) { memStorage.Codec = codec }},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			storage := c.storage
+			codec := &versionedCodec{}
+			c.setCodec(codec)
+			require.NoError(t, storage.SaveAlerts(rule, alerts))
+			require.Equal(t, 2, codec.encoded)
+
+			loaded, err := storage.LoadAlerts(rule)
+			require.NoError(t, err)
+			require.Equal(t, 2, codec.decoded)
+			require.Len(t, loaded, 2)
+			for i, alert := range loaded {
+				require.Equal(t, alerts[i].Labels(), alert.Labels())
+				require.Empty(t, DiffSnapshots(alerts[i].Snapshot(), alert.Snapshot()))
+			}
+
+			// 默认 JSON 编码无法读取自定义格式
+			c.setCodec(nil)
+			_, err = storage.LoadAlerts(rule)
+			require.Error(t, err)
+		})
+	}
+}
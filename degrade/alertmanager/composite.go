@@ -0,0 +1,164 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+)
+
+// CompositeOp 组合条件的逻辑运算
+type CompositeOp string
+
+const (
+	CompositeAnd CompositeOp = "and"
+	CompositeOr  CompositeOp = "or"
+)
+
+// ConditionsLabel 组合规则通知 Metadata 中记录已满足子条件的键，值为逗号分隔的条件名
+const ConditionsLabel = "conditions"
+
+// Condition 组合规则中的一个具名子条件
+type Condition struct {
+	Name string
+	Expr string
+}
+
+// CompositeRule 由多个具名子条件按 AND/OR 组合而成的告警条件，
+// 各子条件的查询结果按 On 标签关联到同一实例
+type CompositeRule struct {
+	Op         CompositeOp
+	Conditions []Condition
+	// On 关联子条件结果的标签，结果序列只保留这些标签；为空时按除 __name__ 外的全部标签关联
+	On []string
+}
+
+// NewCompositeRule 创建以组合条件代替 Expr 的规则，Expr 为组合条件的可读形式
+func NewCompositeRule(
+	name string,
+	typ AlertType,
+	opts *AlertOpts,
+	composite *CompositeRule,
+	lbs, ann labels.Labels,
+) (*Rule, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+	if composite == nil {
+		return nil, errors.New("nil composite condition")
+	}
+	if err := composite.validate(); err != nil {
+		return nil, fmt.Errorf("rule %s: %w", name, err)
+	}
+	if opts == nil {
+		opts = &AlertOpts{}
+	}
+	if err := ValidateOpts(typ, opts); err != nil {
+		return nil, fmt.Errorf("rule %s: %w", name, err)
+	}
+	return &Rule{
+		Name:        name,
+		Expr:        composite.String(),
+		AlertType:   typ,
+		AlertOpts:   opts,
+		Labels:      lbs,
+		Annotations: ann,
+		Composite:   composite,
+		active:      make(map[uint64]IAlert),
+	}, nil
+}
+
+func (c *CompositeRule) validate() error {
+	if c.Op != CompositeAnd && c.Op != CompositeOr {
+		return fmt.Errorf("unsupported composite op %q", c.Op)
+	}
+	if len(c.Conditions) == 0 {
+		return errors.New("no conditions")
+	}
+	seen := make(map[string]struct{}, len(c.Conditions))
+	for _, cond := range c.Conditions {
+		if cond.Name == "" || cond.Expr == "" {
+			return errors.New("empty condition name or expr")
+		}
+		if strings.Contains(cond.Name, ",") {
+			return fmt.Errorf("condition name %q contains ','", cond.Name)
+		}
+		if _, dup := seen[cond.Name]; dup {
+			return fmt.Errorf("duplicate condition %q", cond.Name)
+		}
+		seen[cond.Name] = struct{}{}
+	}
+	return nil
+}
+
+// String 组合条件的可读形式，如 "(cpu_usage > 0.3) and (memory_usage > 0.6)"
+func (c *CompositeRule) String() string {
+	parts := make([]string, 0, len(c.Conditions))
+	for _, cond := range c.Conditions {
+		parts = append(parts, "("+cond.Expr+")")
+	}
+	return strings.Join(parts, " "+string(c.Op)+" ")
+}
+
+// joinedSeries 按关联标签聚合后的一个实例
+type joinedSeries struct {
+	labels labels.Labels
+	value  float64  // 第一个满足的子条件的值
+	active []string // 满足的子条件名，按 Conditions 顺序
+}
+
+// eval 查询各子条件并按 On 关联，返回满足组合条件的序列，
+// 序列带有 ConditionsLabel 标签记录满足的子条件
+func (c *CompositeRule) eval(ctx context.Context, ts time.Time, query QueryFunc, value func(promql.Sample) (float64, error)) (promql.Vector, error) {
+	var (
+		order  []uint64
+		joined = make(map[uint64]*joinedSeries)
+	)
+	for _, cond := range c.Conditions {
+		vector, err := query(ctx, cond.Expr, ts)
+		if err != nil {
+			return nil, fmt.Errorf("condition %s: %w", cond.Name, err)
+		}
+		for _, sample := range vector {
+			lbs := c.joinLabels(sample.Metric)
+			fp := lbs.Hash()
+			series, ok := joined[fp]
+			if !ok {
+				v, err := value(sample)
+				if err != nil {
+					return nil, fmt.Errorf("condition %s: %w", cond.Name, err)
+				}
+				series = &joinedSeries{labels: lbs, value: v}
+				joined[fp] = series
+				order = append(order, fp)
+			}
+			// 同一条件关联到同一实例的多个序列只计一次
+			if n := len(series.active); n == 0 || series.active[n-1] != cond.Name {
+				series.active = append(series.active, cond.Name)
+			}
+		}
+	}
+
+	var result promql.Vector
+	for _, fp := range order {
+		series := joined[fp]
+		if c.Op == CompositeAnd && len(series.active) < len(c.Conditions) {
+			continue
+		}
+		builder := labels.NewBuilder(series.labels)
+		builder.Set(ConditionsLabel, strings.Join(series.active, ","))
+		result = append(result, promql.Sample{Metric: builder.Labels(), F: series.value})
+	}
+	return result, nil
+}
+
+func (c *CompositeRule) joinLabels(metric labels.Labels) labels.Labels {
+	if len(c.On) > 0 {
+		return metric.MatchLabels(true, c.On...)
+	}
+	return metric.DropMetricName()
+}
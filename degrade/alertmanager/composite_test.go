@@ -0,0 +1,118 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+func compositeQuery(results map[string]promql.Vector) QueryFunc {
+	return func(_ context.Context, query string, _ time.Time) (promql.Vector, error) {
+		vector, ok := results[query]
+		if !ok {
+			return nil, errors.New("unknown query " + query)
+		}
+		return vector, nil
+	}
+}
+
+// firedConditions 评估一次组合规则，返回触发的实例及其满足的子条件
+func firedConditions(t *testing.T, rule *Rule, query QueryFunc) map[string]string {
+	alerts, err := rule.Eval(context.Background(), time.Now(), query)
+	require.NoError(t, err)
+	fired := make(map[string]string)
+	for _, alert := range alerts {
+		n := NewNotification(rule, alert)
+		require.NotContains(t, n.Labels, ConditionsLabel, "conditions do not take part in alert identity")
+		fired[n.Labels["instance"]] = n.Metadata[ConditionsLabel]
+	}
+	return fired
+}
+
+func TestCompositeRule_AndOr(t *testing.T) {
+	query := compositeQuery(map[string]promql.Vector{
+		"cpu_usage > 0.3": {
+			{Metric: labels.FromStrings("__name__", "cpu_usage", "instance", "host1"), F: 0.5},
+			{Metric: labels.FromStrings("__name__", "cpu_usage", "instance", "host2"), F: 0.4},
+		},
+		"memory_usage > 0.6": {
+			{Metric: labels.FromStrings("__name__", "memory_usage", "instance", "host1"), F: 0.7},
+			{Metric: labels.FromStrings("__name__", "memory_usage", "instance", "host3"), F: 0.9},
+		},
+	})
+	conditions := []Condition{
+		{Name: "cpu", Expr: "cpu_usage > 0.3"},
+		{Name: "mem", Expr: "memory_usage > 0.6"},
+	}
+
+	and, err := NewCompositeRule("Overload", AlertTypeBasic, nil,
+		&CompositeRule{Op: CompositeAnd, Conditions: conditions}, labels.EmptyLabels(), labels.EmptyLabels())
+	require.NoError(t, err)
+	require.Equal(t, "(cpu_usage > 0.3) and (memory_usage > 0.6)", and.Expr)
+	require.Equal(t, map[string]string{"host1": "cpu,mem"}, firedConditions(t, and, query))
+
+	or, err := NewCompositeRule("Overload", AlertTypeBasic, nil,
+		&CompositeRule{Op: CompositeOr, Conditions: conditions}, labels.EmptyLabels(), labels.EmptyLabels())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"host1": "cpu,mem", "host2": "cpu", "host3": "mem"}, firedConditions(t, or, query))
+}
+
+func TestCompositeRule_JoinOn(t *testing.T) {
+	// 子条件的标签集不完全相同，只有 instance 一致
+	query := compositeQuery(map[string]promql.Vector{
+		"cpu": {
+			{Metric: labels.FromStrings("__name__", "cpu_usage", "instance", "host1", "cpu", "0"), F: 0.5},
+			{Metric: labels.FromStrings("__name__", "cpu_usage", "instance", "host1", "cpu", "1"), F: 0.6},
+		},
+		"mem": {
+			{Metric: labels.FromStrings("__name__", "memory_usage", "instance", "host1", "job", "node"), F: 0.7},
+		},
+	})
+	composite := &CompositeRule{
+		Op:         CompositeAnd,
+		Conditions: []Condition{{Name: "cpu", Expr: "cpu"}, {Name: "mem", Expr: "mem"}},
+	}
+	rule, err := NewCompositeRule("Overload", AlertTypeBasic, nil, composite, labels.EmptyLabels(), labels.EmptyLabels())
+	require.NoError(t, err)
+	require.Empty(t, firedConditions(t, rule, query), "labels differ, nothing joins without On")
+
+	composite.On = []string{"instance"}
+	alerts, err := rule.Eval(context.Background(), time.Now(), query)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	n := NewNotification(rule, alerts[0])
+	require.Equal(t, map[string]string{"alertname": "Overload", "instance": "host1"}, n.Labels)
+	require.Equal(t, "cpu,mem", n.Metadata[ConditionsLabel], "multiple series of one condition count once")
+	require.Equal(t, 0.5, n.Value, "value comes from the first matching condition")
+}
+
+func TestCompositeRule_Errors(t *testing.T) {
+	rule, err := NewCompositeRule("Overload", AlertTypeBasic, nil, &CompositeRule{
+		Op:         CompositeOr,
+		Conditions: []Condition{{Name: "cpu", Expr: "cpu"}, {Name: "disk", Expr: "disk"}},
+	}, labels.EmptyLabels(), labels.EmptyLabels())
+	require.NoError(t, err)
+	_, err = rule.Eval(context.Background(), time.Now(), compositeQuery(map[string]promql.Vector{"cpu": nil}))
+	require.ErrorContains(t, err, "condition disk")
+
+	for _, composite := range []*CompositeRule{
+		nil,
+		{Op: "xor", Conditions: []Condition{{Name: "a", Expr: "a"}}},
+		{Op: CompositeAnd},
+		{Op: CompositeAnd, Conditions: []Condition{{Name: "a", Expr: "a"}, {Name: "a", Expr: "b"}}},
+		{Op: CompositeAnd, Conditions: []Condition{{Name: "a,b", Expr: "a"}}},
+		{Op: CompositeAnd, Conditions: []Condition{{Name: "a"}}},
+	} {
+		_, err := NewCompositeRule("x", AlertTypeBasic, nil, composite, labels.EmptyLabels(), labels.EmptyLabels())
+		require.Error(t, err, "composite %+v", composite)
+	}
+	_, err = NewCompositeRule("x", AlertTypeCustom, nil, &CompositeRule{
+		Op: CompositeAnd, Conditions: []Condition{{Name: "a", Expr: "a"}},
+	}, labels.EmptyLabels(), labels.EmptyLabels())
+	require.ErrorIs(t, err, ErrUnsupportedAlertType)
+}
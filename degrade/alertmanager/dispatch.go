@@ -0,0 +1,138 @@
+package alertmanager
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// BudgetAlertName 超出 MaxNotificationsPerCycle 时发送的元告警的 alertname
+const BudgetAlertName = "NotificationBudgetExceeded"
+
+// DroppedLabel 预算元告警 Metadata 中记录因顺延队列已满而丢弃的通知数
+const DroppedLabel = "dropped"
+
+// deferredKey 标识顺延通知所属的告警
+type deferredKey struct {
+	rule string
+	fp   uint64
+}
+
+func notificationKey(n *Notification) deferredKey {
+	return deferredKey{rule: n.Rule, fp: labels.FromMap(n.Labels).Hash()}
+}
+
+// maxDeferred 顺延队列上限
+func (am *AlertManager) maxDeferred() int {
+	if am.MaxDeferredNotifications > 0 {
+		return am.MaxDeferredNotifications
+	}
+	return 10 * am.MaxNotificationsPerCycle
+}
+
+// startCycle 重置本轮配额，返回上一轮顺延、本轮可以发送的通知，这些通知先于本轮新产生的通知占用配额
+func (am *AlertManager) startCycle() []*Notification {
+	am.dispatchMtx.Lock()
+	defer am.dispatchMtx.Unlock()
+	am.cycleLeft = am.MaxNotificationsPerCycle
+	am.budgetHit = false
+
+	n := min(len(am.deferred), am.cycleLeft)
+	ready := am.deferred[:n:n]
+	am.deferred = am.deferred[n:]
+	am.cycleLeft -= n
+	return ready
+}
+
+// admit 从本轮配额中为 notifications 申请发送名额，超出部分按顺序顺延到下一轮；
+// 同一告警已顺延的旧通知被新通知取代，队列超出上限时丢弃最早的通知；
+// 本轮首次超出时在返回结果末尾附加一条不占配额的元告警
+func (am *AlertManager) admit(notifications []*Notification, now time.Time) []*Notification {
+	if am.MaxNotificationsPerCycle <= 0 {
+		return notifications
+	}
+	am.dispatchMtx.Lock()
+	defer am.dispatchMtx.Unlock()
+
+	am.dropSuperseded(notifications)
+
+	n := min(len(notifications), am.cycleLeft)
+	am.cycleLeft -= n
+	if n == len(notifications) {
+		return notifications
+	}
+	allowed := notifications[:n:n]
+	am.deferred = append(am.deferred, notifications[n:]...)
+	if over := len(am.deferred) - am.maxDeferred(); over > 0 {
+		am.deferred = append([]*Notification(nil), am.deferred[over:]...)
+		am.droppedDeferred += over
+	}
+	if !am.budgetHit {
+		am.budgetHit = true
+		log.Printf("Notification budget %d exhausted, %d notifications deferred, %d dropped",
+			am.MaxNotificationsPerCycle, len(am.deferred), am.droppedDeferred)
+		allowed = append(allowed, am.budgetNotification(now))
+		am.droppedDeferred = 0
+	}
+	return allowed
+}
+
+// dropSuperseded 移除与 notifications 属于同一告警的顺延通知，避免过期状态晚于新状态送达
+func (am *AlertManager) dropSuperseded(notifications []*Notification) {
+	if len(am.deferred) == 0 {
+		return
+	}
+	keys := make(map[deferredKey]struct{}, len(notifications))
+	for _, n := range notifications {
+		keys[notificationKey(n)] = struct{}{}
+	}
+	kept := am.deferred[:0]
+	for _, n := range am.deferred {
+		if _, ok := keys[notificationKey(n)]; !ok {
+			kept = append(kept, n)
+		}
+	}
+	clear(am.deferred[len(kept):])
+	am.deferred = kept
+}
+
+// budgetNotification 构造预算元告警，Value 为触发时顺延的通知数，
+// Metadata 中的 DroppedLabel 为上一条元告警以来因队列已满丢弃的通知数
+func (am *AlertManager) budgetNotification(now time.Time) *Notification {
+	n := &Notification{
+		Rule:     BudgetAlertName,
+		Status:   string(AlertStateFiring),
+		Labels:   map[string]string{labels.AlertName: BudgetAlertName},
+		Kind:     NotificationNew,
+		Value:    float64(len(am.deferred)),
+		StartsAt: now,
+	}
+	if am.droppedDeferred > 0 {
+		n.Metadata = map[string]string{DroppedLabel: strconv.Itoa(am.droppedDeferred)}
+	}
+	return n
+}
+
+// dispatchDeferred 在后台发送上一轮顺延的通知，返回的通道在发送结束后关闭；
+// 单次发送最长等待一个评估周期，避免接收方不可用时顺延通知无限阻塞本轮通知
+func (am *AlertManager) dispatchDeferred(notifications []*Notification) <-chan struct{} {
+	done := make(chan struct{})
+	if len(notifications) == 0 {
+		close(done)
+		return done
+	}
+	am.evalWg.Add(1)
+	go func() {
+		defer am.evalWg.Done()
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), am.interval)
+		defer cancel()
+		if err := am.notifier.Notify(ctx, notifications); err != nil {
+			log.Printf("Error sending %d deferred notifications: %v", len(notifications), err)
+		}
+	}()
+	return done
+}
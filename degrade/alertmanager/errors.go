@@ -0,0 +1,14 @@
+package alertmanager
+
+import "errors"
+
+// 规则与告警操作返回的哨兵错误，调用方可通过 errors.Is 判断
+var (
+	ErrRuleExists           = errors.New("rule already exists")
+	ErrRuleNotFound         = errors.New("rule not found")
+	ErrUnsupportedAlertType = errors.New("unsupported alert type")
+	ErrInvalidDuration      = errors.New("durations cannot be negative")
+	ErrInvalidOpts          = errors.New("invalid alert opts")
+	ErrHistogramSample      = errors.New("histogram sample without HistogramQuantile")
+	ErrEvalPanic            = errors.New("rule evaluation panicked")
+)
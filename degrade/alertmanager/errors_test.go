@@ -0,0 +1,37 @@
+package alertmanager
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	am := NewAlertManager(nil, time.Second, nil, &recordNotifier{}, NewMemoryStorage())
+	rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{})
+	require.NoError(t, am.AddRule(rule))
+
+	err := am.AddRule(rule)
+	require.True(t, errors.Is(err, ErrRuleExists))
+	require.Contains(t, err.Error(), "HighCPU")
+
+	err = am.RemoveRule("missing")
+	require.True(t, errors.Is(err, ErrRuleNotFound))
+	require.Contains(t, err.Error(), "missing")
+
+	_, err = NewRule("r", "up", -time.Second, 0, 0, labels.EmptyLabels(), labels.EmptyLabels())
+	require.True(t, errors.Is(err, ErrInvalidDuration))
+
+	_, err = NewRuleFromSpec(RuleSpec{Name: "r", Expr: "up", AtOffset: -time.Second})
+	require.True(t, errors.Is(err, ErrInvalidDuration))
+
+	_, err = NewRuleFromSpec(RuleSpec{Name: "r", Expr: "up", AlertType: AlertTypeCustom})
+	require.True(t, errors.Is(err, ErrUnsupportedAlertType))
+
+	_, err = NewFsm(AlertTypeCustom)
+	require.True(t, errors.Is(err, ErrUnsupportedAlertType))
+	require.Contains(t, err.Error(), string(AlertTypeCustom))
+}
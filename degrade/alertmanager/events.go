@@ -0,0 +1,130 @@
+package alertmanager
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// eventBufferSize 每个订阅者的事件缓冲大小
+const eventBufferSize = 64
+
+// StateChangeEvent 告警状态变化事件
+type StateChangeEvent struct {
+	Fingerprint uint64
+	Rule        string
+	Labels      labels.Labels
+	From        AlertState // 新建告警为空
+	To          AlertState
+	Timestamp   time.Time
+}
+
+// eventBroker 非阻塞地向订阅者广播事件，订阅者消费过慢时丢弃事件
+type eventBroker struct {
+	mtx     sync.RWMutex
+	nextID  int
+	subs    map[int]chan StateChangeEvent
+	dropped atomic.Uint64
+}
+
+func (b *eventBroker) subscribe() (<-chan StateChangeEvent, func()) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[int]chan StateChangeEvent)
+	}
+	id := b.nextID
+	b.nextID++
+	ch := make(chan StateChangeEvent, eventBufferSize)
+	b.subs[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mtx.Lock()
+			defer b.mtx.Unlock()
+			delete(b.subs, id)
+			close(ch)
+		})
+	}
+}
+
+func (b *eventBroker) publish(e StateChangeEvent) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+func (b *eventBroker) hasSubscribers() bool {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return len(b.subs) > 0
+}
+
+// alertStates 记录规则当前所有告警的状态
+func (r *Rule) alertStates() map[uint64]alertState {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	states := make(map[uint64]alertState, len(r.active))
+	for fp, alert := range r.active {
+		states[fp] = alertState{alert: alert, state: alert.State()}
+	}
+	return states
+}
+
+type alertState struct {
+	alert IAlert
+	state AlertState
+}
+
+// publishChanges 对比评估前后的告警状态并发布变化事件
+func (am *AlertManager) publishChanges(r *Rule, before map[uint64]alertState, ts time.Time) {
+	after := r.alertStates()
+	for fp, cur := range after {
+		prev := before[fp]
+		if prev.state != cur.state {
+			am.events.publish(StateChangeEvent{
+				Fingerprint: fp,
+				Rule:        r.Name,
+				Labels:      cur.alert.Labels(),
+				From:        prev.state,
+				To:          cur.state,
+				Timestamp:   ts,
+			})
+		}
+	}
+	// 已从规则中移除的告警
+	for fp, prev := range before {
+		if _, ok := after[fp]; ok {
+			continue
+		}
+		if state := prev.alert.State(); state != prev.state {
+			am.events.publish(StateChangeEvent{
+				Fingerprint: fp,
+				Rule:        r.Name,
+				Labels:      prev.alert.Labels(),
+				From:        prev.state,
+				To:          state,
+				Timestamp:   ts,
+			})
+		}
+	}
+}
+
+// Subscribe 订阅告警状态变化事件，返回事件通道和取消订阅函数
+func (am *AlertManager) Subscribe() (<-chan StateChangeEvent, func()) {
+	return am.events.subscribe()
+}
+
+// DroppedEvents 因订阅者消费过慢而丢弃的事件数
+func (am *AlertManager) DroppedEvents() uint64 {
+	return am.events.dropped.Load()
+}
@@ -0,0 +1,68 @@
+package alertmanager
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertManager_Subscribe(t *testing.T) {
+	var active atomic.Bool
+	queryFn := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		if !active.Load() {
+			return promql.Vector{}, nil
+		}
+		return promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: 1}}, nil
+	}
+	rule := newTestRule(t, "HighCPU", "cpu > 0.8", &AlertOpts{})
+	am := NewAlertManager([]*Rule{rule}, time.Second, queryFn, &recordNotifier{}, nil)
+
+	events, unsubscribe := am.Subscribe()
+
+	evaluate := func() {
+		am.evaluateAllRules()
+		am.evalWg.Wait()
+	}
+	active.Store(true)
+	evaluate()
+	evaluate() // 保持 firing，不产生事件
+	active.Store(false)
+	evaluate()
+
+	unsubscribe()
+	unsubscribe()
+
+	var got []StateChangeEvent
+	for e := range events {
+		got = append(got, e)
+	}
+	require.Len(t, got, 2)
+
+	require.Equal(t, "HighCPU", got[0].Rule)
+	require.Equal(t, AlertState(""), got[0].From)
+	require.Equal(t, AlertStateFiring, got[0].To)
+	require.Equal(t, "host1", got[0].Labels.Get("instance"))
+
+	require.Equal(t, AlertStateFiring, got[1].From)
+	require.Equal(t, AlertStateInactive, got[1].To)
+	require.Equal(t, got[0].Fingerprint, got[1].Fingerprint)
+	require.True(t, got[1].Timestamp.After(got[0].Timestamp))
+	require.Zero(t, am.DroppedEvents())
+}
+
+func TestEventBroker_DropsWhenSlow(t *testing.T) {
+	var b eventBroker
+	ch, unsubscribe := b.subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < eventBufferSize+5; i++ {
+		b.publish(StateChangeEvent{To: AlertStateFiring})
+	}
+	require.Len(t, ch, eventBufferSize)
+	require.Equal(t, uint64(5), b.dropped.Load())
+}
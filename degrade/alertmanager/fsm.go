@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"github.com/looplab/fsm"
 )
 
 // 状态和事件定义
@@ -16,9 +18,27 @@ const (
 
 type IFsm interface {
 	Transition(ctx context.Context, active bool, ts time.Time, opts *AlertOpts) (bool, error)
+	// Resolve 强制恢复到初始状态，之前已通知过时返回 true
+	Resolve(ctx context.Context, ts time.Time) (bool, error)
+	// TimeToResend 距离下一次通知（重发或状态升级）的剩余时间，已到期或无待发通知时返回 0
+	TimeToResend(now time.Time, opts *AlertOpts) time.Duration
 	Snapshot() AlertSnapshot
 	Restore(snap AlertSnapshot) error
+	// Reset 回到初始状态（inactive/L0）并清空全部时间记录，不产生通知
+	Reset()
 	State() AlertState
+	// Reason 最近一次 Transition/Warn/Resolve 的决策原因，如 "hold duration not met: 30s/1m0s"
+	Reason() string
+}
+
+// eventTime 取出状态转移事件携带的时间戳，未携带时使用当前时间
+func eventTime(e *fsm.Event) time.Time {
+	if len(e.Args) > 0 {
+		if ts, ok := e.Args[0].(time.Time); ok {
+			return ts
+		}
+	}
+	return time.Now()
 }
 
 func NewFsm(typ AlertType) (IFsm, error) {
@@ -28,6 +48,6 @@ func NewFsm(typ AlertType) (IFsm, error) {
 	case AlertTypeMultiTier:
 		return NewDegradeFsm(), nil
 	default:
-		return nil, fmt.Errorf("unsupported alert type: %s", typ)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlertType, typ)
 	}
 }
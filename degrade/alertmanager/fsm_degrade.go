@@ -2,6 +2,7 @@ package alertmanager
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -15,6 +16,9 @@ type DegradeFsm struct {
 	// 状态时间记录
 	stateEnteredAt map[AlertState]time.Time
 	lastSentAt     time.Time
+	clearSince     time.Time // 恢复条件持续满足的起始时间
+	lastSignalAt   time.Time // 最后一次收到触发信号的时间
+	reason         string    // 最近一次决策的原因
 
 	// 状态机配置
 	events    fsm.Events
@@ -27,9 +31,6 @@ func NewDegradeFsm() *DegradeFsm {
 		stateEnteredAt: make(map[AlertState]time.Time),
 	}
 
-	// 初始化状态时间记录
-	d.stateEnteredAt[AlertStateL0] = time.Now()
-
 	// 定义状态转移规则
 	d.events = fsm.Events{
 		// 降级路径
@@ -50,7 +51,7 @@ func NewDegradeFsm() *DegradeFsm {
 	d.callbacks = fsm.Callbacks{
 		"enter_state": func(_ context.Context, e *fsm.Event) {
 			newState := AlertState(e.Dst)
-			d.stateEnteredAt[newState] = time.Now()
+			d.stateEnteredAt[newState] = eventTime(e)
 			log.Printf("[DegradeFsm] Entered state %s at %v", newState, d.stateEnteredAt[newState])
 		},
 	}
@@ -69,12 +70,19 @@ func NewDegradeFsm() *DegradeFsm {
 // ts: 当前时间戳
 func (d *DegradeFsm) Transition(ctx context.Context, active bool, ts time.Time, opts *AlertOpts) (bool, error) {
 	state := AlertState(d.fsm.Current())
+	// 初始状态的进入时间以首次评估时间为准
+	if _, ok := d.stateEnteredAt[state]; !ok {
+		d.stateEnteredAt[state] = ts
+	}
 
 	log.Printf("[DegradeFsm] Transition - current: %s, active: %v, time: %v", state, active, ts.Format(time.RFC3339))
 
 	switch {
 	case active:
-		// 触发降级条件，尝试降级
+		// 触发降级条件，恢复计时清零
+		d.clearSince = time.Time{}
+		d.lastSignalAt = ts
+		// 尝试降级
 		return d.handleDegradation(ctx, state, ts, opts)
 	case !active && state != AlertStateL0:
 		// 恢复正常条件，尝试恢复
@@ -82,10 +90,70 @@ func (d *DegradeFsm) Transition(ctx context.Context, active bool, ts time.Time,
 	default:
 		// 已经是L0状态且active=false，无需处理
 		log.Printf("[DegradeFsm] Already in L0 with no degradation")
+		d.reason = "at l0, condition not met"
 		return false, nil
 	}
 }
 
+// TransitionTo 直接向目标级别转移
+// 升级立即跳转到目标级别；降级需满足恢复确认时间，之后直接恢复到目标级别
+func (d *DegradeFsm) TransitionTo(ctx context.Context, target AlertState, ts time.Time, opts *AlertOpts) (bool, error) {
+	state := AlertState(d.fsm.Current())
+	if _, ok := d.stateEnteredAt[state]; !ok {
+		d.stateEnteredAt[state] = ts
+	}
+	current, _ := state.DegradeLevel()
+	want, _ := target.DegradeLevel()
+
+	log.Printf("[DegradeFsm] TransitionTo - current: %s, target: %s, time: %v", state, target, ts.Format(time.RFC3339))
+	d.lastSignalAt = ts
+
+	switch {
+	case want > current:
+		d.clearSince = time.Time{}
+		for i := current; i < want; i++ {
+			if err := d.fsm.Event(ctx, EventTrigger, ts); err != nil {
+				log.Printf("[DegradeFsm] Degrade error: %v", err)
+				return false, err
+			}
+		}
+		d.lastSentAt = ts
+		d.reason = fmt.Sprintf("jumped to %s", target)
+		return true, nil
+	case want == current:
+		d.clearSince = time.Time{}
+		if current == 0 {
+			d.reason = "at l0, below thresholds"
+			return false, nil
+		}
+		return d.checkResend(ts, opts), nil
+	}
+
+	timeInState := ts.Sub(d.stateEnteredAt[state])
+	if opts.SustainedRecover {
+		if d.clearSince.IsZero() {
+			d.clearSince = ts
+		}
+		timeInState = ts.Sub(d.clearSince)
+	}
+	if timeInState < opts.RecoverDuration {
+		log.Printf("[DegradeFsm] Recover duration not met: %v < %v (remaining: %v)",
+			timeInState, opts.RecoverDuration, opts.RecoverDuration-timeInState)
+		d.reason = fmt.Sprintf("recover duration not met: %v/%v", timeInState, opts.RecoverDuration)
+		return false, nil
+	}
+	for i := current; i > want; i-- {
+		if err := d.fsm.Event(ctx, EventRecover, ts); err != nil {
+			log.Printf("[DegradeFsm] Recover error: %v", err)
+			return false, err
+		}
+	}
+	d.lastSentAt = ts
+	d.clearSince = ts
+	d.reason = fmt.Sprintf("recovered to %s", target)
+	return true, nil
+}
+
 // handleDegradation 处理降级逻辑
 func (d *DegradeFsm) handleDegradation(ctx context.Context, current AlertState, ts time.Time, opts *AlertOpts) (bool, error) {
 	// 检查是否已经处于最高级降级
@@ -97,19 +165,25 @@ func (d *DegradeFsm) handleDegradation(ctx context.Context, current AlertState,
 	// 检查是否满足降级确认时间
 	timeInState := ts.Sub(d.stateEnteredAt[current])
 	if timeInState < opts.HoldDuration {
+		// 当前级别单独配置了重发间隔时，等待升级期间按该间隔提醒
+		if _, ok := opts.LevelResendDelay[current]; ok && current != AlertStateL0 {
+			return d.checkResend(ts, opts), nil
+		}
 		log.Printf("[DegradeFsm] Hold duration not met: %v < %v (remaining: %v)",
 			timeInState, opts.HoldDuration, opts.HoldDuration-timeInState)
+		d.reason = fmt.Sprintf("hold duration not met: %v/%v", timeInState, opts.HoldDuration)
 		return false, nil
 	}
 
 	// 执行降级
-	if err := d.fsm.Event(ctx, EventTrigger); err != nil {
+	if err := d.fsm.Event(ctx, EventTrigger, ts); err != nil {
 		log.Printf("[DegradeFsm] Degrade error: %v", err)
 		return false, err
 	}
 
 	d.lastSentAt = ts
 	log.Printf("[DegradeFsm] lastSentAt: %v", d.lastSentAt.Format(time.RFC3339))
+	d.reason = fmt.Sprintf("hold duration met, degraded to %s", d.State())
 	return true, nil
 }
 
@@ -118,55 +192,121 @@ func (d *DegradeFsm) handleRecovery(ctx context.Context, current AlertState, ts
 	// 检查自动恢复条件
 	if opts.AutoRecoverAfter > 0 {
 		timeInState := ts.Sub(d.stateEnteredAt[current])
+		if opts.RecoverWithoutSignal && !d.lastSignalAt.IsZero() {
+			// 按无信号的持续时间计算
+			timeInState = ts.Sub(d.lastSignalAt)
+		}
 		if timeInState >= opts.AutoRecoverAfter {
 			log.Printf("[DegradeFsm] Auto-recover duration (%v) met, resolving to L0", opts.AutoRecoverAfter)
-			if err := d.fsm.Event(ctx, EventResolve); err != nil {
+			if err := d.fsm.Event(ctx, EventResolve, ts); err != nil {
 				log.Printf("[DegradeFsm] Resolve error: %v", err)
 				return false, err
 			}
 			d.lastSentAt = ts
 			log.Printf("[DegradeFsm] Auto-resolved to L0")
+			d.reason = "auto-recover duration met, resolved to l0"
 			return true, nil
 		}
 	}
 
 	// 检查恢复确认时间
 	timeInState := ts.Sub(d.stateEnteredAt[current])
+	if opts.SustainedRecover {
+		if d.clearSince.IsZero() {
+			d.clearSince = ts
+		}
+		timeInState = ts.Sub(d.clearSince)
+	}
 	if timeInState < opts.RecoverDuration {
 		log.Printf("[DegradeFsm] Recover duration not met: %v < %v (remaining: %v)",
 			timeInState, opts.RecoverDuration, opts.RecoverDuration-timeInState)
+		d.reason = fmt.Sprintf("recover duration not met: %v/%v", timeInState, opts.RecoverDuration)
 		return false, nil
 	}
 
 	// 执行恢复
-	if err := d.fsm.Event(ctx, EventRecover); err != nil {
+	if err := d.fsm.Event(ctx, EventRecover, ts); err != nil {
 		log.Printf("[DegradeFsm] Recover error: %v", err)
 		return false, err
 	}
 
 	d.lastSentAt = ts
+	// 逐级恢复，下一级需重新满足持续恢复时间
+	d.clearSince = ts
 	log.Printf("[DegradeFsm] Recovered, lastSentAt: %v", d.lastSentAt.Format(time.RFC3339))
+	d.reason = fmt.Sprintf("recover duration met, recovered to %s", d.State())
 	return true, nil
 }
 
 // checkResend 检查是否需要重发通知
 func (d *DegradeFsm) checkResend(ts time.Time, opts *AlertOpts) bool {
-	if opts.ResendDelay == 0 {
+	delay := opts.resendDelay(d.State())
+	if delay == 0 {
+		d.reason = fmt.Sprintf("at %s, resend disabled", d.State())
 		return false
 	}
 
 	elapsed := ts.Sub(d.lastSentAt)
-	if elapsed >= opts.ResendDelay {
+	if elapsed >= delay {
 		d.lastSentAt = ts
-		log.Printf("[DegradeFsm] Resend delay (%v) met, resending notification", opts.ResendDelay)
+		log.Printf("[DegradeFsm] Resend delay (%v) met, resending notification", delay)
+		d.reason = fmt.Sprintf("at %s, resend delay met, resent", d.State())
 		return true
 	}
 
 	log.Printf("[DegradeFsm] Resend delay not met: %v/%v (remaining: %v)",
-		elapsed, opts.ResendDelay, opts.ResendDelay-elapsed)
+		elapsed, delay, delay-elapsed)
+	d.reason = fmt.Sprintf("at %s, resend delay not met: %v/%v", d.State(), elapsed, delay)
 	return false
 }
 
+// Resolve 强制恢复到 L0
+func (d *DegradeFsm) Resolve(ctx context.Context, ts time.Time) (bool, error) {
+	if d.State() == AlertStateL0 {
+		return false, nil
+	}
+	if err := d.fsm.Event(ctx, EventResolve, ts); err != nil {
+		log.Printf("[DegradeFsm] Resolve error: %v", err)
+		return false, err
+	}
+	d.lastSentAt = ts
+	d.clearSince = time.Time{}
+	log.Printf("[DegradeFsm] Force resolved to L0")
+	d.reason = "force resolved"
+	return true, nil
+}
+
+// TimeToResend 距离下一次通知的剩余时间
+// L3 时为重发间隔剩余时间，L1/L2 时为持续触发下升级到下一级的剩余时间，
+// L1/L2 配置了 LevelResendDelay 时取两者中较早的一个
+func (d *DegradeFsm) TimeToResend(now time.Time, opts *AlertOpts) time.Duration {
+	var remaining time.Duration
+	switch state := d.State(); state {
+	case AlertStateL0:
+		return 0
+	case AlertStateL3:
+		delay := opts.resendDelay(state)
+		if delay == 0 {
+			return 0
+		}
+		remaining = delay - now.Sub(d.lastSentAt)
+	default:
+		remaining = opts.HoldDuration - now.Sub(d.stateEnteredAt[state])
+		if delay, ok := opts.LevelResendDelay[state]; ok && delay > 0 {
+			remaining = min(remaining, delay-now.Sub(d.lastSentAt))
+		}
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Reason 最近一次决策的原因
+func (d *DegradeFsm) Reason() string {
+	return d.reason
+}
+
 // CurrentState 获取当前状态
 func (d *DegradeFsm) State() AlertState {
 	return AlertState(d.fsm.Current())
@@ -178,13 +318,34 @@ func (d *DegradeFsm) Snapshot() AlertSnapshot {
 		State:          string(d.State()),
 		StateEnteredAt: d.stateEnteredAt,
 		LastSentAt:     d.lastSentAt,
+		ClearSince:     d.clearSince,
+		LastSignalAt:   d.lastSignalAt,
 	}
 }
 
+// Reset 重置为 L0 并清空计时
+func (d *DegradeFsm) Reset() {
+	d.stateEnteredAt = make(map[AlertState]time.Time)
+	d.lastSentAt = time.Time{}
+	d.clearSince = time.Time{}
+	d.lastSignalAt = time.Time{}
+	d.reason = "reset"
+	d.fsm = fsm.NewFSM(
+		string(AlertStateL0),
+		d.events,
+		d.callbacks,
+	)
+}
+
 // Restore 恢复状态
 func (d *DegradeFsm) Restore(snap AlertSnapshot) error {
 	d.stateEnteredAt = snap.StateEnteredAt
+	if d.stateEnteredAt == nil {
+		d.stateEnteredAt = make(map[AlertState]time.Time)
+	}
 	d.lastSentAt = snap.LastSentAt
+	d.clearSince = snap.ClearSince
+	d.lastSignalAt = snap.LastSignalAt
 
 	d.fsm = fsm.NewFSM(
 		snap.State,
@@ -0,0 +1,177 @@
+package alertmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newDegradeFsmAt(t *testing.T, state AlertState, enteredAt time.Time) *DegradeFsm {
+	d := NewDegradeFsm()
+	require.NoError(t, d.Restore(AlertSnapshot{
+		State:          string(state),
+		StateEnteredAt: map[AlertState]time.Time{state: enteredAt},
+	}))
+	return d
+}
+
+func TestDegradeFsm_SustainedRecover(t *testing.T) {
+	opts := &AlertOpts{
+		HoldDuration:     time.Hour,
+		RecoverDuration:  3 * time.Minute,
+		SustainedRecover: true,
+	}
+	t0 := time.Now()
+	d := newDegradeFsmAt(t, AlertStateL1, t0)
+
+	// 间歇性触发不断重置恢复计时
+	for i, active := range []bool{false, true, false, true, false} {
+		sent, err := d.Transition(context.Background(), active, t0.Add(time.Duration(i+1)*time.Minute), opts)
+		require.NoError(t, err)
+		require.False(t, sent)
+	}
+	sent, err := d.Transition(context.Background(), false, t0.Add(7*time.Minute), opts)
+	require.NoError(t, err)
+	require.False(t, sent, "recovery window restarted at 5m, only 2m clear")
+	require.Equal(t, AlertStateL1, d.State())
+
+	sent, err = d.Transition(context.Background(), false, t0.Add(8*time.Minute), opts)
+	require.NoError(t, err)
+	require.True(t, sent)
+	require.Equal(t, AlertStateL0, d.State())
+}
+
+func TestDegradeFsm_RecoverWithoutSustained(t *testing.T) {
+	opts := &AlertOpts{
+		HoldDuration:    time.Hour,
+		RecoverDuration: 3 * time.Minute,
+	}
+	t0 := time.Now()
+	d := newDegradeFsmAt(t, AlertStateL1, t0)
+
+	for i, active := range []bool{false, true} {
+		_, err := d.Transition(context.Background(), active, t0.Add(time.Duration(i+1)*time.Minute), opts)
+		require.NoError(t, err)
+	}
+	sent, err := d.Transition(context.Background(), false, t0.Add(3*time.Minute), opts)
+	require.NoError(t, err)
+	require.True(t, sent, "time in state alone satisfies recovery")
+	require.Equal(t, AlertStateL0, d.State())
+}
+
+func TestDegradeFsm_TransitionTo(t *testing.T) {
+	opts := &AlertOpts{HoldDuration: time.Hour, RecoverDuration: time.Minute}
+	t0 := time.Now()
+	d := newDegradeFsmAt(t, AlertStateL0, t0)
+
+	sent, err := d.TransitionTo(context.Background(), AlertStateL3, t0, opts)
+	require.NoError(t, err)
+	require.True(t, sent)
+	require.Equal(t, AlertStateL3, d.State())
+	require.Equal(t, t0, d.Snapshot().StateEnteredAt[AlertStateL3])
+
+	sent, err = d.TransitionTo(context.Background(), AlertStateL3, t0.Add(time.Second), opts)
+	require.NoError(t, err)
+	require.False(t, sent, "staying at the target level without ResendDelay sends nothing")
+
+	sent, err = d.TransitionTo(context.Background(), AlertStateL0, t0.Add(30*time.Second), opts)
+	require.NoError(t, err)
+	require.False(t, sent)
+	require.Equal(t, AlertStateL3, d.State())
+
+	sent, err = d.TransitionTo(context.Background(), AlertStateL0, t0.Add(time.Minute), opts)
+	require.NoError(t, err)
+	require.True(t, sent)
+	require.Equal(t, AlertStateL0, d.State())
+}
+
+func TestDegradeFsm_Reset(t *testing.T) {
+	opts := &AlertOpts{HoldDuration: time.Minute, ResendDelay: time.Minute}
+	t0 := time.Now()
+	d := newDegradeFsmAt(t, AlertStateL2, t0)
+	_, err := d.Transition(context.Background(), true, t0.Add(time.Minute), opts)
+	require.NoError(t, err)
+	require.Equal(t, AlertStateL3, d.State())
+
+	d.Reset()
+	require.Equal(t, AlertStateL0, d.State())
+	require.Equal(t, "reset", d.Reason())
+	require.Empty(t, DiffSnapshots(AlertSnapshot{State: string(AlertStateL0), StateEnteredAt: map[AlertState]time.Time{}}, d.Snapshot()))
+
+	// 重置后按新的时间重新计算停留时长，逐级降级
+	t1 := t0.Add(time.Hour)
+	sent, err := d.Transition(context.Background(), true, t1, opts)
+	require.NoError(t, err)
+	require.False(t, sent, "hold restarts from the first evaluation after reset")
+	require.Equal(t, AlertStateL0, d.State())
+
+	sent, err = d.Transition(context.Background(), true, t1.Add(time.Minute), opts)
+	require.NoError(t, err)
+	require.True(t, sent)
+	require.Equal(t, AlertStateL1, d.State())
+}
+
+func TestDegradeFsm_RestoreWithoutStateEnteredAt(t *testing.T) {
+	opts := &AlertOpts{HoldDuration: time.Minute}
+	t0 := time.Now()
+
+	d := NewDegradeFsm()
+	require.NoError(t, d.Restore(AlertSnapshot{}))
+	require.NotPanics(t, func() {
+		_, _ = d.Transition(context.Background(), true, t0, opts)
+	})
+
+	d = NewDegradeFsm()
+	require.NoError(t, d.Restore(AlertSnapshot{State: string(AlertStateL0)}))
+	sent, err := d.Transition(context.Background(), true, t0, opts)
+	require.NoError(t, err)
+	require.False(t, sent)
+	sent, err = d.Transition(context.Background(), true, t0.Add(time.Minute), opts)
+	require.NoError(t, err)
+	require.True(t, sent)
+	require.Equal(t, AlertStateL1, d.State())
+}
+
+func TestDegradeFsm_LevelResendDelayLadder(t *testing.T) {
+	opts := &AlertOpts{
+		HoldDuration:     55 * time.Minute,
+		LevelResendDelay: map[AlertState]time.Duration{AlertStateL1: 15 * time.Minute},
+	}
+	t0 := time.Now()
+	d := NewDegradeFsm()
+	require.NoError(t, d.Restore(AlertSnapshot{
+		State:          string(AlertStateL1),
+		StateEnteredAt: map[AlertState]time.Time{AlertStateL1: t0},
+		LastSentAt:     t0,
+	}))
+
+	// 等待升级期间按 L1 的重发间隔提醒
+	require.Equal(t, 10*time.Minute, d.TimeToResend(t0.Add(5*time.Minute), opts))
+	sent, err := d.Transition(context.Background(), true, t0.Add(5*time.Minute), opts)
+	require.NoError(t, err)
+	require.False(t, sent)
+	sent, err = d.Transition(context.Background(), true, t0.Add(15*time.Minute), opts)
+	require.NoError(t, err)
+	require.True(t, sent)
+	require.Equal(t, AlertStateL1, d.State())
+	require.Equal(t, 15*time.Minute, d.TimeToResend(t0.Add(15*time.Minute), opts))
+
+	sent, err = d.Transition(context.Background(), true, t0.Add(45*time.Minute), opts)
+	require.NoError(t, err)
+	require.True(t, sent)
+
+	// 升级时间早于下一次重发
+	require.Equal(t, 5*time.Minute, d.TimeToResend(t0.Add(50*time.Minute), opts))
+	sent, err = d.Transition(context.Background(), true, t0.Add(55*time.Minute), opts)
+	require.NoError(t, err)
+	require.True(t, sent)
+	require.Equal(t, AlertStateL2, d.State())
+
+	// L2 未配置单独的重发间隔，仅在升级时通知
+	require.Equal(t, 55*time.Minute, d.TimeToResend(t0.Add(55*time.Minute), opts))
+	sent, err = d.Transition(context.Background(), true, t0.Add(80*time.Minute), opts)
+	require.NoError(t, err)
+	require.False(t, sent)
+}
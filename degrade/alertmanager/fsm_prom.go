@@ -2,6 +2,7 @@ package alertmanager
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -15,6 +16,8 @@ type PromAlertFsm struct {
 	activeAt   time.Time
 	firedAt    time.Time
 	lastSentAt time.Time
+	clearSince time.Time // KeepFiringExtend 模式下条件消失的起始时间
+	reason     string    // 最近一次决策的原因
 
 	events    fsm.Events
 	callbacks fsm.Callbacks
@@ -28,11 +31,12 @@ func NewPromAlertFsm() *PromAlertFsm {
 		{Name: EventResolve, Src: []string{string(AlertStatePending), string(AlertStateFiring)}, Dst: string(AlertStateInactive)},
 	}
 	a.callbacks = fsm.Callbacks{
-		"enter_pending": func(_ context.Context, e *fsm.Event) { a.activeAt = time.Now() },
-		"enter_firing":  func(_ context.Context, e *fsm.Event) { a.firedAt = time.Now() },
+		"enter_pending": func(_ context.Context, e *fsm.Event) { a.activeAt = eventTime(e) },
+		"enter_firing":  func(_ context.Context, e *fsm.Event) { a.firedAt = eventTime(e) },
 		"enter_inactive": func(_ context.Context, e *fsm.Event) {
 			if e.Src == string(AlertStateFiring) {
 				a.firedAt = time.Time{}
+				a.clearSince = time.Time{}
 			}
 		},
 	}
@@ -48,6 +52,36 @@ func (a *PromAlertFsm) State() AlertState {
 	return AlertState(a.fsm.Current())
 }
 
+func (a *PromAlertFsm) Reason() string {
+	return a.reason
+}
+
+// Warn 仅满足预警条件：进入或保持 pending，已触发的告警恢复
+// pending 的起始时间随预警刷新，告警条件出现后重新计算 HoldDuration
+func (a *PromAlertFsm) Warn(ctx context.Context, ts time.Time) (bool, error) {
+	switch AlertState(a.fsm.Current()) {
+	case AlertStateInactive:
+		if err := a.fsm.Event(ctx, EventTrigger, ts); err != nil {
+			log.Printf("[StateMachine] Error entering warn pending: %v", err)
+			return false, err
+		}
+		a.reason = "warn condition met, pending"
+		return false, nil
+	case AlertStatePending:
+		a.activeAt = ts
+		a.reason = "warn condition met, pending"
+		return false, nil
+	default:
+		log.Printf("[StateMachine] Critical condition cleared, resolving to warn")
+		if err := a.fsm.Event(ctx, EventResolve, ts); err != nil {
+			log.Printf("[StateMachine] Error resolving alert: %v", err)
+			return false, err
+		}
+		a.reason = "critical condition cleared, resolved"
+		return true, nil
+	}
+}
+
 func (a *PromAlertFsm) Transition(ctx context.Context, active bool, ts time.Time, opts *AlertOpts) (bool, error) {
 	current := AlertState(a.fsm.Current())
 
@@ -57,31 +91,48 @@ func (a *PromAlertFsm) Transition(ctx context.Context, active bool, ts time.Time
 
 	switch {
 	case !active && current != AlertStateInactive:
+		reason := "condition cleared, resolved"
+		if current == AlertStateFiring && opts.KeepFiringMode == KeepFiringExtend && opts.KeepFiringFor > 0 {
+			if a.clearSince.IsZero() {
+				a.clearSince = ts
+			}
+			duration := ts.Sub(a.clearSince)
+			if duration < opts.KeepFiringFor {
+				log.Printf("[StateMachine] Condition cleared, keep firing: %v/%v (remaining: %v)",
+					duration, opts.KeepFiringFor, opts.KeepFiringFor-duration)
+				a.reason = fmt.Sprintf("condition cleared, keep firing: %v/%v", duration, opts.KeepFiringFor)
+				return false, nil
+			}
+			reason = "keep firing duration met, resolved"
+		}
 		log.Printf("[StateMachine] Resolving alert from state %s", current)
-		if err := a.fsm.Event(ctx, EventResolve); err != nil {
+		if err := a.fsm.Event(ctx, EventResolve, ts); err != nil {
 			log.Printf("[StateMachine] Error resolving alert: %v", err)
 			return false, err
 		}
 		log.Printf("[StateMachine] Successfully resolved to inactive state")
+		a.reason = reason
 		return true, nil
 
 	case active && current == AlertStateInactive:
 		if opts.HoldDuration == 0 {
 			log.Printf("[StateMachine] Immediate firing (hold=0)")
-			if err := a.fsm.Event(ctx, EventFire); err != nil {
+			if err := a.fsm.Event(ctx, EventFire, ts); err != nil {
 				log.Printf("[StateMachine] Error firing alert: %v", err)
 				return false, err
 			}
 			a.lastSentAt = ts
 			log.Printf("[StateMachine] Successfully fired immediately")
+			a.reason = "fired immediately (no hold duration)"
 			return true, nil
 		}
 		log.Printf("[StateMachine] Triggering alert (will enter pending state)")
-		if err := a.fsm.Event(ctx, EventTrigger); err != nil {
+		if err := a.fsm.Event(ctx, EventTrigger, ts); err != nil {
 			log.Printf("[StateMachine] Error triggering alert: %v", err)
 			return false, err
 		}
 		log.Printf("[StateMachine] Successfully triggered (now pending)")
+		a.reason = fmt.Sprintf("hold duration not met: 0s/%v", opts.HoldDuration)
 		return false, nil
 
 	case active && current == AlertStatePending:
@@ -89,27 +140,32 @@ func (a *PromAlertFsm) Transition(ctx context.Context, active bool, ts time.Time
 		if duration < opts.HoldDuration {
 			log.Printf("[StateMachine] Hold duration not met: %v < %v (remaining: %v)",
 				duration, opts.HoldDuration, opts.HoldDuration-duration)
+			a.reason = fmt.Sprintf("hold duration not met: %v/%v", duration, opts.HoldDuration)
 			return false, nil
 		}
 		log.Printf("[StateMachine] Hold duration met, firing alert")
-		if err := a.fsm.Event(ctx, EventFire); err != nil {
+		if err := a.fsm.Event(ctx, EventFire, ts); err != nil {
 			log.Printf("[StateMachine] Error firing from pending state: %v", err)
 			return false, err
 		}
 		a.lastSentAt = ts
 		log.Printf("[StateMachine] Successfully fired (now firing), lastSentAt: %v", a.lastSentAt.Format(time.RFC3339))
+		a.reason = "hold duration met, fired"
 		return true, nil
 
 	case active && current == AlertStateFiring:
-		if opts.KeepFiringFor > 0 {
+		// 条件重新满足，保持触发的计时清零
+		a.clearSince = time.Time{}
+		if opts.KeepFiringFor > 0 && opts.KeepFiringMode != KeepFiringExtend {
 			duration := ts.Sub(a.firedAt)
 			if duration >= opts.KeepFiringFor {
 				log.Printf("[StateMachine] KeepFiring duration (%v) met, auto-resolving alert", opts.KeepFiringFor)
-				if err := a.fsm.Event(ctx, EventResolve); err != nil {
+				if err := a.fsm.Event(ctx, EventResolve, ts); err != nil {
 					log.Printf("[StateMachine] Error auto-resolving alert: %v", err)
 					return false, err
 				}
 				log.Printf("[StateMachine] Successfully auto-resolved (now inactive)")
+				a.reason = "keep firing duration met, resolved"
 				return true, nil
 			}
 			log.Printf("[StateMachine] KeepFiring duration not met: %v/%v (remaining: %v)",
@@ -121,22 +177,62 @@ func (a *PromAlertFsm) Transition(ctx context.Context, active bool, ts time.Time
 			log.Printf("[StateMachine] Resend delay (%v) met, resending notification", opts.ResendDelay)
 			a.lastSentAt = ts
 			log.Printf("[StateMachine] Notification resent, lastSentAt updated to: %v", a.lastSentAt.Format(time.RFC3339))
+			a.reason = "resend delay met, resent"
 			return true, nil
 		}
 		log.Printf("[StateMachine] Resend delay not met: %v/%v (remaining: %v)",
 			duration, opts.ResendDelay, opts.ResendDelay-duration)
+		a.reason = "firing, resend disabled"
+		if opts.ResendDelay > 0 {
+			a.reason = fmt.Sprintf("firing, resend delay not met: %v/%v", duration, opts.ResendDelay)
+		}
+		return false, nil
 	}
 
 	log.Printf("[StateMachine] No state transition occurred")
+	a.reason = "condition not met"
 	return false, nil
 }
 
+func (a *PromAlertFsm) Resolve(ctx context.Context, ts time.Time) (bool, error) {
+	current := AlertState(a.fsm.Current())
+	if current == AlertStateInactive {
+		return false, nil
+	}
+	log.Printf("[StateMachine] Force resolving alert from state %s", current)
+	if err := a.fsm.Event(ctx, EventResolve, ts); err != nil {
+		log.Printf("[StateMachine] Error resolving alert: %v", err)
+		return false, err
+	}
+	a.reason = "force resolved"
+	// pending 状态未发送过通知，无需发送恢复通知
+	return current == AlertStateFiring, nil
+}
+
+func (a *PromAlertFsm) TimeToResend(now time.Time, opts *AlertOpts) time.Duration {
+	var remaining time.Duration
+	switch AlertState(a.fsm.Current()) {
+	case AlertStatePending:
+		remaining = opts.HoldDuration - now.Sub(a.activeAt)
+	case AlertStateFiring:
+		if opts.ResendDelay == 0 {
+			return 0
+		}
+		remaining = opts.ResendDelay - now.Sub(a.lastSentAt)
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
 func (a *PromAlertFsm) Snapshot() AlertSnapshot {
 	return AlertSnapshot{
 		State:      a.fsm.Current(),
 		ActiveAt:   a.activeAt,
 		FiredAt:    a.firedAt,
 		LastSentAt: a.lastSentAt,
+		ClearSince: a.clearSince,
 	}
 }
 
@@ -144,6 +240,7 @@ func (a *PromAlertFsm) Restore(snap AlertSnapshot) error {
 	a.activeAt = snap.ActiveAt
 	a.firedAt = snap.FiredAt
 	a.lastSentAt = snap.LastSentAt
+	a.clearSince = snap.ClearSince
 	// FSM需要重建以保证状态一致性
 	a.fsm = fsm.NewFSM(
 		snap.State,
@@ -152,3 +249,16 @@ func (a *PromAlertFsm) Restore(snap AlertSnapshot) error {
 	)
 	return nil
 }
+
+func (a *PromAlertFsm) Reset() {
+	a.activeAt = time.Time{}
+	a.firedAt = time.Time{}
+	a.lastSentAt = time.Time{}
+	a.clearSince = time.Time{}
+	a.reason = "reset"
+	a.fsm = fsm.NewFSM(
+		string(AlertStateInactive),
+		a.events,
+		a.callbacks,
+	)
+}
@@ -2,12 +2,14 @@ package alertmanager
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"log"
+	"runtime/debug"
 	"sync"
 	"time"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql"
 )
 
@@ -21,8 +23,33 @@ type AlertManager struct {
 	notifier Notifier
 	storage  Storage
 	stop     chan struct{}
-	wg       sync.WaitGroup
+	wg       sync.WaitGroup // 主循环
+	evalWg   sync.WaitGroup // 进行中的规则评估
 	mtx      sync.RWMutex
+	events   eventBroker
+
+	// OnEvalError 规则评估失败时的回调，在规则各自的评估协程中调用；
+	// 评估或发送通知时 panic 也会回调，err 包装 ErrEvalPanic
+	OnEvalError func(rule *Rule, err error)
+
+	// Clock 评估周期和评估时间的来源，为空时使用系统时间，需在 Run 之前设置
+	Clock Clock
+	// AlignEvalTimestamp 评估时间向下对齐到评估周期的整数倍，使查询时间戳稳定可复现
+	AlignEvalTimestamp bool
+	// RuleSelector 只评估 Labels 满足全部匹配器的规则，用于多副本分片评估，为空时评估所有规则
+	RuleSelector []*labels.Matcher
+	// MaxNotificationsPerCycle 每轮评估最多发送的通知数，超出的通知按先进先出顺延到后续轮次，
+	// 并额外发送一条 BudgetAlertName 元告警；<=0 表示不限制。Stop 时仍未发送的通知被丢弃
+	MaxNotificationsPerCycle int
+	// MaxDeferredNotifications 顺延队列上限，超出时丢弃最早的通知并计入元告警；
+	// <=0 时为 MaxNotificationsPerCycle 的 10 倍
+	MaxDeferredNotifications int
+
+	dispatchMtx     sync.Mutex
+	deferred        []*Notification // 超出预算、等待后续轮次发送的通知
+	droppedDeferred int             // 上一条元告警以来因队列已满丢弃的通知数
+	cycleLeft       int             // 本轮剩余的通知配额
+	budgetHit       bool            // 本轮是否已发送预算元告警
 }
 
 // NewAlertManager 创建新的AlertManager实例
@@ -50,9 +77,10 @@ func (am *AlertManager) Run() error {
 		return fmt.Errorf("failed to restore alerts: %v", err)
 	}
 
-	// 启动主循环
+	// 启动主循环，ticker 在返回前创建，保证 Run 之后推进的时钟不会丢失 tick
+	ticker := am.clock().NewTicker(am.interval)
 	am.wg.Add(1)
-	go am.loop()
+	go am.loop(ticker)
 
 	log.Println("AlertManager started")
 	return nil
@@ -62,6 +90,14 @@ func (am *AlertManager) Run() error {
 func (am *AlertManager) Stop() {
 	close(am.stop)
 	am.wg.Wait()
+	// 主循环退出后不会再有新的评估，等待进行中的评估完成
+	am.evalWg.Wait()
+
+	am.dispatchMtx.Lock()
+	if len(am.deferred) > 0 {
+		log.Printf("Dropping %d deferred notifications", len(am.deferred))
+	}
+	am.dispatchMtx.Unlock()
 
 	// 保存当前告警状态
 	if err := am.saveAlerts(); err != nil {
@@ -71,16 +107,22 @@ func (am *AlertManager) Stop() {
 	log.Println("AlertManager stopped")
 }
 
+// clock 返回生效的时间来源
+func (am *AlertManager) clock() Clock {
+	if am.Clock != nil {
+		return am.Clock
+	}
+	return realClock{}
+}
+
 // loop 主循环
-func (am *AlertManager) loop() {
+func (am *AlertManager) loop(ticker Ticker) {
 	defer am.wg.Done()
-
-	ticker := time.NewTicker(am.interval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			am.evaluateAllRules()
 		case <-am.stop:
 			return
@@ -93,19 +135,53 @@ func (am *AlertManager) evaluateAllRules() {
 	am.mtx.RLock()
 	defer am.mtx.RUnlock()
 
-	now := time.Now()
+	now := am.clock().Now()
+	if am.AlignEvalTimestamp {
+		now = now.Truncate(am.interval)
+	}
+	var deferredSent <-chan struct{}
+	if am.MaxNotificationsPerCycle > 0 {
+		deferredSent = am.dispatchDeferred(am.startCycle())
+	}
 
 	for _, rule := range am.rules {
-		am.wg.Add(1)
+		if !am.selected(rule) {
+			continue
+		}
+		// 上一次评估尚未结束时跳过本轮，避免同一规则并发评估
+		if !rule.evaluating.CompareAndSwap(false, true) {
+			log.Printf("Skipping rule %s: previous evaluation still in progress", rule.Name)
+			continue
+		}
+		am.evalWg.Add(1)
 		go func(r *Rule) {
-			defer am.wg.Done()
+			defer am.evalWg.Done()
+			defer r.evaluating.Store(false)
+			// 单条规则的 panic（如自定义 QueryFunc、Notifier 的缺陷）不影响其他规则和管理器
+			defer func() {
+				if p := recover(); p != nil {
+					log.Printf("Panic evaluating rule %s: %v\n%s", r.Name, p, debug.Stack())
+					if am.OnEvalError != nil {
+						am.OnEvalError(r, fmt.Errorf("%w: %v", ErrEvalPanic, p))
+					}
+				}
+			}()
 
 			ctx, cancel := context.WithTimeout(context.Background(), am.interval)
 			defer cancel()
 
+			var before map[uint64]alertState
+			if am.events.hasSubscribers() {
+				before = r.alertStates()
+				defer func() { am.publishChanges(r, before, now) }()
+			}
+
 			firingAlerts, err := r.Eval(ctx, now, am.queryFn)
 			if err != nil {
 				log.Printf("Error evaluating rule %s: %v", r.Name, err)
+				if am.OnEvalError != nil {
+					am.OnEvalError(r, err)
+				}
 				return
 			}
 			if len(firingAlerts) > 0 {
@@ -113,6 +189,15 @@ func (am *AlertManager) evaluateAllRules() {
 				for _, alert := range firingAlerts {
 					notifications = append(notifications, NewNotification(r, alert))
 				}
+				SortNotifications(notifications)
+				notifications = am.admit(notifications, now)
+				if len(notifications) == 0 {
+					return
+				}
+				// 上一轮顺延的通知先于本轮的通知送达
+				if deferredSent != nil {
+					<-deferredSent
+				}
 				if err := am.notifier.Notify(context.Background(), notifications); err != nil {
 					log.Printf("Error sending alerts for rule %s: %v", r.Name, err)
 				}
@@ -121,6 +206,16 @@ func (am *AlertManager) evaluateAllRules() {
 	}
 }
 
+// selected 判断规则是否属于本实例评估的分片
+func (am *AlertManager) selected(rule *Rule) bool {
+	for _, m := range am.RuleSelector {
+		if !m.Matches(rule.Labels.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
 // restoreAlerts 从存储恢复告警状态
 func (am *AlertManager) restoreAlerts() error {
 	am.mtx.Lock()
@@ -131,8 +226,18 @@ func (am *AlertManager) restoreAlerts() error {
 			return fmt.Errorf("failed to load alerts for rule %s: %v", rule.Name, err)
 		}
 		rule.active = make(map[uint64]IAlert)
+		rule.resolvedAt = nil
+		rule.quietUntil = nil
+		if rule.SuppressInitialOnRestart > 0 && len(alerts) > 0 {
+			rule.quietUntil = make(map[uint64]time.Time, len(alerts))
+		}
+		until := am.clock().Now().Add(rule.SuppressInitialOnRestart)
 		for _, alert := range alerts {
-			rule.active[alert.Labels().Hash()] = alert
+			fp := rule.fingerprint(alert.Labels())
+			rule.active[fp] = alert
+			if rule.quietUntil != nil {
+				rule.quietUntil[fp] = until
+			}
 		}
 	}
 	return nil
@@ -144,9 +249,15 @@ func (am *AlertManager) saveAlerts() error {
 	defer am.mtx.RUnlock()
 	for _, rule := range am.rules {
 		var alerts []IAlert
-		for _, alert := range rule.active {
+		rule.mtx.RLock()
+		for fp, alert := range rule.active {
+			// 保留中的已恢复告警不落盘，恢复后无法再计时移除
+			if _, resolved := rule.resolvedAt[fp]; resolved {
+				continue
+			}
 			alerts = append(alerts, alert)
 		}
+		rule.mtx.RUnlock()
 		if err := am.storage.SaveAlerts(rule, alerts); err != nil {
 			return fmt.Errorf("failed to save alerts for rule %s: %v", rule.Name, err)
 		}
@@ -154,6 +265,101 @@ func (am *AlertManager) saveAlerts() error {
 	return nil
 }
 
+// DegradeLevels 返回所有多级降级告警的当前级别（0-3），key 为告警标签字符串
+func (am *AlertManager) DegradeLevels() map[string]int {
+	am.mtx.RLock()
+	defer am.mtx.RUnlock()
+
+	levels := make(map[string]int)
+	for _, rule := range am.rules {
+		rule.mtx.RLock()
+		for _, alert := range rule.active {
+			if level, ok := AlertState(alert.Snapshot().State).DegradeLevel(); ok {
+				levels[alert.Labels().String()] = level
+			}
+		}
+		rule.mtx.RUnlock()
+	}
+	return levels
+}
+
+// StateSchemaVersion 导出状态的格式版本
+const StateSchemaVersion = 1
+
+// stateEnvelope 导出状态的外层结构，按规则名组织告警
+type stateEnvelope struct {
+	Version int                          `json:"version"`
+	Rules   map[string][]json.RawMessage `json:"rules"`
+}
+
+// ExportState 导出所有规则的告警状态
+func (am *AlertManager) ExportState() ([]byte, error) {
+	am.mtx.RLock()
+	defer am.mtx.RUnlock()
+
+	env := stateEnvelope{
+		Version: StateSchemaVersion,
+		Rules:   make(map[string][]json.RawMessage, len(am.rules)),
+	}
+	for _, rule := range am.rules {
+		rule.mtx.RLock()
+		alerts := make([]json.RawMessage, 0, len(rule.active))
+		for fp, alert := range rule.active {
+			if _, resolved := rule.resolvedAt[fp]; resolved {
+				continue
+			}
+			data, err := alert.Marshal()
+			if err != nil {
+				rule.mtx.RUnlock()
+				return nil, fmt.Errorf("failed to marshal alert for rule %s: %v", rule.Name, err)
+			}
+			alerts = append(alerts, data)
+		}
+		rule.mtx.RUnlock()
+		env.Rules[rule.Name] = alerts
+	}
+	return json.Marshal(env)
+}
+
+// ImportState 导入 ExportState 导出的状态，覆盖当前所有规则的告警
+func (am *AlertManager) ImportState(data []byte) error {
+	var env stateEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return fmt.Errorf("failed to unmarshal state: %v", err)
+	}
+	if env.Version > StateSchemaVersion {
+		return fmt.Errorf("unsupported state version %d (max %d)", env.Version, StateSchemaVersion)
+	}
+
+	am.mtx.RLock()
+	defer am.mtx.RUnlock()
+
+	// 先全部解析，避免导入一半失败
+	restored := make(map[*Rule]map[uint64]IAlert, len(am.rules))
+	for _, rule := range am.rules {
+		active := make(map[uint64]IAlert)
+		for _, raw := range env.Rules[rule.Name] {
+			alert, err := NewAlert(rule.AlertType, labels.EmptyLabels(), rule.AlertOpts)
+			if err != nil {
+				return err
+			}
+			if err := alert.Restore(raw, rule.AlertOpts); err != nil {
+				return fmt.Errorf("failed to restore alert for rule %s: %v", rule.Name, err)
+			}
+			active[rule.fingerprint(alert.Labels())] = alert
+		}
+		restored[rule] = active
+	}
+
+	for rule, active := range restored {
+		rule.mtx.Lock()
+		rule.active = active
+		rule.resolvedAt = nil
+		rule.mtx.Unlock()
+	}
+	return nil
+}
+
 // AddRule 添加新规则
 func (am *AlertManager) AddRule(rule *Rule) error {
 	am.mtx.Lock()
@@ -162,7 +368,7 @@ func (am *AlertManager) AddRule(rule *Rule) error {
 	// 检查规则是否已存在
 	for _, r := range am.rules {
 		if r.Name == rule.Name {
-			return errors.New("rule already exists")
+			return fmt.Errorf("%w: %s", ErrRuleExists, rule.Name)
 		}
 	}
 
@@ -170,8 +376,29 @@ func (am *AlertManager) AddRule(rule *Rule) error {
 	return nil
 }
 
-// RemoveRule 移除规则
+// RemoveRule 移除规则，并为已触发的告警发送恢复通知
 func (am *AlertManager) RemoveRule(name string) error {
+	r, err := am.detachRule(name)
+	if err != nil {
+		return err
+	}
+
+	resolved := r.resolveAll(context.Background(), am.clock().Now())
+	if len(resolved) > 0 {
+		notifications := make([]*Notification, 0, len(resolved))
+		for _, alert := range resolved {
+			notifications = append(notifications, NewNotification(r, alert))
+		}
+		SortNotifications(notifications)
+		if err := am.notifier.Notify(context.Background(), notifications); err != nil {
+			log.Printf("Error sending resolved alerts for rule %s: %v", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// detachRule 从规则列表和存储中移除规则
+func (am *AlertManager) detachRule(name string) (*Rule, error) {
 	am.mtx.Lock()
 	defer am.mtx.Unlock()
 
@@ -179,13 +406,13 @@ func (am *AlertManager) RemoveRule(name string) error {
 		if r.Name == name {
 			// 从存储中删除该规则的告警状态
 			if err := am.storage.SaveAlerts(r, nil); err != nil {
-				return fmt.Errorf("failed to clear alerts for rule %s: %v", name, err)
+				return nil, fmt.Errorf("failed to clear alerts for rule %s: %v", name, err)
 			}
 			// 从规则列表中移除
 			am.rules = append(am.rules[:i], am.rules[i+1:]...)
-			return nil
+			return r, nil
 		}
 	}
 
-	return errors.New("rule not found")
+	return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, name)
 }
@@ -0,0 +1,631 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+type recordNotifier struct {
+	mtx           sync.Mutex
+	notifications []*Notification
+}
+
+func (n *recordNotifier) Notify(_ context.Context, notifications []*Notification) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.notifications = append(n.notifications, notifications...)
+	return nil
+}
+
+func (n *recordNotifier) All() []*Notification {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return append([]*Notification(nil), n.notifications...)
+}
+
+func newTestRule(t *testing.T, name, expr string, opts *AlertOpts) *Rule {
+	rule, err := NewRule(name, expr, opts.HoldDuration, opts.KeepFiringFor, opts.ResendDelay, labels.EmptyLabels(), labels.EmptyLabels())
+	require.NoError(t, err)
+	rule.AlertType = AlertTypeBasic
+	rule.AlertOpts = opts
+	return rule
+}
+
+func TestAlertManager_OnEvalError(t *testing.T) {
+	errQuery := errors.New("query failed")
+	queryFn := func(_ context.Context, query string, ts time.Time) (promql.Vector, error) {
+		if query == "bad" {
+			return nil, errQuery
+		}
+		return promql.Vector{{Metric: labels.FromStrings("instance", "a"), F: 1}}, nil
+	}
+
+	bad := newTestRule(t, "bad", "bad", &AlertOpts{})
+	good := newTestRule(t, "good", "good", &AlertOpts{})
+	notifier := &recordNotifier{}
+	am := NewAlertManager([]*Rule{bad, good}, time.Second, queryFn, notifier, nil)
+
+	var (
+		mtx     sync.Mutex
+		gotRule *Rule
+		gotErr  error
+		calls   int
+	)
+	am.OnEvalError = func(rule *Rule, err error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		gotRule, gotErr = rule, err
+		calls++
+	}
+
+	am.evaluateAllRules()
+	am.evalWg.Wait()
+
+	require.Equal(t, 1, calls)
+	require.Same(t, bad, gotRule)
+	require.ErrorIs(t, gotErr, errQuery)
+
+	notifications := notifier.All()
+	require.Len(t, notifications, 1)
+	require.Equal(t, "good", notifications[0].Rule)
+}
+
+func TestAlertManager_EvalPanic(t *testing.T) {
+	queryFn := func(_ context.Context, query string, ts time.Time) (promql.Vector, error) {
+		if query == "bad" {
+			panic("bad query")
+		}
+		return promql.Vector{{Metric: labels.FromStrings("instance", "a"), F: 1}}, nil
+	}
+
+	bad := newTestRule(t, "bad", "bad", &AlertOpts{})
+	good := newTestRule(t, "good", "good", &AlertOpts{ResendDelay: time.Hour})
+	notifier := &recordNotifier{}
+	am := NewAlertManager([]*Rule{bad, good}, time.Second, queryFn, notifier, nil)
+
+	var (
+		mtx   sync.Mutex
+		rules []string
+		errs  []error
+	)
+	am.OnEvalError = func(rule *Rule, err error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		rules = append(rules, rule.Name)
+		errs = append(errs, err)
+	}
+
+	// 多轮评估：panic 的规则每轮都可再次评估，其他规则不受影响
+	for i := 0; i < 2; i++ {
+		am.evaluateAllRules()
+		am.evalWg.Wait()
+	}
+
+	require.Equal(t, []string{"bad", "bad"}, rules)
+	require.ErrorIs(t, errs[0], ErrEvalPanic)
+	require.Contains(t, errs[0].Error(), "bad query")
+	require.False(t, bad.evaluating.Load(), "evaluation flag is cleared after panic")
+
+	notifications := notifier.All()
+	require.Len(t, notifications, 1)
+	require.Equal(t, "good", notifications[0].Rule)
+}
+
+func TestAlertManager_MaxNotificationsPerCycle(t *testing.T) {
+	var vector promql.Vector
+	for i := 0; i < 25; i++ {
+		vector = append(vector, promql.Sample{Metric: labels.FromStrings("instance", fmt.Sprintf("host%02d", i)), F: 1})
+	}
+	queryFn := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		return vector, nil
+	}
+	notifier := &recordNotifier{}
+	rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{ResendDelay: time.Hour})
+	am := NewAlertManager([]*Rule{rule}, time.Second, queryFn, notifier, nil)
+	am.MaxNotificationsPerCycle = 10
+
+	cycle := func() []*Notification {
+		before := len(notifier.All())
+		am.evaluateAllRules()
+		am.evalWg.Wait()
+		return notifier.All()[before:]
+	}
+	instances := func(notifications []*Notification) []string {
+		var out []string
+		for _, n := range notifications {
+			if n.Rule != BudgetAlertName {
+				out = append(out, n.Labels["instance"])
+			}
+		}
+		return out
+	}
+
+	// 第一轮：25 条告警同时触发，只发送 10 条，附加一条元告警
+	first := cycle()
+	require.Len(t, first, 11)
+	require.Equal(t, BudgetAlertName, first[10].Rule)
+	require.Equal(t, float64(15), first[10].Value)
+	require.Len(t, instances(first), 10)
+
+	// 后续轮次按先进先出发送顺延的通知，不再产生元告警
+	second := cycle()
+	require.Len(t, second, 10)
+	third := cycle()
+	require.Len(t, third, 5)
+	require.Empty(t, cycle())
+
+	all := append(append(instances(first), instances(second)...), instances(third)...)
+	want := make([]string, 0, len(vector))
+	for _, sample := range vector {
+		want = append(want, sample.Metric.Get("instance"))
+	}
+	require.Equal(t, want, all, "each alert is delivered once, in order")
+}
+
+func TestAlertManager_StopWaitsForEvaluations(t *testing.T) {
+	started := make(chan struct{}, 1)
+	queryFn := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		time.Sleep(20 * time.Millisecond)
+		return promql.Vector{{Metric: labels.FromStrings("instance", "a"), F: 1}}, nil
+	}
+
+	rule := newTestRule(t, "slow", "slow", &AlertOpts{})
+	storage := NewMemoryStorage()
+	am := NewAlertManager([]*Rule{rule}, 40*time.Millisecond, queryFn, &recordNotifier{}, storage)
+	require.NoError(t, am.Run())
+
+	<-started
+	am.Stop()
+
+	alerts, err := storage.LoadAlerts(rule)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1, "in-flight evaluation should settle before state is saved")
+	require.Equal(t, AlertStateFiring, alerts[0].State())
+}
+
+func TestAlertManager_DegradeLevels(t *testing.T) {
+	degrade := newTestRule(t, "degrade", "qps > 100", &AlertOpts{HoldDuration: time.Minute})
+	degrade.AlertType = AlertTypeMultiTier
+	basic := newTestRule(t, "basic", "cpu > 0.8", &AlertOpts{})
+	am := NewAlertManager([]*Rule{degrade, basic}, time.Second, nil, &recordNotifier{}, nil)
+
+	vector := promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: 200}}
+	t0 := time.Now()
+	for _, ts := range []time.Time{t0, t0.Add(2 * time.Minute), t0.Add(4 * time.Minute)} {
+		_, err := degrade.EvalVector(context.Background(), ts, vector)
+		require.NoError(t, err)
+		_, err = basic.EvalVector(context.Background(), ts, vector)
+		require.NoError(t, err)
+	}
+
+	key := labels.FromStrings("alertname", "degrade", "instance", "host1").String()
+	require.Equal(t, map[string]int{key: 2}, am.DegradeLevels())
+}
+
+func TestAlertManager_ExportImportState(t *testing.T) {
+	basic := newTestRule(t, "basic", "cpu > 0.8", &AlertOpts{HoldDuration: time.Minute})
+	degrade := newTestRule(t, "degrade", "qps > 100", &AlertOpts{})
+	degrade.AlertType = AlertTypeMultiTier
+	am := NewAlertManager([]*Rule{basic, degrade}, time.Second, nil, &recordNotifier{}, nil)
+
+	vector := promql.Vector{
+		{Metric: labels.FromStrings("instance", "host1"), F: 1},
+		{Metric: labels.FromStrings("instance", "host2"), F: 2},
+	}
+	t0 := time.Now()
+	for _, ts := range []time.Time{t0, t0.Add(2 * time.Minute)} {
+		for _, rule := range []*Rule{basic, degrade} {
+			_, err := rule.EvalVector(context.Background(), ts, vector)
+			require.NoError(t, err)
+		}
+	}
+
+	snapshot := func() map[string]map[uint64]string {
+		out := make(map[string]map[uint64]string)
+		for _, rule := range []*Rule{basic, degrade} {
+			out[rule.Name] = make(map[uint64]string)
+			for fp, alert := range rule.active {
+				data, err := alert.Marshal()
+				require.NoError(t, err)
+				out[rule.Name][fp] = string(data)
+			}
+		}
+		return out
+	}
+	before := snapshot()
+
+	data, err := am.ExportState()
+	require.NoError(t, err)
+
+	basic.active = make(map[uint64]IAlert)
+	degrade.active = make(map[uint64]IAlert)
+
+	require.NoError(t, am.ImportState(data))
+	require.Equal(t, before, snapshot())
+	require.Len(t, before["basic"], 2)
+	require.Len(t, before["degrade"], 2)
+
+	require.Error(t, am.ImportState([]byte(`{"version": 99}`)))
+}
+
+func TestAlertManager_RemoveRule_SendsResolved(t *testing.T) {
+	rule := newTestRule(t, "HighCPU", "cpu > 0.8", &AlertOpts{})
+	pending := newTestRule(t, "Pending", "mem > 0.8", &AlertOpts{HoldDuration: time.Hour})
+	notifier := &recordNotifier{}
+	am := NewAlertManager([]*Rule{rule, pending}, time.Second, nil, notifier, NewMemoryStorage())
+
+	vector := promql.Vector{
+		{Metric: labels.FromStrings("instance", "host1"), F: 1},
+		{Metric: labels.FromStrings("instance", "host2"), F: 1},
+	}
+	_, err := rule.EvalVector(context.Background(), time.Now(), vector)
+	require.NoError(t, err)
+	_, err = pending.EvalVector(context.Background(), time.Now(), vector)
+	require.NoError(t, err)
+
+	require.NoError(t, am.RemoveRule("HighCPU"))
+	notifications := notifier.All()
+	require.Len(t, notifications, 2)
+	instances := make(map[string]bool)
+	for _, n := range notifications {
+		require.Equal(t, "HighCPU", n.Rule)
+		require.Equal(t, string(AlertStateInactive), n.Status)
+		instances[n.Labels["instance"]] = true
+	}
+	require.Equal(t, map[string]bool{"host1": true, "host2": true}, instances)
+	require.Empty(t, rule.active)
+
+	// pending 告警从未通知过，不发送恢复通知
+	require.NoError(t, am.RemoveRule("Pending"))
+	require.Len(t, notifier.All(), 2)
+
+	require.Error(t, am.RemoveRule("HighCPU"))
+}
+
+func TestAlertManager_NoOverlappingEvaluations(t *testing.T) {
+	var (
+		mtx         sync.Mutex
+		running     int
+		maxRunning  int
+		evaluations int
+	)
+	queryFn := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		mtx.Lock()
+		running++
+		evaluations++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mtx.Unlock()
+
+		time.Sleep(30 * time.Millisecond)
+
+		mtx.Lock()
+		running--
+		mtx.Unlock()
+		return promql.Vector{}, nil
+	}
+
+	rule := newTestRule(t, "slow", "slow", &AlertOpts{})
+	am := NewAlertManager([]*Rule{rule}, time.Second, queryFn, &recordNotifier{}, nil)
+	for i := 0; i < 5; i++ {
+		am.evaluateAllRules()
+		time.Sleep(5 * time.Millisecond)
+	}
+	am.evalWg.Wait()
+
+	require.Equal(t, 1, maxRunning)
+	require.Equal(t, 1, evaluations, "ticks during a running evaluation should be skipped")
+
+	am.evaluateAllRules()
+	am.evalWg.Wait()
+	require.Equal(t, 2, evaluations, "rule should be evaluated again once the previous run finished")
+}
+
+func TestAlertManager_RuleSelector(t *testing.T) {
+	var (
+		mtx     sync.Mutex
+		queried []string
+	)
+	queryFn := func(_ context.Context, query string, _ time.Time) (promql.Vector, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		queried = append(queried, query)
+		return nil, nil
+	}
+
+	var rules []*Rule
+	for _, spec := range []struct{ name, shard string }{
+		{"a1", "a"}, {"a2", "a"}, {"b1", "b"}, {"none", ""},
+	} {
+		rule := newTestRule(t, spec.name, spec.name, &AlertOpts{})
+		if spec.shard != "" {
+			rule.Labels = labels.FromStrings("shard", spec.shard)
+		}
+		rules = append(rules, rule)
+	}
+	am := NewAlertManager(rules, time.Second, queryFn, &recordNotifier{}, NewMemoryStorage())
+	am.RuleSelector = []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "shard", "a")}
+
+	am.evaluateAllRules()
+	am.evalWg.Wait()
+	require.ElementsMatch(t, []string{"a1", "a2"}, queried)
+
+	// 未设置选择器时评估全部规则
+	queried = nil
+	am.RuleSelector = nil
+	am.evaluateAllRules()
+	am.evalWg.Wait()
+	require.ElementsMatch(t, []string{"a1", "a2", "b1", "none"}, queried)
+}
+
+func TestAlertManager_NotificationOrder(t *testing.T) {
+	queryFn := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		return promql.Vector{
+			{Metric: labels.FromStrings("instance", "host3", "severity", "info"), F: 1},
+			{Metric: labels.FromStrings("instance", "host2", "severity", "critical"), F: 1},
+			{Metric: labels.FromStrings("instance", "host5"), F: 1},
+			{Metric: labels.FromStrings("instance", "host1", "severity", "warning"), F: 1},
+			{Metric: labels.FromStrings("instance", "host4", "severity", "critical"), F: 1},
+			{Metric: labels.FromStrings("instance", "host0", "severity", "warning"), F: 1},
+		}, nil
+	}
+	rule := newTestRule(t, "batch", "batch", &AlertOpts{})
+	notifier := &recordNotifier{}
+	am := NewAlertManager([]*Rule{rule}, time.Second, queryFn, notifier, NewMemoryStorage())
+
+	am.evaluateAllRules()
+	am.evalWg.Wait()
+
+	var got []string
+	for _, n := range notifier.All() {
+		got = append(got, n.Severity+"/"+n.Labels["instance"])
+	}
+	require.Equal(t, []string{
+		"critical/host2", "critical/host4",
+		"warning/host0", "warning/host1",
+		"info/host3",
+		"/host5",
+	}, got)
+}
+
+func TestAlertManager_SuppressInitialOnRestart(t *testing.T) {
+	newRule := func(quiet time.Duration) *Rule {
+		rule := newTestRule(t, "cpu", "cpu > 0.8", &AlertOpts{ResendDelay: time.Minute})
+		rule.SuppressInitialOnRestart = quiet
+		return rule
+	}
+	vector := promql.Vector{
+		{Metric: labels.FromStrings("instance", "host1"), F: 1},
+		{Metric: labels.FromStrings("instance", "host2"), F: 1},
+	}
+
+	// 重启前两个实例均已触发并发送过通知
+	storage := NewMemoryStorage()
+	before := newRule(0)
+	t0 := time.Now()
+	alerts, err := before.EvalVector(context.Background(), t0, vector)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	require.NoError(t, NewAlertManager([]*Rule{before}, time.Second, nil, &recordNotifier{}, storage).saveAlerts())
+
+	restart := func(rule *Rule, now time.Time) {
+		am := NewAlertManager([]*Rule{rule}, time.Second, nil, &recordNotifier{}, storage)
+		am.Clock = NewFakeClock(now)
+		require.NoError(t, am.restoreAlerts())
+	}
+	instances := func(alerts []IAlert) []string {
+		var out []string
+		for _, alert := range alerts {
+			out = append(out, alert.Labels().Get("instance"))
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	// 不静默时重启后的第一次评估立即重发
+	plain := newRule(0)
+	restart(plain, t0.Add(5*time.Minute))
+	alerts, err = plain.EvalVector(context.Background(), t0.Add(5*time.Minute), vector)
+	require.NoError(t, err)
+	require.Equal(t, []string{"host1", "host2"}, instances(alerts))
+
+	quiet := newRule(10 * time.Minute)
+	restart(quiet, t0.Add(5*time.Minute))
+	alerts, err = quiet.EvalVector(context.Background(), t0.Add(5*time.Minute), vector)
+	require.NoError(t, err)
+	require.Empty(t, alerts, "restored alerts do not resend within the quiet period")
+
+	// 静默期内状态变化照常通知
+	alerts, err = quiet.EvalVector(context.Background(), t0.Add(7*time.Minute), vector[:1])
+	require.NoError(t, err)
+	require.Equal(t, []string{"host2"}, instances(alerts))
+	require.Equal(t, AlertStateInactive, alerts[0].State())
+
+	// 静默期结束后恢复正常重发
+	alerts, err = quiet.EvalVector(context.Background(), t0.Add(14*time.Minute), vector[:1])
+	require.NoError(t, err)
+	require.Empty(t, alerts)
+	alerts, err = quiet.EvalVector(context.Background(), t0.Add(16*time.Minute), vector[:1])
+	require.NoError(t, err)
+	require.Equal(t, []string{"host1"}, instances(alerts))
+	require.Empty(t, quiet.quietUntil)
+}
+
+func TestAlertManager_SaveRestore_SkipsRetainedResolved(t *testing.T) {
+	rule := newTestRule(t, "HighCPU", "cpu > 0.8", &AlertOpts{})
+	rule.ResolvedRetention = 5 * time.Minute
+	am := NewAlertManager([]*Rule{rule}, time.Second, nil, &recordNotifier{}, NewMemoryStorage())
+
+	t0 := time.Now()
+	_, err := rule.EvalVector(context.Background(), t0, promql.Vector{
+		{Metric: labels.FromStrings("instance", "host1"), F: 1},
+		{Metric: labels.FromStrings("instance", "host2"), F: 1},
+	})
+	require.NoError(t, err)
+	_, err = rule.EvalVector(context.Background(), t0.Add(time.Minute), promql.Vector{
+		{Metric: labels.FromStrings("instance", "host2"), F: 1},
+	})
+	require.NoError(t, err)
+	require.Len(t, rule.ActiveAlerts(true), 2)
+
+	require.NoError(t, am.saveAlerts())
+	require.NoError(t, am.restoreAlerts())
+	alerts := rule.ActiveAlerts(true)
+	require.Len(t, alerts, 1, "retained resolved alert is not persisted")
+	require.Equal(t, "host2", alerts[0].Labels().Get("instance"))
+
+	// 恢复后的告警仍能正常恢复并在保留期后移除
+	_, err = rule.EvalVector(context.Background(), t0.Add(2*time.Minute), promql.Vector{})
+	require.NoError(t, err)
+	require.Empty(t, rule.ActiveAlerts(false))
+	_, err = rule.EvalVector(context.Background(), t0.Add(8*time.Minute), promql.Vector{})
+	require.NoError(t, err)
+	require.Empty(t, rule.ActiveAlerts(true))
+
+	_, err = rule.EvalVector(context.Background(), t0.Add(9*time.Minute), promql.Vector{
+		{Metric: labels.FromStrings("instance", "host1"), F: 1},
+	})
+	require.NoError(t, err)
+	_, err = rule.EvalVector(context.Background(), t0.Add(10*time.Minute), promql.Vector{})
+	require.NoError(t, err)
+	data, err := am.ExportState()
+	require.NoError(t, err)
+	require.NoError(t, am.ImportState(data))
+	require.Empty(t, rule.ActiveAlerts(true), "retained resolved alert is not exported")
+}
+
+func TestAlertManager_DeferredQueueCapAndSupersede(t *testing.T) {
+	series := func(n int) promql.Vector {
+		var vector promql.Vector
+		for i := 0; i < n; i++ {
+			vector = append(vector, promql.Sample{Metric: labels.FromStrings("instance", fmt.Sprintf("host%02d", i)), F: 1})
+		}
+		return vector
+	}
+	var (
+		vecMtx sync.Mutex
+		vector = series(20)
+	)
+	queryFn := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		vecMtx.Lock()
+		defer vecMtx.Unlock()
+		return vector, nil
+	}
+	notifier := &recordNotifier{}
+	rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{ResendDelay: time.Hour})
+	am := NewAlertManager([]*Rule{rule}, time.Second, queryFn, notifier, nil)
+	am.MaxNotificationsPerCycle = 5
+	am.MaxDeferredNotifications = 8
+
+	cycle := func() []*Notification {
+		before := len(notifier.All())
+		am.evaluateAllRules()
+		am.evalWg.Wait()
+		return notifier.All()[before:]
+	}
+
+	// 20 条通知：5 条发送，15 条顺延，超出上限的 7 条最早顺延的通知被丢弃
+	first := cycle()
+	require.Len(t, first, 6)
+	meta := first[5]
+	require.Equal(t, BudgetAlertName, meta.Rule)
+	require.Equal(t, float64(8), meta.Value)
+	require.Equal(t, "7", meta.Metadata[DroppedLabel])
+	require.Len(t, am.deferred, 8)
+	require.Equal(t, "host12", am.deferred[0].Labels["instance"])
+
+	// 顺延中的告警恢复：旧的 firing 通知被 resolved 取代，且顺延通知先于本轮通知发送
+	vecMtx.Lock()
+	vector = series(18)
+	vecMtx.Unlock()
+	second := cycle()
+	require.Len(t, second, 6)
+	require.Equal(t, BudgetAlertName, second[5].Rule)
+	require.Empty(t, second[5].Metadata)
+	var instances []string
+	for _, n := range second[:5] {
+		instances = append(instances, n.Labels["instance"]+"/"+n.Status)
+	}
+	require.Equal(t, []string{"host12/firing", "host13/firing", "host14/firing", "host15/firing", "host16/firing"}, instances)
+
+	third := cycle()
+	instances = instances[:0]
+	for _, n := range third {
+		instances = append(instances, n.Labels["instance"]+"/"+n.Status)
+	}
+	require.Equal(t, []string{"host17/firing", "host18/inactive", "host19/inactive"}, instances,
+		"stale firing notifications for resolved alerts are dropped")
+}
+
+// stallingNotifier 第一次调用之后的 Notify 阻塞到 release 关闭或 ctx 结束
+type stallingNotifier struct {
+	recordNotifier
+	calls   int
+	release chan struct{}
+}
+
+func (n *stallingNotifier) Notify(ctx context.Context, notifications []*Notification) error {
+	n.mtx.Lock()
+	n.calls++
+	block := n.calls > 1
+	n.mtx.Unlock()
+	if block {
+		select {
+		case <-n.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return n.recordNotifier.Notify(ctx, notifications)
+}
+
+func TestAlertManager_DeferredSendDoesNotBlockCycle(t *testing.T) {
+	vector := promql.Vector{
+		{Metric: labels.FromStrings("instance", "host1"), F: 1},
+		{Metric: labels.FromStrings("instance", "host2"), F: 1},
+	}
+	queryFn := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		return vector, nil
+	}
+	notifier := &stallingNotifier{release: make(chan struct{})}
+	rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{ResendDelay: time.Hour})
+	am := NewAlertManager([]*Rule{rule}, time.Minute, queryFn, notifier, nil)
+	am.MaxNotificationsPerCycle = 1
+
+	am.evaluateAllRules()
+	am.evalWg.Wait()
+	require.Len(t, notifier.All(), 2, "one alert plus the budget meta-alert")
+
+	// 顺延通知的接收方阻塞时，本轮评估和规则增删不受影响
+	done := make(chan struct{})
+	go func() {
+		am.evaluateAllRules()
+		require.NoError(t, am.AddRule(newTestRule(t, "HighMem", "mem > 0.8", &AlertOpts{})))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("evaluation blocked on a hung deferred send")
+	}
+
+	close(notifier.release)
+	am.evalWg.Wait()
+	all := notifier.All()
+	require.Len(t, all, 3)
+	require.Equal(t, "host2", all[2].Labels["instance"])
+}
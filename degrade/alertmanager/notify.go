@@ -4,7 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// NotificationKind 区分首次通知、重发和恢复，便于接收方分别路由
+type NotificationKind string
+
+const (
+	NotificationNew      NotificationKind = "new"      // 首次触发或级别变化
+	NotificationResend   NotificationKind = "resend"   // 状态未变，按重发间隔再次发送
+	NotificationResolved NotificationKind = "resolved" // 恢复到 inactive/L0
 )
 
 // Notification 表示发送的告警通知
@@ -13,9 +25,14 @@ type Notification struct {
 	Status   string            `json:"status"`
 	Labels   map[string]string `json:"labels"`
 	Metadata map[string]string `json:"metadata,omitempty"`
+	GroupKey string            `json:"groupKey,omitempty"`
+	Severity string            `json:"severity,omitempty"` // 取自告警的级别标签，如 critical/warning/info
+	Kind     NotificationKind  `json:"kind,omitempty"`
 	Value    float64           `json:"value"`
 	StartsAt time.Time         `json:"startsAt"`
 	EndsAt   time.Time         `json:"endsAt"`
+	// Metrics CorrelationNotifier 合并多条规则的通知时记录各规则的取值
+	Metrics []MetricValue `json:"metrics,omitempty"`
 }
 
 func NewNotification(r *Rule, alert IAlert) *Notification {
@@ -24,9 +41,21 @@ func NewNotification(r *Rule, alert IAlert) *Notification {
 		Rule:     r.Name,
 		Status:   string(snap.State),
 		Labels:   alert.Labels().Map(),
+		Severity: alert.Labels().Get(r.severityLabel()),
+		Kind:     notificationKind(snap),
 		StartsAt: snap.FiredAt,
 		Value:    alert.GetValue(),
 	}
+	// 多级告警不记录 FiredAt，以进入当前级别的时间作为开始时间
+	if level, ok := AlertState(snap.State).DegradeLevel(); ok && level > 0 && n.StartsAt.IsZero() {
+		n.StartsAt = snap.StateEnteredAt[AlertState(snap.State)]
+	}
+	if md := alert.Metadata(); len(md) > 0 {
+		n.Metadata = make(map[string]string, len(md))
+		for k, v := range md {
+			n.Metadata[k] = v
+		}
+	}
 	// 对于已解决的告警，设置结束时间
 	if AlertState(snap.State) == AlertStateInactive && !snap.FiredAt.IsZero() {
 		n.EndsAt = time.Now()
@@ -34,6 +63,47 @@ func NewNotification(r *Rule, alert IAlert) *Notification {
 	return n
 }
 
+// notificationKind 根据快照判断本次通知的类型：最后发送时间晚于进入当前状态的时间即为重发
+func notificationKind(snap AlertSnapshot) NotificationKind {
+	state := AlertState(snap.State)
+	if state == AlertStateInactive || state == AlertStateL0 {
+		return NotificationResolved
+	}
+	entered := snap.FiredAt
+	if _, ok := state.DegradeLevel(); ok {
+		entered = snap.StateEnteredAt[state]
+	}
+	if !entered.IsZero() && snap.LastSentAt.After(entered) {
+		return NotificationResend
+	}
+	return NotificationNew
+}
+
+// severityRank 通知排序时的级别次序，未列出的级别排在最后
+var severityRank = map[string]int{
+	"critical": 0,
+	"warning":  1,
+	"info":     2,
+}
+
+func rankOf(severity string) int {
+	if r, ok := severityRank[severity]; ok {
+		return r
+	}
+	return len(severityRank)
+}
+
+// SortNotifications 按级别（critical、warning、info、其他）再按标签排序，使同一批通知的顺序确定
+func SortNotifications(notifications []*Notification) {
+	sort.SliceStable(notifications, func(i, j int) bool {
+		a, b := notifications[i], notifications[j]
+		if ra, rb := rankOf(a.Severity), rankOf(b.Severity); ra != rb {
+			return ra < rb
+		}
+		return labels.Compare(labels.FromMap(a.Labels), labels.FromMap(b.Labels)) < 0
+	})
+}
+
 // Notifier 定义通知器接口
 type Notifier interface {
 	Notify(ctx context.Context, notifications []*Notification) error
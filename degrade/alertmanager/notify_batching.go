@@ -0,0 +1,104 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrNotifierClosed 通知器关闭后继续发送时返回
+var ErrNotifierClosed = errors.New("notifier closed")
+
+// BatchingNotifier 缓冲一个窗口内的通知，合并为一次调用发给底层通知器，
+// 避免关联故障中多条规则同时触发时通知被拆成许多小批次。
+// 窗口从缓冲区收到第一条通知开始计时；缓冲达到 maxSize 时立即发送。
+// 窗口到期的发送在后台进行，失败只记录日志
+type BatchingNotifier struct {
+	base    Notifier
+	window  time.Duration
+	maxSize int
+
+	mtx     sync.Mutex
+	buf     []*Notification
+	timer   *time.Timer
+	gen     int // 每取出一批加一，使已过期的计时回调不会提前取走下一批
+	closed  bool
+	sendMtx sync.Mutex // 取出与发送都在此锁内进行，保证批次按取出顺序送达
+}
+
+// NewBatchingNotifier maxSize <= 0 时不限制缓冲大小
+func NewBatchingNotifier(base Notifier, window time.Duration, maxSize int) *BatchingNotifier {
+	return &BatchingNotifier{
+		base:    base,
+		window:  window,
+		maxSize: maxSize,
+	}
+}
+
+// Notify 将通知加入缓冲；达到 maxSize 时同步发送并返回底层错误
+func (b *BatchingNotifier) Notify(ctx context.Context, notifications []*Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	b.mtx.Lock()
+	if b.closed {
+		b.mtx.Unlock()
+		return ErrNotifierClosed
+	}
+	b.buf = append(b.buf, notifications...)
+	if b.maxSize > 0 && len(b.buf) >= b.maxSize {
+		b.mtx.Unlock()
+		return b.flush(ctx, -1)
+	}
+	if b.timer == nil {
+		gen := b.gen
+		b.timer = time.AfterFunc(b.window, func() { b.flushWindow(gen) })
+	}
+	b.mtx.Unlock()
+	return nil
+}
+
+// Close 停止计时并立即发送缓冲中的通知，之后的 Notify 返回 ErrNotifierClosed
+func (b *BatchingNotifier) Close(ctx context.Context) error {
+	b.mtx.Lock()
+	b.closed = true
+	b.mtx.Unlock()
+	return b.flush(ctx, -1)
+}
+
+func (b *BatchingNotifier) flushWindow(gen int) {
+	if err := b.flush(context.Background(), gen); err != nil {
+		log.Printf("Error sending batched notifications: %v", err)
+	}
+}
+
+// flush 持有 sendMtx 取出并发送缓冲；gen >= 0 时仅在计时代数匹配时发送
+func (b *BatchingNotifier) flush(ctx context.Context, gen int) error {
+	b.sendMtx.Lock()
+	defer b.sendMtx.Unlock()
+	b.mtx.Lock()
+	if gen >= 0 && gen != b.gen {
+		b.mtx.Unlock()
+		return nil
+	}
+	batch := b.take()
+	b.mtx.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return b.base.Notify(ctx, batch)
+}
+
+// take 取出缓冲并停止计时，调用方需持有 sendMtx 与 mtx
+func (b *BatchingNotifier) take() []*Notification {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.gen++
+	batch := b.buf
+	b.buf = nil
+	return batch
+}
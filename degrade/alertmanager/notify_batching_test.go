@@ -0,0 +1,114 @@
+package alertmanager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// batchRecorder 记录每次 Notify 调用收到的批次
+type batchRecorder struct {
+	mtx     sync.Mutex
+	batches [][]string
+}
+
+func (r *batchRecorder) Notify(_ context.Context, notifications []*Notification) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	var rules []string
+	for _, n := range notifications {
+		rules = append(rules, n.Rule)
+	}
+	r.batches = append(r.batches, rules)
+	return nil
+}
+
+func (r *batchRecorder) Batches() [][]string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([][]string(nil), r.batches...)
+}
+
+func TestBatchingNotifier_CoalescesWithinWindow(t *testing.T) {
+	base := &batchRecorder{}
+	notifier := NewBatchingNotifier(base, 50*time.Millisecond, 0)
+
+	for _, rule := range []string{"cpu", "mem", "disk"} {
+		require.NoError(t, notifier.Notify(context.Background(), []*Notification{{Rule: rule}}))
+	}
+	require.Empty(t, base.Batches(), "nothing is sent before the window closes")
+
+	require.Eventually(t, func() bool { return len(base.Batches()) == 1 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, [][]string{{"cpu", "mem", "disk"}}, base.Batches())
+
+	// 下一批重新计时
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{{Rule: "net"}}))
+	require.Eventually(t, func() bool { return len(base.Batches()) == 2 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, []string{"net"}, base.Batches()[1])
+}
+
+func TestBatchingNotifier_MaxSize(t *testing.T) {
+	base := &batchRecorder{}
+	notifier := NewBatchingNotifier(base, time.Hour, 3)
+
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{{Rule: "a"}, {Rule: "b"}}))
+	require.Empty(t, base.Batches())
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{{Rule: "c"}}))
+	require.Equal(t, [][]string{{"a", "b", "c"}}, base.Batches(), "full buffer is sent immediately")
+}
+
+func TestBatchingNotifier_Close(t *testing.T) {
+	base := &batchRecorder{}
+	notifier := NewBatchingNotifier(base, time.Hour, 0)
+
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{{Rule: "a"}}))
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{{Rule: "b"}}))
+	require.NoError(t, notifier.Close(context.Background()))
+	require.Equal(t, [][]string{{"a", "b"}}, base.Batches(), "pending notifications are flushed on close")
+
+	require.ErrorIs(t, notifier.Notify(context.Background(), []*Notification{{Rule: "c"}}), ErrNotifierClosed)
+	require.NoError(t, notifier.Close(context.Background()))
+	require.Len(t, base.Batches(), 1)
+}
+
+// blockingRecorder 第一次 Notify 阻塞到 release 关闭，模拟慢速下游
+type blockingRecorder struct {
+	batchRecorder
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (r *blockingRecorder) Notify(ctx context.Context, notifications []*Notification) error {
+	first := false
+	r.once.Do(func() { first = true })
+	if first {
+		close(r.started)
+		<-r.release
+	}
+	return r.batchRecorder.Notify(ctx, notifications)
+}
+
+func TestBatchingNotifier_PreservesBatchOrder(t *testing.T) {
+	base := &blockingRecorder{started: make(chan struct{}), release: make(chan struct{})}
+	notifier := NewBatchingNotifier(base, time.Millisecond, 0)
+
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{{Rule: "a"}}))
+	<-base.started
+
+	// 第一批发送阻塞期间到期的窗口不会提前取走缓冲
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{{Rule: "b"}}))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{{Rule: "c"}}))
+
+	closed := make(chan error, 1)
+	go func() { closed <- notifier.Close(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	close(base.release)
+	require.NoError(t, <-closed)
+
+	require.Equal(t, [][]string{{"a"}, {"b", "c"}}, base.Batches())
+}
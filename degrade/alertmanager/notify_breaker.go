@@ -0,0 +1,114 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断打开期间发送被短路时返回
+var ErrCircuitOpen = errors.New("notifier circuit open")
+
+// CircuitState 熔断器状态
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreakerNotifier 连续失败达到阈值后熔断，冷却期内直接拒绝发送，
+// 冷却结束后进入半开状态放行一次试探请求，成功则关闭熔断，失败则重新打开
+type CircuitBreakerNotifier struct {
+	base      Notifier
+	threshold int
+	cooldown  time.Duration
+
+	mtx      sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool // 半开状态下是否已有试探请求在进行
+
+	now func() time.Time
+}
+
+func NewCircuitBreakerNotifier(base Notifier, threshold int, cooldown time.Duration) *CircuitBreakerNotifier {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreakerNotifier{
+		base:      base,
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     CircuitClosed,
+		now:       time.Now,
+	}
+}
+
+// State 返回当前熔断状态
+func (c *CircuitBreakerNotifier) State() CircuitState {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.refresh()
+	return c.state
+}
+
+func (c *CircuitBreakerNotifier) Notify(ctx context.Context, notifications []*Notification) error {
+	if !c.acquire() {
+		return ErrCircuitOpen
+	}
+	err := c.base.Notify(ctx, notifications)
+	c.record(err)
+	return err
+}
+
+// refresh 冷却结束后从打开切换为半开
+func (c *CircuitBreakerNotifier) refresh() {
+	if c.state == CircuitOpen && c.now().Sub(c.openedAt) >= c.cooldown {
+		c.state = CircuitHalfOpen
+		c.probing = false
+	}
+}
+
+func (c *CircuitBreakerNotifier) acquire() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.refresh()
+	switch c.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if c.probing {
+			return false
+		}
+		c.probing = true
+	}
+	return true
+}
+
+func (c *CircuitBreakerNotifier) record(err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if err == nil {
+		if c.state != CircuitClosed {
+			log.Printf("[CircuitBreaker] Notifier recovered, closing circuit")
+		}
+		c.state = CircuitClosed
+		c.failures = 0
+		c.probing = false
+		return
+	}
+
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= c.threshold {
+		log.Printf("[CircuitBreaker] Opening circuit after %d consecutive failures: %v", c.failures, err)
+		c.state = CircuitOpen
+		c.openedAt = c.now()
+		c.probing = false
+	}
+}
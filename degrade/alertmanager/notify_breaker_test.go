@@ -0,0 +1,80 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type flakyNotifier struct {
+	fail  bool
+	calls int
+}
+
+func (f *flakyNotifier) Notify(_ context.Context, _ []*Notification) error {
+	f.calls++
+	if f.fail {
+		return errors.New("receiver down")
+	}
+	return nil
+}
+
+func TestCircuitBreakerNotifier_Transitions(t *testing.T) {
+	base := &flakyNotifier{fail: true}
+	cb := NewCircuitBreakerNotifier(base, 3, time.Minute)
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// closed：失败未达阈值
+	for i := 0; i < 2; i++ {
+		require.Error(t, cb.Notify(ctx, nil))
+		require.Equal(t, CircuitClosed, cb.State())
+	}
+
+	// 第三次失败后打开
+	require.Error(t, cb.Notify(ctx, nil))
+	require.Equal(t, CircuitOpen, cb.State())
+
+	// 打开期间短路，不调用下游
+	require.ErrorIs(t, cb.Notify(ctx, nil), ErrCircuitOpen)
+	require.Equal(t, 3, base.calls)
+
+	// 冷却结束进入半开，试探失败重新打开
+	now = now.Add(time.Minute)
+	require.Equal(t, CircuitHalfOpen, cb.State())
+	err := cb.Notify(ctx, nil)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, 4, base.calls)
+	require.Equal(t, CircuitOpen, cb.State())
+
+	// 再次冷却后试探成功，关闭熔断
+	now = now.Add(time.Minute)
+	base.fail = false
+	require.Equal(t, CircuitHalfOpen, cb.State())
+	require.NoError(t, cb.Notify(ctx, nil))
+	require.Equal(t, CircuitClosed, cb.State())
+
+	// 关闭后失败计数重新开始
+	base.fail = true
+	require.Error(t, cb.Notify(ctx, nil))
+	require.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestCircuitBreakerNotifier_SuccessResetsFailures(t *testing.T) {
+	base := &flakyNotifier{}
+	cb := NewCircuitBreakerNotifier(base, 2, time.Minute)
+	ctx := context.Background()
+
+	base.fail = true
+	require.Error(t, cb.Notify(ctx, nil))
+	base.fail = false
+	require.NoError(t, cb.Notify(ctx, nil))
+	base.fail = true
+	require.Error(t, cb.Notify(ctx, nil))
+	require.Equal(t, CircuitClosed, cb.State(), "failures must be consecutive to open the circuit")
+}
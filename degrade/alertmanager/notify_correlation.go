@@ -0,0 +1,132 @@
+package alertmanager
+
+import (
+	"context"
+	"strings"
+)
+
+// MetricValue 关联通知中单条规则的取值
+type MetricValue struct {
+	Rule     string            `json:"rule"`
+	Value    float64           `json:"value"`
+	Severity string            `json:"severity,omitempty"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// CorrelationNotifier 将同一批中来自不同规则、correlateBy 标签取值相同且状态相同的通知合并为一条，
+// 如同一 instance 的 CPU 与内存告警。合并后的通知：
+//   - Rule 为各规则名以逗号连接，Labels 为各通知共有且取值相同的标签
+//   - Metrics 按原顺序记录每条规则的取值，Value 取第一条
+//   - Severity 取最严重的级别，StartsAt 取最早的触发时间，EndsAt 取最晚的结束时间
+//   - 任一通知为 NotificationNew 时 Kind 为 NotificationNew，否则沿用第一条
+//
+// 缺少任一 correlateBy 标签或组内只有一条的通知原样发送。
+// 管理器按规则分别调用 Notify，需跨规则关联时在外层套一个 BatchingNotifier，使同一轮评估的通知进入同一批
+type CorrelationNotifier struct {
+	base        Notifier
+	correlateBy []string
+}
+
+func NewCorrelationNotifier(base Notifier, correlateBy ...string) *CorrelationNotifier {
+	return &CorrelationNotifier{base: base, correlateBy: correlateBy}
+}
+
+func (c *CorrelationNotifier) Notify(ctx context.Context, notifications []*Notification) error {
+	return c.base.Notify(ctx, c.correlate(notifications))
+}
+
+func (c *CorrelationNotifier) correlate(notifications []*Notification) []*Notification {
+	if len(c.correlateBy) == 0 {
+		return notifications
+	}
+	var (
+		order  []string
+		groups = make(map[string][]*Notification)
+		out    = make([]*Notification, 0, len(notifications))
+	)
+	for _, n := range notifications {
+		key, ok := c.key(n)
+		if !ok {
+			out = append(out, n)
+			continue
+		}
+		if _, seen := groups[key]; !seen {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], n)
+	}
+	for _, key := range order {
+		group := groups[key]
+		if len(group) == 1 {
+			out = append(out, group[0])
+			continue
+		}
+		out = append(out, combineNotifications(group))
+	}
+	return out
+}
+
+// key 由状态和 correlateBy 标签值组成，缺少标签时返回 false
+func (c *CorrelationNotifier) key(n *Notification) (string, bool) {
+	parts := make([]string, 0, len(c.correlateBy)+1)
+	parts = append(parts, n.Status)
+	for _, name := range c.correlateBy {
+		v, ok := n.Labels[name]
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, "\xff"), true
+}
+
+func combineNotifications(group []*Notification) *Notification {
+	first := group[0]
+	combined := &Notification{
+		Status:   first.Status,
+		Labels:   make(map[string]string, len(first.Labels)),
+		Severity: first.Severity,
+		Kind:     first.Kind,
+		Value:    first.Value,
+		StartsAt: first.StartsAt,
+		EndsAt:   first.EndsAt,
+		Metrics:  make([]MetricValue, 0, len(group)),
+	}
+	for k, v := range first.Labels {
+		combined.Labels[k] = v
+	}
+
+	var rules []string
+	seen := make(map[string]struct{}, len(group))
+	for _, n := range group {
+		if _, ok := seen[n.Rule]; !ok {
+			seen[n.Rule] = struct{}{}
+			rules = append(rules, n.Rule)
+		}
+		combined.Metrics = append(combined.Metrics, MetricValue{
+			Rule:     n.Rule,
+			Value:    n.Value,
+			Severity: n.Severity,
+			Labels:   n.Labels,
+		})
+		for k, v := range combined.Labels {
+			if n.Labels[k] != v {
+				delete(combined.Labels, k)
+			}
+		}
+		if n.Kind == NotificationNew {
+			combined.Kind = NotificationNew
+		}
+		if rankOf(n.Severity) < rankOf(combined.Severity) {
+			combined.Severity = n.Severity
+		}
+		if !n.StartsAt.IsZero() && (combined.StartsAt.IsZero() || n.StartsAt.Before(combined.StartsAt)) {
+			combined.StartsAt = n.StartsAt
+		}
+		if n.EndsAt.After(combined.EndsAt) {
+			combined.EndsAt = n.EndsAt
+		}
+	}
+	combined.Rule = strings.Join(rules, ",")
+	return combined
+}
@@ -0,0 +1,82 @@
+package alertmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelationNotifier_TwoRulesSameInstance(t *testing.T) {
+	cpu := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{})
+	cpu.Labels = labels.FromStrings("severity", "warning")
+	mem := newTestRule(t, "HighMem", "memory_usage > 0.9", &AlertOpts{})
+	mem.Labels = labels.FromStrings("severity", "critical")
+
+	results := map[string]promql.Vector{
+		"cpu_usage > 0.8": {
+			{Metric: labels.FromStrings("__name__", "cpu_usage", "instance", "host1", "core", "0"), F: 0.95},
+			{Metric: labels.FromStrings("__name__", "cpu_usage", "instance", "host2", "core", "0"), F: 0.85},
+		},
+		"memory_usage > 0.9": {
+			{Metric: labels.FromStrings("__name__", "memory_usage", "instance", "host1"), F: 0.97},
+		},
+	}
+	queryFn := func(_ context.Context, query string, _ time.Time) (promql.Vector, error) {
+		return results[query], nil
+	}
+
+	// 管理器按规则分别通知，先由 BatchingNotifier 汇总同一轮评估的通知再关联
+	recorder := &recordNotifier{}
+	notifier := NewBatchingNotifier(NewCorrelationNotifier(recorder, "instance"), 100*time.Millisecond, 0)
+	am := NewAlertManager([]*Rule{cpu, mem}, 20*time.Millisecond, queryFn, notifier, NewMemoryStorage())
+	require.NoError(t, am.Run())
+	defer am.Stop()
+
+	require.Eventually(t, func() bool { return len(recorder.All()) == 2 }, 2*time.Second, 10*time.Millisecond)
+	byInstance := make(map[string]*Notification)
+	for _, n := range recorder.All() {
+		byInstance[n.Labels["instance"]] = n
+	}
+
+	combined := byInstance["host1"]
+	require.NotNil(t, combined)
+	require.Contains(t, []string{"HighCPU,HighMem", "HighMem,HighCPU"}, combined.Rule)
+	require.Equal(t, "firing", combined.Status)
+	require.Equal(t, "critical", combined.Severity, "most severe level wins")
+	require.Equal(t, map[string]string{"instance": "host1"}, combined.Labels, "only labels shared by all alerts are kept")
+	require.Len(t, combined.Metrics, 2)
+	values := make(map[string]float64)
+	for _, m := range combined.Metrics {
+		values[m.Rule] = m.Value
+	}
+	require.Equal(t, map[string]float64{"HighCPU": 0.95, "HighMem": 0.97}, values)
+
+	single := byInstance["host2"]
+	require.NotNil(t, single)
+	require.Equal(t, "HighCPU", single.Rule, "uncorrelated notification is passed through")
+	require.Empty(t, single.Metrics)
+}
+
+func TestCorrelationNotifier_Grouping(t *testing.T) {
+	recorder := &recordNotifier{}
+	notifier := NewCorrelationNotifier(recorder, "instance")
+	t0 := time.Now()
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{
+		{Rule: "cpu", Status: "firing", Labels: map[string]string{"instance": "a"}, Value: 1, StartsAt: t0.Add(time.Minute)},
+		{Rule: "disk", Status: "inactive", Labels: map[string]string{"instance": "a"}, Value: 2},
+		{Rule: "mem", Status: "firing", Labels: map[string]string{"instance": "a"}, Value: 3, StartsAt: t0},
+		{Rule: "net", Status: "firing", Labels: map[string]string{"job": "x"}, Value: 4},
+	}))
+
+	got := recorder.All()
+	require.Len(t, got, 3)
+	require.Equal(t, "net", got[0].Rule, "notifications missing the correlation labels are sent as is")
+	require.Equal(t, "cpu,mem", got[1].Rule)
+	require.Equal(t, t0, got[1].StartsAt, "earliest start time")
+	require.Equal(t, 1.0, got[1].Value)
+	require.Equal(t, "disk", got[2].Rule, "firing and resolved notifications are not combined")
+}
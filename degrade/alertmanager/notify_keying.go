@@ -0,0 +1,54 @@
+package alertmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// KeyingNotifier 为通知填充 GroupKey，同一规则和分组标签始终得到相同的键，
+// 可作为 PagerDuty 等系统的事件去重键，跨进程重启保持稳定
+type KeyingNotifier struct {
+	base    Notifier
+	groupBy []string // 参与分组的标签名，为空时使用全部标签
+}
+
+func NewKeyingNotifier(base Notifier, groupBy ...string) *KeyingNotifier {
+	return &KeyingNotifier{base: base, groupBy: groupBy}
+}
+
+// Notify 复制通知后填充 GroupKey，不修改调用方的通知
+func (k *KeyingNotifier) Notify(ctx context.Context, notifications []*Notification) error {
+	keyed := make([]*Notification, len(notifications))
+	for i, n := range notifications {
+		c := *n
+		c.GroupKey = GroupKey(n.Rule, n.Labels, k.groupBy)
+		keyed[i] = &c
+	}
+	return k.base.Notify(ctx, keyed)
+}
+
+// GroupKey 根据规则名和分组标签计算稳定的键，groupBy 为空时使用全部标签，缺失的标签按空值处理
+func GroupKey(rule string, lbs map[string]string, groupBy []string) string {
+	names := groupBy
+	if len(names) == 0 {
+		names = make([]string, 0, len(lbs))
+		for name := range lbs {
+			names = append(names, name)
+		}
+	}
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+
+	h := sha256.New()
+	// 以 0xff 分隔各字段，标签名和值中不会出现该字节，避免拼接歧义
+	h.Write([]byte(rule))
+	for _, name := range names {
+		h.Write([]byte{0xff})
+		h.Write([]byte(name))
+		h.Write([]byte{0xff})
+		h.Write([]byte(lbs[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
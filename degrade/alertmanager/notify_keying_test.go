@@ -0,0 +1,62 @@
+package alertmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyingNotifier_StableGroupKey(t *testing.T) {
+	newNotification := func(instance, pod string) *Notification {
+		rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{})
+		vector := promql.Vector{{Metric: labels.FromStrings("instance", instance, "pod", pod), F: 0.9}}
+		alerts, err := rule.EvalVector(context.Background(), time.Now(), vector)
+		require.NoError(t, err)
+		require.Len(t, alerts, 1)
+		return NewNotification(rule, alerts[0])
+	}
+
+	recorder := &recordNotifier{}
+	notifier := NewKeyingNotifier(recorder, "instance")
+
+	// 两次独立构造的规则和告警得到相同的键，与 pod 标签无关
+	first, second, other := newNotification("host1", "a"), newNotification("host1", "b"), newNotification("host2", "a")
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{first, second, other}))
+	sent := recorder.All()
+	require.Len(t, sent, 3)
+	require.NotEmpty(t, sent[0].GroupKey)
+	require.Equal(t, sent[0].GroupKey, sent[1].GroupKey)
+	require.NotEqual(t, sent[0].GroupKey, sent[2].GroupKey)
+	require.Empty(t, first.GroupKey, "caller's notifications are not modified")
+
+	// 键只依赖内容，固定输入得到固定输出
+	require.Equal(t, "67f62c76d53fe993c8ff173722b062d4", GroupKey("HighCPU", map[string]string{"instance": "host1"}, nil))
+	require.NotEqual(t, GroupKey("HighCPU", map[string]string{"instance": "host1"}, nil),
+		GroupKey("HighMem", map[string]string{"instance": "host1"}, nil))
+}
+
+func TestKeyingNotifier_ParallelMultiNotifier(t *testing.T) {
+	recorders := []*recordNotifier{{}, {}, {}}
+	multi := NewMultiNotifier(
+		NewKeyingNotifier(recorders[0], "instance"),
+		NewKeyingNotifier(recorders[1], "pod"),
+		recorders[2],
+	)
+	multi.Parallel = true
+
+	// 并发的 KeyingNotifier 共享同一批通知，-race 下不应出现数据竞争
+	batch := []*Notification{
+		{Rule: "HighCPU", Labels: map[string]string{"instance": "host1", "pod": "a"}},
+		{Rule: "HighCPU", Labels: map[string]string{"instance": "host2", "pod": "a"}},
+	}
+	require.NoError(t, multi.Notify(context.Background(), batch))
+
+	require.Equal(t, GroupKey("HighCPU", batch[0].Labels, []string{"instance"}), recorders[0].All()[0].GroupKey)
+	require.Equal(t, GroupKey("HighCPU", batch[0].Labels, []string{"pod"}), recorders[1].All()[0].GroupKey)
+	require.Empty(t, recorders[2].All()[0].GroupKey)
+	require.Empty(t, batch[0].GroupKey)
+}
@@ -0,0 +1,64 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MultiNotifier 将同一批通知发送到多个通知器，如同时发送到 webhook 和日志。
+// 某个通知器失败不影响其他通知器，全部错误通过 errors.Join 合并返回。
+// 各通知器共享同一个 notifications 切片，不应修改其内容
+type MultiNotifier struct {
+	notifiers []Notifier
+
+	// Parallel 并发调用各通知器，默认按顺序调用；并发时 ctx 取消会传递给所有进行中的调用
+	Parallel bool
+}
+
+// NewMultiNotifier 创建扇出通知器，nil 通知器被忽略
+func NewMultiNotifier(notifiers ...Notifier) *MultiNotifier {
+	m := &MultiNotifier{}
+	for _, n := range notifiers {
+		if n != nil {
+			m.notifiers = append(m.notifiers, n)
+		}
+	}
+	return m
+}
+
+func (m *MultiNotifier) Notify(ctx context.Context, notifications []*Notification) error {
+	if m.Parallel {
+		return m.notifyParallel(ctx, notifications)
+	}
+	var errs []error
+	for i, n := range m.notifiers {
+		// ctx 已结束时不再调用剩余的通知器
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("notifier %d: %w", i, err))
+			continue
+		}
+		if err := n.Notify(ctx, notifications); err != nil {
+			errs = append(errs, fmt.Errorf("notifier %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notifyParallel 等待全部调用返回，ctx 取消时依赖各通知器响应 ctx 及时结束
+func (m *MultiNotifier) notifyParallel(ctx context.Context, notifications []*Notification) error {
+	errs := make([]error, len(m.notifiers))
+	var wg sync.WaitGroup
+	for i, n := range m.notifiers {
+		wg.Add(1)
+		go func(i int, n Notifier) {
+			defer wg.Done()
+			if err := n.Notify(ctx, notifications); err != nil {
+				errs[i] = fmt.Errorf("notifier %d: %w", i, err)
+			}
+		}(i, n)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
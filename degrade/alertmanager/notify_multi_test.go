@@ -0,0 +1,80 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ctxNotifier 阻塞直到 ctx 结束
+type ctxNotifier struct {
+	started chan struct{}
+}
+
+func (n *ctxNotifier) Notify(ctx context.Context, _ []*Notification) error {
+	close(n.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func failingNotifier(err error) Notifier {
+	n := &blockingNotifier{release: make(chan struct{}), err: err}
+	close(n.release)
+	return n
+}
+
+func TestMultiNotifier(t *testing.T) {
+	errWebhook := errors.New("webhook down")
+	errLog := errors.New("log sink full")
+	notifications := []*Notification{{Rule: "HighCPU", Status: "firing"}}
+
+	for _, parallel := range []bool{false, true} {
+		first, last := &recordNotifier{}, &recordNotifier{}
+		multi := NewMultiNotifier(first, failingNotifier(errWebhook), nil, failingNotifier(errLog), last)
+		multi.Parallel = parallel
+
+		err := multi.Notify(context.Background(), notifications)
+		require.ErrorIs(t, err, errWebhook, "parallel=%v", parallel)
+		require.ErrorIs(t, err, errLog, "parallel=%v", parallel)
+		require.Equal(t, notifications, first.All())
+		require.Equal(t, notifications, last.All(), "a failing sink does not stop the others")
+
+		require.NoError(t, NewMultiNotifier(first, last).Notify(context.Background(), notifications))
+	}
+}
+
+func TestMultiNotifier_ParallelCancel(t *testing.T) {
+	blocking := &ctxNotifier{started: make(chan struct{})}
+	recorder := &recordNotifier{}
+	multi := NewMultiNotifier(blocking, recorder)
+	multi.Parallel = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- multi.Notify(ctx, []*Notification{{Rule: "HighCPU"}})
+	}()
+
+	<-blocking.started
+	cancel()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("in-flight send was not cancelled")
+	}
+	require.Len(t, recorder.All(), 1, "other sinks are still notified")
+}
+
+func TestMultiNotifier_SequentialCancelled(t *testing.T) {
+	recorder := &recordNotifier{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewMultiNotifier(recorder).Notify(ctx, []*Notification{{Rule: "HighCPU"}})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Empty(t, recorder.All())
+}
@@ -0,0 +1,158 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultSlackTemplate 默认的 Slack 附件标题模板，按单条通知渲染
+const DefaultSlackTemplate = `[{{ statusText .Status }}] {{ .Rule }}`
+
+// Slack 附件颜色：触发为红色，恢复为绿色，pending 为橙色
+const (
+	slackColorFiring   = "#e01e5a"
+	slackColorResolved = "#2eb67d"
+	slackColorPending  = "#ecb22e"
+)
+
+// SlackNotifier 将通知转换为 Slack 消息并发送到 incoming webhook。
+// 同一规则的通知合并为一条消息，每条通知对应一个附件，附件颜色取决于状态，
+// 字段包含 Value、StartsAt 和各标签
+type SlackNotifier struct {
+	webhookURL string
+	tmpl       *template.Template
+
+	// Client 发送请求使用的 HTTP 客户端，为空时使用 http.DefaultClient
+	Client *http.Client
+}
+
+// NewSlackNotifier 创建 Slack 通知器，text 为附件标题模板，为空时使用 DefaultSlackTemplate
+func NewSlackNotifier(webhookURL, text string) (*SlackNotifier, error) {
+	if text == "" {
+		text = DefaultSlackTemplate
+	}
+	tmpl, err := template.New("slack").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse slack template: %w", err)
+	}
+	return &SlackNotifier{webhookURL: webhookURL, tmpl: tmpl}, nil
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Title  string       `json:"title"`
+	Fields []slackField `json:"fields"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func (s *SlackNotifier) Notify(ctx context.Context, notifications []*Notification) error {
+	var (
+		order  []string
+		byRule = make(map[string][]*Notification)
+	)
+	for _, n := range notifications {
+		if _, ok := byRule[n.Rule]; !ok {
+			order = append(order, n.Rule)
+		}
+		byRule[n.Rule] = append(byRule[n.Rule], n)
+	}
+	for _, rule := range order {
+		msg, err := s.message(rule, byRule[rule])
+		if err != nil {
+			return err
+		}
+		if err := s.post(ctx, msg); err != nil {
+			return fmt.Errorf("failed to send slack message for rule %s: %w", rule, err)
+		}
+	}
+	return nil
+}
+
+// message 将同一规则的通知转换为一条 Slack 消息
+func (s *SlackNotifier) message(rule string, notifications []*Notification) (*slackMessage, error) {
+	msg := &slackMessage{
+		Text:        fmt.Sprintf("*%s*: %d alert(s)", rule, len(notifications)),
+		Attachments: make([]slackAttachment, 0, len(notifications)),
+	}
+	for _, n := range notifications {
+		var title strings.Builder
+		if err := s.tmpl.Execute(&title, n); err != nil {
+			return nil, fmt.Errorf("failed to render slack notification: %w", err)
+		}
+		fields := []slackField{{Title: "Value", Value: fmt.Sprint(n.Value), Short: true}}
+		if !n.StartsAt.IsZero() {
+			fields = append(fields, slackField{Title: "StartsAt", Value: n.StartsAt.Format(time.RFC3339), Short: true})
+		}
+		keys := make([]string, 0, len(n.Labels))
+		for k := range n.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fields = append(fields, slackField{Title: k, Value: n.Labels[k], Short: true})
+		}
+		msg.Attachments = append(msg.Attachments, slackAttachment{
+			Color:  slackColor(n.Status),
+			Title:  title.String(),
+			Fields: fields,
+		})
+	}
+	return msg, nil
+}
+
+func slackColor(status string) string {
+	switch AlertState(status) {
+	case AlertStateInactive, AlertStateL0:
+		return slackColorResolved
+	case AlertStatePending:
+		return slackColorPending
+	default:
+		return slackColorFiring
+	}
+}
+
+func (s *SlackNotifier) post(ctx context.Context, msg *slackMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal slack message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,130 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+type slackRecorder struct {
+	mtx      sync.Mutex
+	messages []slackMessage
+	status   int
+}
+
+func (r *slackRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var msg slackMessage
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil || req.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.messages = append(r.messages, msg)
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+		_, _ = w.Write([]byte("invalid_payload"))
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	recorder := &slackRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	notifier, err := NewSlackNotifier(server.URL, "")
+	require.NoError(t, err)
+
+	startsAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{
+		{Rule: "HighCPU", Status: "firing", Value: 0.95, StartsAt: startsAt, Labels: map[string]string{"instance": "host1", "alertname": "HighCPU"}},
+		{Rule: "HighMem", Status: "pending", Value: 0.8, Labels: map[string]string{"instance": "host1"}},
+		{Rule: "HighCPU", Status: "inactive", Value: 0.5, Labels: map[string]string{"instance": "host2"}},
+	}))
+
+	require.Len(t, recorder.messages, 2, "notifications of the same rule are grouped")
+	cpu := recorder.messages[0]
+	require.Equal(t, "*HighCPU*: 2 alert(s)", cpu.Text)
+	require.Len(t, cpu.Attachments, 2)
+
+	firing := cpu.Attachments[0]
+	require.Equal(t, slackColorFiring, firing.Color)
+	require.Equal(t, "[FIRING] HighCPU", firing.Title)
+	require.Equal(t, []slackField{
+		{Title: "Value", Value: "0.95", Short: true},
+		{Title: "StartsAt", Value: "2024-01-01T12:00:00Z", Short: true},
+		{Title: "alertname", Value: "HighCPU", Short: true},
+		{Title: "instance", Value: "host1", Short: true},
+	}, firing.Fields)
+
+	resolved := cpu.Attachments[1]
+	require.Equal(t, slackColorResolved, resolved.Color)
+	require.Equal(t, "[RESOLVED] HighCPU", resolved.Title)
+
+	mem := recorder.messages[1]
+	require.Len(t, mem.Attachments, 1)
+	require.Equal(t, slackColorPending, mem.Attachments[0].Color)
+}
+
+func TestSlackNotifier_CustomTemplate(t *testing.T) {
+	recorder := &slackRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	notifier, err := NewSlackNotifier(server.URL, `{{ .Rule }} on {{ .Labels.instance }} is {{ statusText .Status }}`)
+	require.NoError(t, err)
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{
+		{Rule: "Overload", Status: "l2", Labels: map[string]string{"instance": "host1"}},
+	}))
+	require.Len(t, recorder.messages, 1)
+	require.Equal(t, "Overload on host1 is L2", recorder.messages[0].Attachments[0].Title)
+	require.Equal(t, slackColorFiring, recorder.messages[0].Attachments[0].Color)
+
+	_, err = NewSlackNotifier(server.URL, "{{ .Rule")
+	require.Error(t, err)
+}
+
+func TestSlackNotifier_ErrorStatus(t *testing.T) {
+	recorder := &slackRecorder{status: http.StatusBadRequest}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	notifier, err := NewSlackNotifier(server.URL, "")
+	require.NoError(t, err)
+	err = notifier.Notify(context.Background(), []*Notification{{Rule: "HighCPU", Status: "firing"}})
+	require.ErrorContains(t, err, "400")
+	require.ErrorContains(t, err, "invalid_payload")
+}
+
+func TestSlackNotifier_MultiTierStartsAt(t *testing.T) {
+	recorder := &slackRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	notifier, err := NewSlackNotifier(server.URL, "")
+	require.NoError(t, err)
+
+	rule := newTestRule(t, "HighQPS", "qps > 1000", &AlertOpts{})
+	rule.AlertType = AlertTypeMultiTier
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	alerts, err := rule.EvalVector(context.Background(), t0, promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: 2000}})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateL1, alerts[0].State())
+
+	n := NewNotification(rule, alerts[0])
+	require.Equal(t, t0, n.StartsAt, "degrade alerts start when the current level was entered")
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{n}))
+
+	require.Len(t, recorder.messages, 1)
+	require.Contains(t, recorder.messages[0].Attachments[0].Fields,
+		slackField{Title: "StartsAt", Value: t0.Format(time.RFC3339), Short: true})
+}
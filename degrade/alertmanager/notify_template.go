@@ -0,0 +1,85 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// DefaultNotificationTemplate 默认的 Markdown 通知模板，适用于 Slack/Teams 等聊天工具
+const DefaultNotificationTemplate = `**[{{ statusText .Status }}]** {{ .Rule }}
+> Value: ` + "`{{ .Value }}`" + `
+{{- if .Labels }}
+> Labels: {{ labelList .Labels }}
+{{- end }}
+{{- with index .Metadata "link" }}
+[Details]({{ . }})
+{{- end }}`
+
+var templateFuncs = template.FuncMap{
+	"statusText": func(status string) string {
+		if AlertState(status) == AlertStateInactive {
+			return "RESOLVED"
+		}
+		return strings.ToUpper(status)
+	},
+	"labelList": func(lbs map[string]string) string {
+		keys := make([]string, 0, len(lbs))
+		for k := range lbs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(keys))
+		for _, k := range keys {
+			items = append(items, fmt.Sprintf("`%s=%s`", k, lbs[k]))
+		}
+		return strings.Join(items, ", ")
+	},
+}
+
+// TextSender 接收渲染后文本的发送端
+type TextSender interface {
+	SendText(ctx context.Context, text string) error
+}
+
+// TemplateNotifier 使用模板将通知渲染为文本后交给 TextSender 发送
+type TemplateNotifier struct {
+	tmpl   *template.Template
+	sender TextSender
+}
+
+// NewTemplateNotifier 创建模板通知器，text 为空时使用默认模板
+func NewTemplateNotifier(sender TextSender, text string) (*TemplateNotifier, error) {
+	if text == "" {
+		text = DefaultNotificationTemplate
+	}
+	tmpl, err := template.New("notification").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notification template: %w", err)
+	}
+	return &TemplateNotifier{tmpl: tmpl, sender: sender}, nil
+}
+
+// Render 渲染单条通知
+func (t *TemplateNotifier) Render(n *Notification) (string, error) {
+	var buf strings.Builder
+	if err := t.tmpl.Execute(&buf, n); err != nil {
+		return "", fmt.Errorf("failed to render notification: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func (t *TemplateNotifier) Notify(ctx context.Context, notifications []*Notification) error {
+	for _, n := range notifications {
+		text, err := t.Render(n)
+		if err != nil {
+			return err
+		}
+		if err := t.sender.SendText(ctx, text); err != nil {
+			return fmt.Errorf("failed to send notification text: %w", err)
+		}
+	}
+	return nil
+}
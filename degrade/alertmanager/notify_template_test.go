@@ -0,0 +1,61 @@
+package alertmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordSender struct {
+	texts []string
+}
+
+func (s *recordSender) SendText(_ context.Context, text string) error {
+	s.texts = append(s.texts, text)
+	return nil
+}
+
+func TestTemplateNotifier_DefaultTemplate(t *testing.T) {
+	sender := &recordSender{}
+	notifier, err := NewTemplateNotifier(sender, "")
+	require.NoError(t, err)
+
+	firing := &Notification{
+		Rule:     "HighCPU",
+		Status:   string(AlertStateFiring),
+		Labels:   map[string]string{"instance": "host1", "alertname": "HighCPU"},
+		Metadata: map[string]string{"link": "http://grafana/d/cpu"},
+		Value:    0.92,
+	}
+	resolved := &Notification{
+		Rule:   "HighCPU",
+		Status: string(AlertStateInactive),
+		Labels: map[string]string{"instance": "host1"},
+		Value:  0.3,
+	}
+	require.NoError(t, notifier.Notify(context.Background(), []*Notification{firing, resolved}))
+
+	require.Equal(t, []string{
+		"**[FIRING]** HighCPU\n" +
+			"> Value: `0.92`\n" +
+			"> Labels: `alertname=HighCPU`, `instance=host1`\n" +
+			"[Details](http://grafana/d/cpu)",
+		"**[RESOLVED]** HighCPU\n" +
+			"> Value: `0.3`\n" +
+			"> Labels: `instance=host1`",
+	}, sender.texts)
+}
+
+func TestTemplateNotifier_CustomTemplate(t *testing.T) {
+	sender := &recordSender{}
+	notifier, err := NewTemplateNotifier(sender, `{{ .Rule }} is {{ statusText .Status }}`)
+	require.NoError(t, err)
+
+	text, err := notifier.Render(&Notification{Rule: "HighCPU", Status: string(AlertStateFiring)})
+	require.NoError(t, err)
+	require.Equal(t, "HighCPU is FIRING", text)
+
+	_, err = NewTemplateNotifier(sender, `{{ .Rule `)
+	require.Error(t, err)
+}
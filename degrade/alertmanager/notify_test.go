@@ -0,0 +1,127 @@
+package alertmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNotification_MetadataLabels(t *testing.T) {
+	rule := newTestRule(t, "DiskFull", "disk_used / disk_total > 0.9", &AlertOpts{})
+	rule.MetadataLabels = []string{"device"}
+
+	vector := promql.Vector{{Metric: labels.FromStrings("__name__", "disk_used", "instance", "host1", "device", "sda1"), F: 0.95}}
+	alerts, err := rule.EvalVector(context.Background(), time.Now(), vector)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+
+	n := NewNotification(rule, alerts[0])
+	require.Equal(t, map[string]string{"device": "sda1"}, n.Metadata)
+	require.Equal(t, map[string]string{"alertname": "DiskFull", "instance": "host1"}, n.Labels)
+
+	// 元数据标签变化不产生新告警，但会更新通知内容
+	vector[0].Metric = labels.FromStrings("__name__", "disk_used", "instance", "host1", "device", "sdb1")
+	_, err = rule.EvalVector(context.Background(), time.Now(), vector)
+	require.NoError(t, err)
+	require.Len(t, rule.active, 1)
+	require.Equal(t, "sdb1", NewNotification(rule, alerts[0]).Metadata["device"])
+
+	// 元数据随告警持久化
+	data, err := alerts[0].Marshal()
+	require.NoError(t, err)
+	var restored Alert
+	require.NoError(t, restored.Restore(data, rule.AlertOpts))
+	require.Equal(t, map[string]string{"device": "sdb1"}, restored.Metadata())
+}
+
+func TestNewNotification_Severity(t *testing.T) {
+	rule := newTestRule(t, "HighLatency", "latency > 1", &AlertOpts{})
+	rule.Labels = labels.FromStrings("severity", "warning")
+
+	vector := promql.Vector{
+		{Metric: labels.FromStrings("instance", "host1"), F: 2},
+		{Metric: labels.FromStrings("instance", "host2", "severity", "critical"), F: 3},
+	}
+	alerts, err := rule.EvalVector(context.Background(), time.Now(), vector)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+
+	severities := make(map[string]string)
+	for _, alert := range alerts {
+		n := NewNotification(rule, alert)
+		severities[n.Labels["instance"]] = n.Severity
+	}
+	// 样本自带的级别优先于规则标签
+	require.Equal(t, map[string]string{"host1": "warning", "host2": "critical"}, severities)
+
+	// 自定义级别标签，缺失时为空
+	rule.SeverityLabel = "priority"
+	require.Empty(t, NewNotification(rule, alerts[0]).Severity)
+	rule.SeverityLabel = "instance"
+	require.Contains(t, []string{"host1", "host2"}, NewNotification(rule, alerts[0]).Severity)
+}
+
+func TestNewNotification_Kind(t *testing.T) {
+	rule := newTestRule(t, "HighLatency", "latency > 1", &AlertOpts{ResendDelay: time.Minute})
+	alert, err := NewAlert(AlertTypeBasic, labels.FromStrings("instance", "host1"), rule.AlertOpts)
+	require.NoError(t, err)
+
+	t0 := time.Now()
+	send, err := alert.Transition(context.Background(), true, t0)
+	require.NoError(t, err)
+	require.True(t, send)
+	require.Equal(t, NotificationNew, NewNotification(rule, alert).Kind, "first notification")
+
+	send, err = alert.Transition(context.Background(), true, t0.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.True(t, send)
+	require.Equal(t, NotificationResend, NewNotification(rule, alert).Kind, "repeated while firing")
+
+	send, err = alert.Transition(context.Background(), true, t0.Add(4*time.Minute))
+	require.NoError(t, err)
+	require.True(t, send)
+	require.Equal(t, NotificationResend, NewNotification(rule, alert).Kind)
+
+	send, err = alert.Transition(context.Background(), false, t0.Add(5*time.Minute))
+	require.NoError(t, err)
+	require.True(t, send)
+	require.Equal(t, NotificationResolved, NewNotification(rule, alert).Kind)
+
+	// 恢复后再次触发视为新告警
+	send, err = alert.Transition(context.Background(), true, t0.Add(6*time.Minute))
+	require.NoError(t, err)
+	require.True(t, send)
+	require.Equal(t, NotificationNew, NewNotification(rule, alert).Kind)
+}
+
+func TestNewNotification_Kind_Degrade(t *testing.T) {
+	rule := newTestRule(t, "Overload", "qps > 100", &AlertOpts{HoldDuration: time.Minute, ResendDelay: 3 * time.Minute})
+	alert, err := NewAlert(AlertTypeMultiTier, labels.FromStrings("instance", "host1"), rule.AlertOpts)
+	require.NoError(t, err)
+
+	t0 := time.Now()
+	var kinds []NotificationKind
+	for i := 0; i < 4; i++ {
+		send, err := alert.Transition(context.Background(), true, t0.Add(time.Duration(i)*2*time.Minute))
+		require.NoError(t, err)
+		if send {
+			kinds = append(kinds, NewNotification(rule, alert).Kind)
+		}
+	}
+	require.Equal(t, AlertStateL3, alert.State())
+	require.NotEmpty(t, kinds)
+	for _, kind := range kinds {
+		require.Equal(t, NotificationNew, kind, "each escalation is a new notification")
+	}
+
+	// 停留在 L3 到达重发间隔
+	send, err := alert.Transition(context.Background(), true, t0.Add(10*time.Minute))
+	require.NoError(t, err)
+	require.True(t, send)
+	require.Equal(t, AlertStateL3, alert.State())
+	require.Equal(t, NotificationResend, NewNotification(rule, alert).Kind)
+}
@@ -0,0 +1,36 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TimeoutNotifier 为每次 Notify 设置超时，超时后立即返回，避免卡住的通知渠道阻塞评估
+// 底层实现忽略 ctx 时其调用仍会在后台继续执行直至返回
+type TimeoutNotifier struct {
+	base    Notifier
+	timeout time.Duration
+}
+
+func NewTimeoutNotifier(base Notifier, timeout time.Duration) *TimeoutNotifier {
+	return &TimeoutNotifier{base: base, timeout: timeout}
+}
+
+// Notify 超时时返回包装了 context.DeadlineExceeded 的错误
+func (t *TimeoutNotifier) Notify(ctx context.Context, notifications []*Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- t.base.Notify(ctx, notifications)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("notify %d notifications after %v: %w", len(notifications), t.timeout, ctx.Err())
+	}
+}
@@ -0,0 +1,37 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blockingNotifier 忽略 ctx，直到 release 关闭才返回
+type blockingNotifier struct {
+	release chan struct{}
+	err     error
+}
+
+func (n *blockingNotifier) Notify(context.Context, []*Notification) error {
+	<-n.release
+	return n.err
+}
+
+func TestTimeoutNotifier(t *testing.T) {
+	slow := &blockingNotifier{release: make(chan struct{})}
+	defer close(slow.release)
+
+	notifier := NewTimeoutNotifier(slow, 20*time.Millisecond)
+	start := time.Now()
+	err := notifier.Notify(context.Background(), []*Notification{{Rule: "r"}})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second, "should return at the timeout, not when the sink unblocks")
+
+	errSend := errors.New("send failed")
+	fast := &blockingNotifier{release: make(chan struct{}), err: errSend}
+	close(fast.release)
+	require.ErrorIs(t, NewTimeoutNotifier(fast, time.Second).Notify(context.Background(), nil), errSend)
+}
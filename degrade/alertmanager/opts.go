@@ -0,0 +1,113 @@
+package alertmanager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// ValidateOpts 检查 opts 对 typ 类型告警的内部一致性。
+// 无法运行的组合（负的时长、乱序阈值等）作为错误返回；
+// 能运行但可疑的组合（字段对该类型无效、时长关系不合理）只记录警告日志
+func ValidateOpts(typ AlertType, opts *AlertOpts) error {
+	warnings, err := checkOpts(typ, opts)
+	for _, w := range warnings {
+		log.Printf("Warning: %s alert opts: %s", typ, w)
+	}
+	return err
+}
+
+// checkOpts 返回警告和合并后的错误
+func checkOpts(typ AlertType, opts *AlertOpts) ([]string, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("%w: nil opts", ErrInvalidOpts)
+	}
+	var (
+		errs     []error
+		warnings []string
+	)
+	for _, d := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"hold", opts.HoldDuration},
+		{"keepFiringFor", opts.KeepFiringFor},
+		{"resendDelay", opts.ResendDelay},
+		{"recoverDuration", opts.RecoverDuration},
+		{"autoRecoverAfter", opts.AutoRecoverAfter},
+	} {
+		if d.d < 0 {
+			errs = append(errs, fmt.Errorf("%w: %s %v", ErrInvalidDuration, d.name, d.d))
+		}
+	}
+	for state, d := range opts.LevelResendDelay {
+		if _, ok := state.DegradeLevel(); !ok {
+			errs = append(errs, fmt.Errorf("%w: level resend delay for %q is not a degrade level", ErrInvalidOpts, state))
+		}
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("%w: level resend delay for %s %v", ErrInvalidDuration, state, d))
+		}
+	}
+	if opts.Epsilon < 0 {
+		errs = append(errs, fmt.Errorf("%w: negative epsilon %v", ErrInvalidOpts, opts.Epsilon))
+	}
+	if len(opts.LevelThresholds) > len(degradeStates)-1 {
+		errs = append(errs, fmt.Errorf("%w: at most %d level thresholds allowed", ErrInvalidOpts, len(degradeStates)-1))
+	}
+	for i := 1; i < len(opts.LevelThresholds); i++ {
+		if opts.LevelThresholds[i] <= opts.LevelThresholds[i-1] {
+			errs = append(errs, fmt.Errorf("%w: level thresholds must be strictly ascending", ErrInvalidOpts))
+			break
+		}
+	}
+	switch opts.KeepFiringMode {
+	case "", KeepFiringAutoResolve, KeepFiringExtend:
+	default:
+		errs = append(errs, fmt.Errorf("%w: unknown keepFiringMode %q", ErrInvalidOpts, opts.KeepFiringMode))
+	}
+	if opts.RecoverWithoutSignal && opts.AutoRecoverAfter <= 0 {
+		errs = append(errs, fmt.Errorf("%w: recoverWithoutSignal requires autoRecoverAfter", ErrInvalidOpts))
+	}
+
+	switch typ {
+	case AlertTypeBasic:
+		for name, set := range map[string]bool{
+			"recoverDuration":      opts.RecoverDuration != 0,
+			"autoRecoverAfter":     opts.AutoRecoverAfter != 0,
+			"sustainedRecover":     opts.SustainedRecover,
+			"recoverWithoutSignal": opts.RecoverWithoutSignal,
+			"levelThresholds":      len(opts.LevelThresholds) > 0,
+			"levelResendDelay":     len(opts.LevelResendDelay) > 0,
+			"epsilon":              opts.Epsilon != 0,
+		} {
+			if set {
+				warnings = append(warnings, name+" is ignored")
+			}
+		}
+		if opts.KeepFiringMode != "" && opts.KeepFiringFor == 0 {
+			warnings = append(warnings, "keepFiringMode is ignored without keepFiringFor")
+		}
+		if opts.KeepFiringMode != KeepFiringExtend && opts.KeepFiringFor > 0 && opts.KeepFiringFor < opts.HoldDuration {
+			warnings = append(warnings, fmt.Sprintf("keepFiringFor %v is shorter than hold %v", opts.KeepFiringFor, opts.HoldDuration))
+		}
+	case AlertTypeMultiTier:
+		if opts.KeepFiringFor != 0 {
+			warnings = append(warnings, "keepFiringFor is ignored")
+		}
+		if opts.KeepFiringMode != "" {
+			warnings = append(warnings, "keepFiringMode is ignored")
+		}
+		if opts.Epsilon != 0 && len(opts.LevelThresholds) == 0 {
+			warnings = append(warnings, "epsilon is ignored without levelThresholds")
+		}
+		if opts.AutoRecoverAfter > 0 && opts.AutoRecoverAfter < opts.RecoverDuration {
+			warnings = append(warnings, fmt.Sprintf("autoRecoverAfter %v is shorter than recoverDuration %v", opts.AutoRecoverAfter, opts.RecoverDuration))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%w: %s", ErrUnsupportedAlertType, typ))
+	}
+	sort.Strings(warnings)
+	return warnings, errors.Join(errs...)
+}
@@ -0,0 +1,117 @@
+package alertmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateOpts_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  AlertType
+		opts *AlertOpts
+		is   error
+		msg  string
+	}{
+		{"nil opts", AlertTypeBasic, nil, ErrInvalidOpts, "nil opts"},
+		{"negative hold", AlertTypeBasic, &AlertOpts{HoldDuration: -time.Second}, ErrInvalidDuration, "hold -1s"},
+		{"negative recover", AlertTypeMultiTier, &AlertOpts{RecoverDuration: -time.Minute}, ErrInvalidDuration, "recoverDuration -1m0s"},
+		{
+			"non-level resend delay", AlertTypeMultiTier,
+			&AlertOpts{LevelResendDelay: map[AlertState]time.Duration{AlertStateFiring: time.Minute}},
+			ErrInvalidOpts, `level resend delay for "firing" is not a degrade level`,
+		},
+		{"negative epsilon", AlertTypeMultiTier, &AlertOpts{Epsilon: -0.1}, ErrInvalidOpts, "negative epsilon"},
+		{
+			"unordered thresholds", AlertTypeMultiTier,
+			&AlertOpts{LevelThresholds: []float64{0.8, 0.5}},
+			ErrInvalidOpts, "level thresholds must be strictly ascending",
+		},
+		{
+			"too many thresholds", AlertTypeMultiTier,
+			&AlertOpts{LevelThresholds: []float64{0.1, 0.2, 0.3, 0.4}},
+			ErrInvalidOpts, "at most 3 level thresholds allowed",
+		},
+		{
+			"signal recovery without timeout", AlertTypeMultiTier,
+			&AlertOpts{RecoverWithoutSignal: true},
+			ErrInvalidOpts, "recoverWithoutSignal requires autoRecoverAfter",
+		},
+		{"unsupported type", AlertTypeCustom, &AlertOpts{}, ErrUnsupportedAlertType, "custom"},
+		{"unknown keep firing mode", AlertTypeBasic, &AlertOpts{KeepFiringMode: "forever"}, ErrInvalidOpts, `unknown keepFiringMode "forever"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOpts(tt.typ, tt.opts)
+			require.ErrorIs(t, err, tt.is)
+			require.ErrorContains(t, err, tt.msg)
+
+			_, err = NewAlert(tt.typ, labels.EmptyLabels(), tt.opts)
+			require.ErrorIs(t, err, tt.is, "NewAlert rejects the same opts")
+		})
+	}
+
+	// 多个问题一并返回
+	err := ValidateOpts(AlertTypeMultiTier, &AlertOpts{HoldDuration: -time.Second, Epsilon: -1})
+	require.ErrorIs(t, err, ErrInvalidDuration)
+	require.ErrorIs(t, err, ErrInvalidOpts)
+}
+
+func TestValidateOpts_Warnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      AlertType
+		opts     *AlertOpts
+		warnings []string
+	}{
+		{"consistent basic", AlertTypeBasic, &AlertOpts{HoldDuration: time.Minute, KeepFiringFor: 5 * time.Minute}, nil},
+		{
+			"consistent multi-tier", AlertTypeMultiTier,
+			&AlertOpts{HoldDuration: time.Minute, RecoverDuration: time.Minute, AutoRecoverAfter: time.Hour},
+			nil,
+		},
+		{
+			"keep firing shorter than hold", AlertTypeBasic,
+			&AlertOpts{HoldDuration: 5 * time.Minute, KeepFiringFor: time.Minute},
+			[]string{"keepFiringFor 1m0s is shorter than hold 5m0s"},
+		},
+		{
+			"degrade fields on basic", AlertTypeBasic,
+			&AlertOpts{RecoverDuration: time.Minute, SustainedRecover: true},
+			[]string{"recoverDuration is ignored", "sustainedRecover is ignored"},
+		},
+		{
+			"extend mode allows keep firing shorter than hold", AlertTypeBasic,
+			&AlertOpts{HoldDuration: 5 * time.Minute, KeepFiringFor: time.Minute, KeepFiringMode: KeepFiringExtend},
+			nil,
+		},
+		{"keep firing mode without duration", AlertTypeBasic, &AlertOpts{KeepFiringMode: KeepFiringExtend}, []string{"keepFiringMode is ignored without keepFiringFor"}},
+		{"keep firing on multi-tier", AlertTypeMultiTier, &AlertOpts{KeepFiringFor: time.Minute}, []string{"keepFiringFor is ignored"}},
+		{"epsilon without thresholds", AlertTypeMultiTier, &AlertOpts{Epsilon: 0.1}, []string{"epsilon is ignored without levelThresholds"}},
+		{
+			"auto recover shorter than recover", AlertTypeMultiTier,
+			&AlertOpts{RecoverDuration: 10 * time.Minute, AutoRecoverAfter: time.Minute},
+			[]string{"autoRecoverAfter 1m0s is shorter than recoverDuration 10m0s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := checkOpts(tt.typ, tt.opts)
+			require.NoError(t, err, "warnings do not fail validation")
+			require.Equal(t, tt.warnings, warnings)
+			require.NoError(t, ValidateOpts(tt.typ, tt.opts))
+		})
+	}
+}
+
+func TestNewRule_ValidateOpts(t *testing.T) {
+	_, err := NewRule("r", "up", -time.Second, 0, 0, labels.EmptyLabels(), labels.EmptyLabels())
+	require.ErrorIs(t, err, ErrInvalidDuration)
+
+	_, err = NewRuleFromSpec(RuleSpec{Name: "r", Expr: "up", AlertType: AlertTypeMultiTier, RecoverWithoutSignal: true})
+	require.ErrorIs(t, err, ErrInvalidOpts)
+	require.ErrorContains(t, err, "rule r")
+}
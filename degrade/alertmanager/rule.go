@@ -3,24 +3,82 @@ package alertmanager
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser/posrange"
+)
+
+// KeepFiringMode KeepFiringFor 的语义
+type KeepFiringMode string
+
+const (
+	// KeepFiringAutoResolve 触发持续 KeepFiringFor 后自动恢复，即使条件仍满足（零值）
+	KeepFiringAutoResolve KeepFiringMode = "autoResolve"
+	// KeepFiringExtend 与 Prometheus keep_firing_for 一致：条件消失后继续保持触发 KeepFiringFor 才恢复，
+	// 期间条件再次满足则重新计时
+	KeepFiringExtend KeepFiringMode = "extend"
 )
 
 type AlertOpts struct {
 	// for normal
-	HoldDuration  time.Duration
-	KeepFiringFor time.Duration
-	ResendDelay   time.Duration
+	HoldDuration   time.Duration
+	KeepFiringFor  time.Duration
+	KeepFiringMode KeepFiringMode // 为空时按 KeepFiringAutoResolve
+	ResendDelay    time.Duration
 
 	// for degrade
 	RecoverDuration  time.Duration // 恢复确认时间
 	AutoRecoverAfter time.Duration // 自动恢复时间
+	SustainedRecover bool          // 恢复条件需持续满足 RecoverDuration，期间出现触发则重新计时
+	// RecoverWithoutSignal 自最后一次触发起持续 AutoRecoverAfter 无信号（序列消失）时直接恢复到 L0，
+	// 不再按所处级别的停留时间计算自动恢复
+	RecoverWithoutSignal bool
+	// LevelThresholds 升序阈值，样本值超过第 i 个阈值时目标级别为 L(i+1)，
+	// 设置后按值直接跳转到目标级别，不再逐级等待 HoldDuration
+	LevelThresholds []float64
+	// LevelResendDelay 按降级级别覆盖 ResendDelay，如 L3 每分钟提醒、L1 每 15 分钟提醒，未配置的级别使用 ResendDelay
+	LevelResendDelay map[AlertState]time.Duration
+	// Epsilon 阈值比较的容差，样本值与阈值相差不超过 Epsilon 时保持当前所在的一侧，避免在边界抖动
+	Epsilon float64
+}
+
+// resendDelay 返回 state 下生效的重发间隔
+func (o *AlertOpts) resendDelay(state AlertState) time.Duration {
+	if d, ok := o.LevelResendDelay[state]; ok {
+		return d
+	}
+	return o.ResendDelay
 }
 
+// targetLevel 根据样本值和 LevelThresholds 选出目标降级状态，current 为当前级别
+func (o *AlertOpts) targetLevel(v float64, current int) AlertState {
+	level := 0
+	for i, th := range o.LevelThresholds {
+		switch {
+		case o.Epsilon > 0 && math.Abs(v-th) <= o.Epsilon:
+			if current > i {
+				level++
+			}
+		case v > th:
+			level++
+		}
+	}
+	return degradeStates[level]
+}
+
+// OverflowLabel 标记基数超限元告警的标签名，值为 "true"
+const OverflowLabel = "alert_overflow"
+
+// DefaultSeverityLabel 未设置 Rule.SeverityLabel 时读取告警级别的标签名
+const DefaultSeverityLabel = "severity"
+
 type Rule struct {
 	Name      string
 	Expr      string
@@ -30,8 +88,51 @@ type Rule struct {
 	Labels      labels.Labels
 	Annotations labels.Labels
 
-	mtx    sync.RWMutex
-	active map[uint64]IAlert
+	// AtOffset 查询时间相对评估时间的回退量，用于数据有延迟的场景
+	AtOffset time.Duration
+	// MetadataLabels 从样本标签拷贝到通知 Metadata 的标签名，这些标签不参与告警分组
+	MetadataLabels []string
+	// IdentityLabels 决定告警身份的标签名，只有这些标签（及 alertname）参与指纹计算，
+	// 其余标签变化时沿用同一告警并更新通知中的标签；为空时全部标签参与
+	IdentityLabels []string
+	// Composite 设置后按组合条件评估，代替 Expr；满足的子条件记录在通知 Metadata 的 ConditionsLabel 中
+	Composite *CompositeRule
+	// BaselineOffset 大于 0 时按相对基线判断：Expr 应返回原始指标（如 qps），
+	// 当前值与 BaselineOffset 之前同一序列的值之比超过 Factor 时触发，告警值为该比值
+	BaselineOffset time.Duration
+	Factor         float64
+	// MaxAlertsPerRule 单条规则的告警数上限，超出部分的序列不再创建告警，<=0 表示不限制
+	MaxAlertsPerRule int
+	// CardinalityAlert 序列数超出 MaxAlertsPerRule 时额外触发一条元告警
+	CardinalityAlert bool
+	// WarnExpr 预警条件，仅满足预警条件的序列保持 pending 并记录值，不会触发通知，仅支持 AlertTypeBasic
+	WarnExpr string
+	// ResolvedRetention 已恢复告警在 active 中保留的时长，便于状态查询，<=0 时恢复后立即移除
+	ResolvedRetention time.Duration
+	// HistogramQuantile 表达式返回原生直方图时取该分位数（如 0.99）作为样本值，
+	// 为 0 时直方图样本视为错误，需在表达式中用 histogram_quantile 等函数转为浮点数
+	HistogramQuantile float64
+	// OnTransition 每个告警完成一次状态决策后的回调，reason 为决策原因（如 "hold duration not met: 30s/1m0s"），
+	// notify 表示是否产生通知。在持有规则锁时调用，回调中不能再调用该规则的方法
+	OnTransition func(alert IAlert, notify bool, reason string)
+	// SuppressInitialOnRestart 从存储恢复的告警在该时长内不重发通知，避免重启后对已知故障重复告警；
+	// 期间状态发生变化（如恢复、升级）的通知照常发送
+	SuppressInitialOnRestart time.Duration
+	// FiringThreshold 设置后序列需在最近 M 次评估中至少 N 次出现在查询结果中才视为满足条件，
+	// 未达到时按序列缺失处理，用于过滤单次采集抖动；与 HoldDuration 同时设置时两者都需满足
+	FiringThreshold FiringThreshold
+	// SeverityLabel 填充 Notification.Severity 的告警标签名，为空时使用 DefaultSeverityLabel
+	SeverityLabel string
+
+	mtx        sync.RWMutex
+	active     map[uint64]IAlert
+	resolvedAt map[uint64]time.Time    // 保留中的已恢复告警及其恢复时间
+	lastResult promql.Vector           // 最近一次评估的查询结果，仅保留最新一次
+	quietUntil map[uint64]time.Time    // 重启恢复的告警及其静默截止时间
+	outcomes   map[uint64]*outcomeRing // 设置 FiringThreshold 时各序列最近的评估结果
+	lastEvalAt time.Time
+
+	evaluating atomic.Bool // 是否有评估正在进行
 }
 
 func NewRule(
@@ -42,23 +143,142 @@ func NewRule(
 	if name == "" || expr == "" {
 		return nil, errors.New("empty name or expr")
 	}
-	if hold < 0 || keepFiring < 0 || resendDelay < 0 {
-		return nil, errors.New("durations cannot be negative")
+	opts := &AlertOpts{
+		HoldDuration:  hold,
+		KeepFiringFor: keepFiring,
+		ResendDelay:   resendDelay,
+	}
+	if err := ValidateOpts(AlertTypeBasic, opts); err != nil {
+		return nil, fmt.Errorf("rule %s: %w", name, err)
 	}
 	return &Rule{
-		Name: name,
-		Expr: expr,
-		AlertOpts: &AlertOpts{
-			HoldDuration:  hold,
-			KeepFiringFor: keepFiring,
-			ResendDelay:   resendDelay,
-		},
+		Name:        name,
+		Expr:        expr,
+		AlertOpts:   opts,
 		Labels:      lbs,
 		Annotations: ann,
 		active:      make(map[uint64]IAlert),
 	}, nil
 }
 
+// RuleSpec 规则定义，覆盖全部 AlertOpts 字段，便于从配置文件构造规则
+type RuleSpec struct {
+	Name      string    `yaml:"name" json:"name"`
+	Expr      string    `yaml:"expr" json:"expr"`
+	AlertType AlertType `yaml:"type" json:"type"` // 为空时使用 AlertTypeBasic
+
+	HoldDuration   time.Duration  `yaml:"hold" json:"hold"`
+	KeepFiringFor  time.Duration  `yaml:"keepFiringFor" json:"keepFiringFor"`
+	KeepFiringMode KeepFiringMode `yaml:"keepFiringMode" json:"keepFiringMode"`
+	ResendDelay    time.Duration  `yaml:"resendDelay" json:"resendDelay"`
+
+	RecoverDuration  time.Duration `yaml:"recoverDuration" json:"recoverDuration"`
+	AutoRecoverAfter time.Duration `yaml:"autoRecoverAfter" json:"autoRecoverAfter"`
+	SustainedRecover bool          `yaml:"sustainedRecover" json:"sustainedRecover"`
+	// RecoverWithoutSignal 需同时设置 AutoRecoverAfter
+	RecoverWithoutSignal bool      `yaml:"recoverWithoutSignal" json:"recoverWithoutSignal"`
+	LevelThresholds      []float64 `yaml:"levelThresholds" json:"levelThresholds"` // 仅用于 AlertTypeMultiTier
+	Epsilon              float64   `yaml:"epsilon" json:"epsilon"`
+	// LevelResendDelay 仅用于 AlertTypeMultiTier，键为 l1/l2/l3
+	LevelResendDelay map[AlertState]time.Duration `yaml:"levelResendDelay" json:"levelResendDelay"`
+
+	AtOffset       time.Duration `yaml:"atOffset" json:"atOffset"`
+	MetadataLabels []string      `yaml:"metadataLabels" json:"metadataLabels"`
+	IdentityLabels []string      `yaml:"identityLabels" json:"identityLabels"`
+
+	MaxAlertsPerRule int  `yaml:"maxAlerts" json:"maxAlerts"`
+	CardinalityAlert bool `yaml:"cardinalityAlert" json:"cardinalityAlert"`
+
+	ResolvedRetention time.Duration `yaml:"resolvedRetention" json:"resolvedRetention"`
+	WarnExpr          string        `yaml:"warnExpr" json:"warnExpr"` // 仅用于 AlertTypeBasic
+	SeverityLabel     string        `yaml:"severityLabel" json:"severityLabel"`
+	HistogramQuantile float64       `yaml:"histogramQuantile" json:"histogramQuantile"`
+
+	SuppressInitialOnRestart time.Duration   `yaml:"suppressInitialOnRestart" json:"suppressInitialOnRestart"`
+	BaselineOffset           time.Duration   `yaml:"baselineOffset" json:"baselineOffset"`
+	Factor                   float64         `yaml:"factor" json:"factor"` // 与 BaselineOffset 同时设置
+	FiringThreshold          FiringThreshold `yaml:"firingThreshold" json:"firingThreshold"`
+
+	Labels      map[string]string `yaml:"labels" json:"labels"`
+	Annotations map[string]string `yaml:"annotations" json:"annotations"`
+}
+
+// NewRuleFromSpec 根据 RuleSpec 创建规则
+func NewRuleFromSpec(spec RuleSpec) (*Rule, error) {
+	if spec.Name == "" || spec.Expr == "" {
+		return nil, errors.New("empty name or expr")
+	}
+	typ := spec.AlertType
+	if typ == "" {
+		typ = AlertTypeBasic
+	}
+	if typ != AlertTypeBasic && typ != AlertTypeMultiTier {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlertType, typ)
+	}
+	if spec.AtOffset < 0 || spec.ResolvedRetention < 0 || spec.BaselineOffset < 0 || spec.SuppressInitialOnRestart < 0 {
+		return nil, fmt.Errorf("%w: rule %s", ErrInvalidDuration, spec.Name)
+	}
+	if spec.WarnExpr != "" && typ != AlertTypeBasic {
+		return nil, fmt.Errorf("%w: warn expr requires %s", ErrUnsupportedAlertType, AlertTypeBasic)
+	}
+	if spec.HistogramQuantile < 0 || spec.HistogramQuantile > 1 {
+		return nil, fmt.Errorf("histogram quantile %v out of range [0, 1]", spec.HistogramQuantile)
+	}
+	if (spec.BaselineOffset > 0) != (spec.Factor > 0) {
+		return nil, errors.New("baselineOffset and factor must be set together")
+	}
+	if err := spec.FiringThreshold.validate(); err != nil {
+		return nil, fmt.Errorf("rule %s: %w", spec.Name, err)
+	}
+	// 配置文件中对该类型无效的分级字段直接报错，而不只是记录警告
+	if len(spec.LevelResendDelay) > 0 && typ != AlertTypeMultiTier {
+		return nil, errors.New("level resend delay requires multi-tier alert type")
+	}
+	if len(spec.LevelThresholds) > 0 && typ != AlertTypeMultiTier {
+		return nil, errors.New("level thresholds require multi-tier alert type")
+	}
+	opts := &AlertOpts{
+		HoldDuration:     spec.HoldDuration,
+		KeepFiringFor:    spec.KeepFiringFor,
+		KeepFiringMode:   spec.KeepFiringMode,
+		ResendDelay:      spec.ResendDelay,
+		RecoverDuration:  spec.RecoverDuration,
+		AutoRecoverAfter: spec.AutoRecoverAfter,
+		SustainedRecover: spec.SustainedRecover,
+		LevelThresholds:  spec.LevelThresholds,
+		Epsilon:          spec.Epsilon,
+		LevelResendDelay: spec.LevelResendDelay,
+
+		RecoverWithoutSignal: spec.RecoverWithoutSignal,
+	}
+	if err := ValidateOpts(typ, opts); err != nil {
+		return nil, fmt.Errorf("rule %s: %w", spec.Name, err)
+	}
+	return &Rule{
+		Name:              spec.Name,
+		Expr:              spec.Expr,
+		AlertType:         typ,
+		AlertOpts:         opts,
+		Labels:            labels.FromMap(spec.Labels),
+		Annotations:       labels.FromMap(spec.Annotations),
+		AtOffset:          spec.AtOffset,
+		MetadataLabels:    spec.MetadataLabels,
+		IdentityLabels:    spec.IdentityLabels,
+		MaxAlertsPerRule:  spec.MaxAlertsPerRule,
+		CardinalityAlert:  spec.CardinalityAlert,
+		ResolvedRetention: spec.ResolvedRetention,
+		WarnExpr:          spec.WarnExpr,
+		SeverityLabel:     spec.SeverityLabel,
+		HistogramQuantile: spec.HistogramQuantile,
+		BaselineOffset:    spec.BaselineOffset,
+		Factor:            spec.Factor,
+
+		SuppressInitialOnRestart: spec.SuppressInitialOnRestart,
+		FiringThreshold:          spec.FiringThreshold,
+		active:                   make(map[uint64]IAlert),
+	}, nil
+}
+
 func (r *Rule) newAlert(lbs labels.Labels) (IAlert, error) {
 	return NewAlert(r.AlertType, lbs, r.AlertOpts)
 }
@@ -68,61 +288,271 @@ func (r *Rule) Eval(
 	ts time.Time,
 	query QueryFunc,
 ) ([]IAlert, error) {
-	vector, err := query(ctx, r.Expr, ts)
+	var (
+		vector promql.Vector
+		err    error
+	)
+	switch {
+	case r.Composite != nil:
+		vector, err = r.Composite.eval(ctx, ts.Add(-r.AtOffset), query, r.sampleValue)
+	case r.BaselineOffset > 0:
+		vector, err = r.evalBaseline(ctx, ts.Add(-r.AtOffset), query)
+	default:
+		vector, err = query(ctx, r.Expr, ts.Add(-r.AtOffset))
+	}
 	if err != nil {
 		return nil, err
 	}
+	var warn promql.Vector
+	if r.WarnExpr != "" {
+		warn, err = query(ctx, r.WarnExpr, ts.Add(-r.AtOffset))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.evalVectors(ctx, ts, vector, warn)
+}
+
+// EvalVector 使用给定的查询结果驱动告警状态，返回需要通知的告警
+func (r *Rule) EvalVector(
+	ctx context.Context,
+	ts time.Time,
+	vector promql.Vector,
+) ([]IAlert, error) {
+	return r.evalVectors(ctx, ts, vector, nil)
+}
+
+// LastResult 返回最近一次评估的查询结果及评估时间，用于排查告警为何触发或未触发；
+// 尚未评估时返回 nil 和零值时间
+func (r *Rule) LastResult() (promql.Vector, time.Time) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return append(promql.Vector(nil), r.lastResult...), r.lastEvalAt
+}
+
+// evalVectors vector 为满足告警条件的序列，warn 为满足预警条件的序列，同时出现时以告警条件为准
+func (r *Rule) evalVectors(
+	ctx context.Context,
+	ts time.Time,
+	vector, warn promql.Vector,
+) ([]IAlert, error) {
+	var err error
+
+	// 先取出全部样本值，存在无法处理的直方图样本时不修改任何告警状态
+	values := make([]float64, 0, len(vector)+len(warn))
+	for _, sample := range append(vector[:len(vector):len(vector)], warn...) {
+		v, err := r.sampleValue(sample)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
 
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	r.lastResult = append(promql.Vector(nil), vector...)
+	r.lastEvalAt = ts
+
 	activeFPs := make(map[uint64]struct{}, len(vector))
 	var firingAlerts []IAlert
+	if r.FiringThreshold.enabled() {
+		defer r.ageOutcomes()
+	}
+
+	overflowFP := r.fingerprint(r.overflowLabels())
+	skipped := 0
 
-	for _, sample := range vector {
+	// 先处理告警条件，再处理仅满足预警条件的序列
+	critical := len(vector)
+	for i, sample := range append(vector[:len(vector):len(vector)], warn...) {
+		warnOnly := i >= critical
 		lbs := r.formatLabels(sample.Metric)
-		fp := lbs.Hash()
-		activeFPs[fp] = struct{}{}
+		fp := r.fingerprint(lbs)
+		if _, seen := activeFPs[fp]; seen && warnOnly {
+			continue
+		}
+		// 未达到 N/M 的序列按缺失处理，由下方的清理逻辑驱动恢复
+		if !warnOnly && r.FiringThreshold.enabled() && !r.recordOutcome(fp) {
+			continue
+		}
 
 		alert, exists := r.active[fp]
+		if !exists && r.MaxAlertsPerRule > 0 && r.alertCount(overflowFP) >= r.MaxAlertsPerRule {
+			skipped++
+			continue
+		}
+		activeFPs[fp] = struct{}{}
 		if !exists {
 			alert, err = r.newAlert(lbs)
 			if err != nil {
 				return nil, err
 			}
 			r.active[fp] = alert
+		} else if len(r.IdentityLabels) > 0 {
+			alert.SetLabels(lbs)
 		}
 
-		alert.SetValue(sample.F)
+		alert.SetValue(values[i])
+		if len(r.metadataLabels()) > 0 {
+			alert.SetMetadata(r.extractMetadata(sample.Metric))
+		}
 
-		shouldSend, err := alert.Transition(ctx, true, ts)
+		var shouldSend bool
+		before := alert.State()
+		if warnOnly {
+			shouldSend, err = alert.Warn(ctx, ts)
+		} else {
+			shouldSend, err = alert.Transition(ctx, true, ts)
+		}
 		if err != nil {
 			log.Printf("alert transition failed: %v\n", err)
 			continue
 		}
+		if shouldSend && r.quiet(fp, before, alert.State(), ts) {
+			log.Printf("Rule %s: resend of restored alert %s suppressed until %v\n", r.Name, alert.Labels(), r.quietUntil[fp])
+			shouldSend = false
+		}
+		r.reportTransition(alert, shouldSend)
 		if shouldSend {
 			firingAlerts = append(firingAlerts, alert)
 		}
 	}
 
-	// 清理非活跃告警
-	for fp, alert := range r.active {
-		if _, active := activeFPs[fp]; !active {
-			shouldSend, _ := alert.Transition(ctx, false, ts)
+	if skipped > 0 {
+		log.Printf("Rule %s: %d of %d series exceed MaxAlertsPerRule %d, skipped\n",
+			r.Name, skipped, len(vector), r.MaxAlertsPerRule)
+		if r.CardinalityAlert {
+			activeFPs[overflowFP] = struct{}{}
+			alert, exists := r.active[overflowFP]
+			if !exists {
+				alert, err = r.newAlert(r.overflowLabels())
+				if err != nil {
+					return nil, err
+				}
+				r.active[overflowFP] = alert
+			}
+			alert.SetValue(float64(len(vector)))
+			shouldSend, err := alert.Transition(ctx, true, ts)
 			if err != nil {
 				log.Printf("alert transition failed: %v\n", err)
-				continue
+			} else {
+				r.reportTransition(alert, shouldSend)
+				if shouldSend {
+					firingAlerts = append(firingAlerts, alert)
+				}
 			}
-			if shouldSend {
-				firingAlerts = append(firingAlerts, alert)
-				delete(r.active, fp)
+		}
+	}
+
+	// 重新出现的告警结束保留，保留到期的告警移除
+	for fp, resolvedAt := range r.resolvedAt {
+		if _, active := activeFPs[fp]; active {
+			delete(r.resolvedAt, fp)
+		} else if ts.Sub(resolvedAt) >= r.ResolvedRetention {
+			delete(r.resolvedAt, fp)
+			delete(r.active, fp)
+		}
+	}
+
+	// 清理非活跃告警
+	for fp, alert := range r.active {
+		if _, active := activeFPs[fp]; active {
+			continue
+		}
+		if _, retained := r.resolvedAt[fp]; retained {
+			continue
+		}
+		shouldSend, err := alert.Transition(ctx, false, ts)
+		if err != nil {
+			log.Printf("alert transition failed: %v\n", err)
+			continue
+		}
+		r.reportTransition(alert, shouldSend)
+		if shouldSend {
+			firingAlerts = append(firingAlerts, alert)
+		}
+		// 多级告警逐级恢复，回到 L0 之前继续留在 active 中驱动恢复
+		if state := alert.State(); state != AlertStateInactive && state != AlertStateL0 {
+			continue
+		}
+		if shouldSend && r.ResolvedRetention > 0 {
+			if r.resolvedAt == nil {
+				r.resolvedAt = make(map[uint64]time.Time)
 			}
+			r.resolvedAt[fp] = ts
+		} else {
+			delete(r.active, fp)
 		}
+		delete(r.quietUntil, fp)
 	}
 
 	return firingAlerts, nil
 }
 
+// ActiveAlerts 返回规则当前的告警，includeResolved 为 true 时包含保留期内的已恢复告警
+func (r *Rule) ActiveAlerts(includeResolved bool) []IAlert {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	alerts := make([]IAlert, 0, len(r.active))
+	for fp, alert := range r.active {
+		if _, resolved := r.resolvedAt[fp]; resolved && !includeResolved {
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// resolveAll 强制恢复规则的所有告警，返回需要发送恢复通知的告警
+func (r *Rule) resolveAll(ctx context.Context, ts time.Time) []IAlert {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var resolved []IAlert
+	for fp, alert := range r.active {
+		shouldSend, err := alert.Resolve(ctx, ts)
+		if err != nil {
+			log.Printf("alert resolve failed: %v\n", err)
+			continue
+		}
+		if shouldSend {
+			resolved = append(resolved, alert)
+		}
+		delete(r.active, fp)
+	}
+	r.resolvedAt = nil
+	return resolved
+}
+
+// alertCount 当前告警数，不含基数超限的元告警和保留中的已恢复告警
+func (r *Rule) alertCount(overflowFP uint64) int {
+	n := len(r.active) - len(r.resolvedAt)
+	if _, ok := r.active[overflowFP]; ok {
+		if _, resolved := r.resolvedAt[overflowFP]; !resolved {
+			n--
+		}
+	}
+	return n
+}
+
+// fingerprint 告警指纹，设置 IdentityLabels 时只按身份标签计算
+func (r *Rule) fingerprint(lbs labels.Labels) uint64 {
+	if len(r.IdentityLabels) == 0 {
+		return lbs.Hash()
+	}
+	names := append([]string{labels.AlertName, OverflowLabel}, r.IdentityLabels...)
+	return lbs.MatchLabels(true, names...).Hash()
+}
+
+// overflowLabels 基数超限元告警的标签
+func (r *Rule) overflowLabels() labels.Labels {
+	builder := labels.NewBuilder(r.formatLabels(labels.EmptyLabels()))
+	builder.Set(OverflowLabel, "true")
+	return builder.Labels()
+}
+
 func (r *Rule) formatLabels(sampleLabels labels.Labels) labels.Labels {
 	builder := labels.NewBuilder(sampleLabels)
 	r.Labels.Range(func(l labels.Label) {
@@ -131,6 +561,66 @@ func (r *Rule) formatLabels(sampleLabels labels.Labels) labels.Labels {
 		}
 	})
 	builder.Del(labels.MetricName)
+	builder.Del(r.metadataLabels()...)
 	builder.Set(labels.AlertName, r.Name)
 	return builder.Labels()
 }
+
+// quiet 判断 fp 对应的重启恢复告警是否仍在静默期，状态未变（即重发）时返回 true；
+// 静默期结束或状态变化后清除标记
+func (r *Rule) quiet(fp uint64, before, after AlertState, ts time.Time) bool {
+	until, ok := r.quietUntil[fp]
+	if !ok {
+		return false
+	}
+	if before != after || !ts.Before(until) {
+		delete(r.quietUntil, fp)
+		return false
+	}
+	return true
+}
+
+func (r *Rule) reportTransition(alert IAlert, notify bool) {
+	if r.OnTransition != nil {
+		r.OnTransition(alert, notify, alert.Reason())
+	}
+}
+
+// sampleValue 返回样本的告警值，直方图样本按 HistogramQuantile 取分位数
+func (r *Rule) sampleValue(s promql.Sample) (float64, error) {
+	if s.H == nil {
+		return s.F, nil
+	}
+	if r.HistogramQuantile <= 0 {
+		return 0, fmt.Errorf("%w: rule %s, series %s", ErrHistogramSample, r.Name, s.Metric)
+	}
+	v, _ := promql.HistogramQuantile(r.HistogramQuantile, s.H, s.Metric.Get(labels.MetricName), posrange.PositionRange{})
+	return v, nil
+}
+
+// severityLabel 返回生效的级别标签名
+func (r *Rule) severityLabel() string {
+	if r.SeverityLabel != "" {
+		return r.SeverityLabel
+	}
+	return DefaultSeverityLabel
+}
+
+// metadataLabels 返回拷贝到 Metadata 的标签名，组合规则额外包含 ConditionsLabel
+func (r *Rule) metadataLabels() []string {
+	if r.Composite == nil {
+		return r.MetadataLabels
+	}
+	return append(r.MetadataLabels[:len(r.MetadataLabels):len(r.MetadataLabels)], ConditionsLabel)
+}
+
+func (r *Rule) extractMetadata(sampleLabels labels.Labels) map[string]string {
+	names := r.metadataLabels()
+	metadata := make(map[string]string, len(names))
+	for _, name := range names {
+		if v := sampleLabels.Get(name); v != "" {
+			metadata[name] = v
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,653 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRule_EvalVector_FireAndResolve(t *testing.T) {
+	rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{HoldDuration: time.Minute})
+	vector := promql.Vector{{Metric: labels.FromStrings("__name__", "cpu_usage", "instance", "host1"), F: 0.9}}
+
+	t0 := time.Now()
+	alerts, err := rule.EvalVector(context.Background(), t0, vector)
+	require.NoError(t, err)
+	require.Empty(t, alerts, "should be pending within hold duration")
+	require.Len(t, rule.active, 1)
+
+	alerts, err = rule.EvalVector(context.Background(), t0.Add(2*time.Minute), vector)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateFiring, alerts[0].State())
+	require.Equal(t, 0.9, alerts[0].GetValue())
+	require.Equal(t, "HighCPU", alerts[0].Labels().Get(labels.AlertName))
+	require.Equal(t, "", alerts[0].Labels().Get(labels.MetricName))
+
+	alerts, err = rule.EvalVector(context.Background(), t0.Add(3*time.Minute), promql.Vector{})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateInactive, alerts[0].State())
+	require.Empty(t, rule.active, "resolved alert should be removed")
+}
+
+func TestNewRuleFromSpec(t *testing.T) {
+	basic, err := NewRuleFromSpec(RuleSpec{
+		Name:          "HighCPU",
+		Expr:          "cpu_usage > 0.8",
+		HoldDuration:  time.Minute,
+		KeepFiringFor: 10 * time.Minute,
+		ResendDelay:   5 * time.Minute,
+		Labels:        map[string]string{"severity": "critical"},
+		Annotations:   map[string]string{"summary": "cpu high"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, AlertTypeBasic, basic.AlertType)
+	require.Equal(t, &AlertOpts{
+		HoldDuration:  time.Minute,
+		KeepFiringFor: 10 * time.Minute,
+		ResendDelay:   5 * time.Minute,
+	}, basic.AlertOpts)
+	require.Equal(t, labels.FromStrings("severity", "critical"), basic.Labels)
+	require.Equal(t, labels.FromStrings("summary", "cpu high"), basic.Annotations)
+
+	degrade, err := NewRuleFromSpec(RuleSpec{
+		Name:             "HighQPS",
+		Expr:             "qps > 1000",
+		AlertType:        AlertTypeMultiTier,
+		HoldDuration:     time.Minute,
+		RecoverDuration:  3 * time.Minute,
+		AutoRecoverAfter: time.Hour,
+		SustainedRecover: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, AlertTypeMultiTier, degrade.AlertType)
+	require.Equal(t, 3*time.Minute, degrade.AlertOpts.RecoverDuration)
+	require.Equal(t, time.Hour, degrade.AlertOpts.AutoRecoverAfter)
+	require.True(t, degrade.AlertOpts.SustainedRecover)
+
+	// 构造的规则可以直接评估
+	_, err = degrade.EvalVector(context.Background(), time.Now(), promql.Vector{{Metric: labels.FromStrings("instance", "a"), F: 2000}})
+	require.NoError(t, err)
+
+	for _, spec := range []RuleSpec{
+		{Expr: "up"},
+		{Name: "x"},
+		{Name: "x", Expr: "up", AlertType: AlertTypeCustom},
+		{Name: "x", Expr: "up", RecoverDuration: -time.Second},
+		{Name: "x", Expr: "up", LevelThresholds: []float64{0.7}},
+		{Name: "x", Expr: "up", AlertType: AlertTypeMultiTier, LevelThresholds: []float64{0.85, 0.7}},
+		{Name: "x", Expr: "up", AlertType: AlertTypeMultiTier, LevelThresholds: []float64{0.1, 0.2, 0.3, 0.4}},
+		{Name: "x", Expr: "up", AlertType: AlertTypeMultiTier, RecoverWithoutSignal: true},
+		{Name: "x", Expr: "qps", BaselineOffset: time.Hour},
+		{Name: "x", Expr: "qps", Factor: 1.5},
+		{Name: "x", Expr: "qps", BaselineOffset: -time.Hour, Factor: 1.5},
+	} {
+		_, err := NewRuleFromSpec(spec)
+		require.Error(t, err, "spec %+v", spec)
+	}
+}
+
+func TestRule_EvalVector_OnTransition(t *testing.T) {
+	rule := newTestRule(t, "HighCPU", "cpu > 0.8", &AlertOpts{HoldDuration: time.Minute})
+	reasons := make(map[string]string)
+	rule.OnTransition = func(alert IAlert, notify bool, reason string) {
+		reasons[alert.Labels().Get("instance")] = fmt.Sprintf("%v %s", notify, reason)
+	}
+	vector := promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: 0.9}}
+
+	t0 := time.Now()
+	alerts, err := rule.EvalVector(context.Background(), t0, vector)
+	require.NoError(t, err)
+	require.Empty(t, alerts)
+	require.Equal(t, "false hold duration not met: 0s/1m0s", reasons["host1"])
+
+	_, err = rule.EvalVector(context.Background(), t0.Add(30*time.Second), vector)
+	require.NoError(t, err)
+	require.Equal(t, "false hold duration not met: 30s/1m0s", reasons["host1"])
+
+	_, err = rule.EvalVector(context.Background(), t0.Add(time.Minute), vector)
+	require.NoError(t, err)
+	require.Equal(t, "true hold duration met, fired", reasons["host1"])
+
+	_, err = rule.EvalVector(context.Background(), t0.Add(2*time.Minute), nil)
+	require.NoError(t, err)
+	require.Equal(t, "true condition cleared, resolved", reasons["host1"])
+
+	// 多级告警同样给出原因
+	degrade := newTestRule(t, "HighQPS", "qps > 1000", &AlertOpts{HoldDuration: time.Minute})
+	degrade.AlertType = AlertTypeMultiTier
+	var reason string
+	degrade.OnTransition = func(_ IAlert, _ bool, r string) { reason = r }
+	_, err = degrade.EvalVector(context.Background(), t0, vector)
+	require.NoError(t, err)
+	require.Equal(t, "hold duration not met: 0s/1m0s", reason)
+}
+
+func TestRule_Eval_AtOffset(t *testing.T) {
+	var queried time.Time
+	queryFn := func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+		queried = ts
+		return promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: 1}}, nil
+	}
+
+	rule := newTestRule(t, "Delayed", "up == 0", &AlertOpts{})
+	rule.AtOffset = 2 * time.Minute
+
+	now := time.Now()
+	alerts, err := rule.Eval(context.Background(), now, queryFn)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(-2*time.Minute), queried)
+	require.Len(t, alerts, 1)
+	require.Equal(t, now, alerts[0].Snapshot().LastSentAt, "alert state still tracks evaluation time")
+
+	rule.AtOffset = 0
+	_, err = rule.Eval(context.Background(), now, queryFn)
+	require.NoError(t, err)
+	require.Equal(t, now, queried)
+}
+
+func TestRule_LastResult(t *testing.T) {
+	rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{HoldDuration: time.Minute})
+	vector, ts := rule.LastResult()
+	require.Nil(t, vector)
+	require.True(t, ts.IsZero(), "not evaluated yet")
+
+	result := promql.Vector{
+		{Metric: labels.FromStrings("__name__", "cpu_usage", "instance", "host1"), F: 0.9},
+		{Metric: labels.FromStrings("__name__", "cpu_usage", "instance", "host2"), F: 0.85},
+	}
+	queryFn := func(context.Context, string, time.Time) (promql.Vector, error) { return result, nil }
+
+	t0 := time.Now()
+	alerts, err := rule.Eval(context.Background(), t0, queryFn)
+	require.NoError(t, err)
+	require.Empty(t, alerts, "pending alerts still record the raw result")
+
+	vector, ts = rule.LastResult()
+	require.Equal(t, t0, ts)
+	require.Equal(t, result, vector)
+
+	// 返回副本，修改不影响规则内部保存的结果
+	vector[0].F = 0
+	vector, _ = rule.LastResult()
+	require.Equal(t, 0.9, vector[0].F)
+
+	// 只保留最近一次
+	_, err = rule.EvalVector(context.Background(), t0.Add(time.Minute), promql.Vector{})
+	require.NoError(t, err)
+	vector, ts = rule.LastResult()
+	require.Empty(t, vector)
+	require.Equal(t, t0.Add(time.Minute), ts)
+}
+
+func TestRule_Eval_Baseline(t *testing.T) {
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+	host := func(name string, v float64) promql.Sample {
+		return promql.Sample{Metric: labels.FromStrings("__name__", "qps", "instance", name), F: v}
+	}
+	queryFn := func(_ context.Context, query string, ts time.Time) (promql.Vector, error) {
+		require.Equal(t, "qps", query)
+		switch ts {
+		case now:
+			return promql.Vector{host("host1", 1600), host("host2", 1200), host("host3", 500), host("host4", 100)}, nil
+		case hourAgo:
+			// host4 一小时前没有数据，host3 基线为 0
+			return promql.Vector{host("host1", 1000), host("host2", 1000), host("host3", 0)}, nil
+		}
+		return nil, fmt.Errorf("unexpected query time %v", ts)
+	}
+
+	rule, err := NewRuleFromSpec(RuleSpec{Name: "QPSSurge", Expr: "qps", BaselineOffset: time.Hour, Factor: 1.5})
+	require.NoError(t, err)
+	alerts, err := rule.Eval(context.Background(), now, queryFn)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1, "only host1 exceeds 150% of its baseline")
+	require.Equal(t, "host1", alerts[0].Labels().Get("instance"))
+	require.Equal(t, AlertStateFiring, alerts[0].State())
+	require.InDelta(t, 1.6, alerts[0].GetValue(), 1e-9, "alert value is the ratio")
+}
+
+func TestRule_Eval_HistogramSamples(t *testing.T) {
+	// 100 次观测全部落在 (4, 8] 桶内
+	h := &histogram.FloatHistogram{
+		Count:           100,
+		Sum:             600,
+		Schema:          0,
+		PositiveSpans:   []histogram.Span{{Offset: 3, Length: 1}},
+		PositiveBuckets: []float64{100},
+	}
+	queryFn := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		return promql.Vector{{Metric: labels.FromStrings("__name__", "request_latency_seconds", "instance", "host1"), H: h}}, nil
+	}
+
+	rule := newTestRule(t, "SlowRequests", "request_latency_seconds", &AlertOpts{})
+	_, err := rule.Eval(context.Background(), time.Now(), queryFn)
+	require.ErrorIs(t, err, ErrHistogramSample)
+	require.Contains(t, err.Error(), `instance="host1"`)
+	require.Empty(t, rule.ActiveAlerts(true), "no alert state is created for rejected samples")
+
+	rule.HistogramQuantile = 0.99
+	alerts, err := rule.Eval(context.Background(), time.Now(), queryFn)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Greater(t, alerts[0].GetValue(), 4.0)
+	require.LessOrEqual(t, alerts[0].GetValue(), 8.0)
+
+	_, err = NewRuleFromSpec(RuleSpec{Name: "x", Expr: "up", HistogramQuantile: 1.5})
+	require.Error(t, err)
+}
+
+func TestRule_EvalVector_LevelThresholds(t *testing.T) {
+	rule, err := NewRuleFromSpec(RuleSpec{
+		Name:            "HighCPU",
+		Expr:            "cpu_usage",
+		AlertType:       AlertTypeMultiTier,
+		HoldDuration:    time.Minute,
+		RecoverDuration: 2 * time.Minute,
+		LevelThresholds: []float64{0.7, 0.85, 0.95},
+	})
+	require.NoError(t, err)
+	sample := func(v float64) promql.Vector {
+		return promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: v}}
+	}
+
+	t0 := time.Now()
+	alerts, err := rule.EvalVector(context.Background(), t0, sample(0.5))
+	require.NoError(t, err)
+	require.Empty(t, alerts, "value below the lowest threshold stays at L0")
+
+	// 突增的值在一次评估内直接跳到对应级别，不受 HoldDuration 限制
+	alerts, err = rule.EvalVector(context.Background(), t0.Add(10*time.Second), sample(0.9))
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateL2, alerts[0].State())
+
+	alerts, err = rule.EvalVector(context.Background(), t0.Add(20*time.Second), sample(0.99))
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateL3, alerts[0].State())
+
+	// 回落需满足恢复确认时间，之后直接恢复到目标级别
+	alerts, err = rule.EvalVector(context.Background(), t0.Add(time.Minute), sample(0.75))
+	require.NoError(t, err)
+	require.Empty(t, alerts)
+
+	alerts, err = rule.EvalVector(context.Background(), t0.Add(3*time.Minute), sample(0.75))
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateL1, alerts[0].State())
+}
+
+func TestRule_EvalVector_Epsilon(t *testing.T) {
+	hovering := []float64{0.705, 0.698, 0.702, 0.695, 0.7, 0.704}
+	newRule := func(eps float64) *Rule {
+		rule, err := NewRuleFromSpec(RuleSpec{
+			Name:            "HighCPU",
+			Expr:            "cpu_usage",
+			AlertType:       AlertTypeMultiTier,
+			LevelThresholds: []float64{0.7, 0.85},
+			Epsilon:         eps,
+		})
+		require.NoError(t, err)
+		return rule
+	}
+	// eval 依次评估 values，返回各次评估后的级别
+	eval := func(rule *Rule, values ...float64) []AlertState {
+		t0 := time.Now()
+		var states []AlertState
+		for i, v := range values {
+			_, err := rule.EvalVector(context.Background(), t0.Add(time.Duration(i)*time.Second),
+				promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: v}})
+			require.NoError(t, err)
+			alerts := rule.ActiveAlerts(false)
+			require.Len(t, alerts, 1)
+			states = append(states, alerts[0].State())
+		}
+		return states
+	}
+
+	// 无容差时在阈值附近来回切换
+	require.Equal(t, []AlertState{AlertStateL1, AlertStateL0, AlertStateL1, AlertStateL0, AlertStateL0, AlertStateL1},
+		eval(newRule(0), hovering...))
+
+	// 容差内保持所在级别：先越过阈值进入 L1 后不再回落，始终低于阈值时不会升级
+	states := eval(newRule(0.01), append([]float64{0.75}, hovering...)...)
+	for i, state := range states {
+		require.Equal(t, AlertStateL1, state, "evaluation %d", i)
+	}
+	states = eval(newRule(0.01), append([]float64{0.6}, hovering...)...)
+	for i, state := range states {
+		require.Equal(t, AlertStateL0, state, "evaluation %d", i)
+	}
+
+	_, err := NewRuleFromSpec(RuleSpec{Name: "x", Expr: "up", Epsilon: -0.1})
+	require.Error(t, err)
+}
+
+func TestRule_EvalVector_RecoverWithoutSignal(t *testing.T) {
+	rule, err := NewRuleFromSpec(RuleSpec{
+		Name:                 "HighCPU",
+		Expr:                 "cpu_usage",
+		AlertType:            AlertTypeMultiTier,
+		RecoverDuration:      time.Hour,
+		AutoRecoverAfter:     5 * time.Minute,
+		RecoverWithoutSignal: true,
+		LevelThresholds:      []float64{0.7, 0.85, 0.95},
+	})
+	require.NoError(t, err)
+	sample := promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: 0.9}}
+
+	t0 := time.Now()
+	alerts, err := rule.EvalVector(context.Background(), t0, sample)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateL2, alerts[0].State())
+
+	// 持续触发期间停留时间超过 AutoRecoverAfter 也不影响，计时从最后一次信号开始
+	lastSignal := t0.Add(10 * time.Minute)
+	_, err = rule.EvalVector(context.Background(), lastSignal, sample)
+	require.NoError(t, err)
+
+	// 序列消失，未满 AutoRecoverAfter 时保持降级
+	alerts, err = rule.EvalVector(context.Background(), lastSignal.Add(4*time.Minute), nil)
+	require.NoError(t, err)
+	require.Empty(t, alerts)
+	require.Len(t, rule.ActiveAlerts(false), 1)
+
+	// 无信号满 AutoRecoverAfter 后直接恢复到 L0，不受 RecoverDuration 限制
+	alerts, err = rule.EvalVector(context.Background(), lastSignal.Add(5*time.Minute), nil)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateL0, alerts[0].State())
+	require.Empty(t, rule.ActiveAlerts(false))
+}
+
+func TestRule_EvalVector_RecoverLadder(t *testing.T) {
+	rule, err := NewRuleFromSpec(RuleSpec{
+		Name:            "HighQPS",
+		Expr:            "qps > 1000",
+		AlertType:       AlertTypeMultiTier,
+		RecoverDuration: time.Minute,
+	})
+	require.NoError(t, err)
+	sample := promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: 2000}}
+
+	t0 := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err = rule.EvalVector(context.Background(), t0.Add(time.Duration(i)*time.Second), sample)
+		require.NoError(t, err)
+	}
+	require.Equal(t, AlertStateL3, rule.ActiveAlerts(false)[0].State())
+
+	// 序列消失后逐级恢复，每一级都发送通知，回到 L0 后才移除
+	var states []AlertState
+	for i := 1; i <= 4; i++ {
+		alerts, err := rule.EvalVector(context.Background(), t0.Add(time.Duration(i)*time.Minute), nil)
+		require.NoError(t, err)
+		for _, alert := range alerts {
+			states = append(states, alert.State())
+		}
+	}
+	require.Equal(t, []AlertState{AlertStateL2, AlertStateL1, AlertStateL0}, states)
+	require.Empty(t, rule.ActiveAlerts(true))
+}
+
+func TestRule_Eval_MaxAlertsPerRule(t *testing.T) {
+	series := func(n int) promql.Vector {
+		vector := make(promql.Vector, 0, n)
+		for i := 0; i < n; i++ {
+			vector = append(vector, promql.Sample{Metric: labels.FromStrings("instance", fmt.Sprintf("host%d", i)), F: 1})
+		}
+		return vector
+	}
+	var vector promql.Vector
+	queryFn := func(context.Context, string, time.Time) (promql.Vector, error) { return vector, nil }
+
+	rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{})
+	rule.MaxAlertsPerRule = 3
+	rule.CardinalityAlert = true
+
+	t0 := time.Now()
+	vector = series(10)
+	alerts, err := rule.Eval(context.Background(), t0, queryFn)
+	require.NoError(t, err)
+	require.Len(t, rule.active, 4, "three capped alerts plus the meta-alert")
+
+	var overflow IAlert
+	for _, alert := range alerts {
+		if alert.Labels().Get(OverflowLabel) == "true" {
+			overflow = alert
+		}
+	}
+	require.NotNil(t, overflow, "meta-alert should fire when the cap is exceeded")
+	require.Equal(t, float64(10), overflow.GetValue())
+	require.Equal(t, "HighCPU", overflow.Labels().Get(labels.AlertName))
+
+	// 已有告警继续评估，超出上限的序列仍被跳过
+	_, err = rule.Eval(context.Background(), t0.Add(time.Minute), queryFn)
+	require.NoError(t, err)
+	require.Equal(t, 3, rule.alertCount(rule.overflowLabels().Hash()))
+
+	// 序列数回落后元告警恢复
+	vector = series(2)
+	alerts, err = rule.Eval(context.Background(), t0.Add(2*time.Minute), queryFn)
+	require.NoError(t, err)
+	_, ok := rule.active[rule.overflowLabels().Hash()]
+	require.False(t, ok, "meta-alert should resolve once cardinality is back under the cap")
+	require.NotEmpty(t, alerts)
+}
+
+func TestRule_EvalVector_ResolvedRetention(t *testing.T) {
+	rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{})
+	rule.ResolvedRetention = 5 * time.Minute
+	vector := promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: 0.9}}
+
+	t0 := time.Now()
+	alerts, err := rule.EvalVector(context.Background(), t0, vector)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateFiring, alerts[0].State())
+
+	alerts, err = rule.EvalVector(context.Background(), t0.Add(time.Minute), promql.Vector{})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1, "resolution is still notified")
+
+	// 保留期内仍可查询到已恢复告警
+	all := rule.ActiveAlerts(true)
+	require.Len(t, all, 1)
+	require.Equal(t, AlertStateInactive, all[0].State())
+	require.Empty(t, rule.ActiveAlerts(false))
+
+	alerts, err = rule.EvalVector(context.Background(), t0.Add(5*time.Minute), promql.Vector{})
+	require.NoError(t, err)
+	require.Empty(t, alerts, "retained alert is not notified again")
+	require.Len(t, rule.ActiveAlerts(true), 1)
+
+	_, err = rule.EvalVector(context.Background(), t0.Add(6*time.Minute), promql.Vector{})
+	require.NoError(t, err)
+	require.Empty(t, rule.ActiveAlerts(true), "retention elapsed")
+}
+
+func TestRule_Eval_WarnExpr(t *testing.T) {
+	values := map[string]float64{}
+	queryFn := func(_ context.Context, query string, _ time.Time) (promql.Vector, error) {
+		threshold := 0.9
+		if query == "cpu_usage > 0.7" {
+			threshold = 0.7
+		}
+		var vector promql.Vector
+		for host, v := range values {
+			if v > threshold {
+				vector = append(vector, promql.Sample{Metric: labels.FromStrings("instance", host), F: v})
+			}
+		}
+		return vector, nil
+	}
+
+	rule, err := NewRuleFromSpec(RuleSpec{Name: "HighCPU", Expr: "cpu_usage > 0.9", WarnExpr: "cpu_usage > 0.7"})
+	require.NoError(t, err)
+	states := func() map[string]AlertState {
+		m := make(map[string]AlertState)
+		for _, alert := range rule.ActiveAlerts(false) {
+			m[alert.Labels().Get("instance")] = alert.State()
+		}
+		return m
+	}
+
+	t0 := time.Now()
+	values["host1"], values["host2"] = 0.8, 0.95
+	alerts, err := rule.Eval(context.Background(), t0, queryFn)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1, "warn-only series must not notify")
+	require.Equal(t, "host2", alerts[0].Labels().Get("instance"))
+	require.Equal(t, map[string]AlertState{"host1": AlertStatePending, "host2": AlertStateFiring}, states())
+
+	alerts, err = rule.Eval(context.Background(), t0.Add(time.Hour), queryFn)
+	require.NoError(t, err)
+	require.Empty(t, alerts, "warn-only series stays pending regardless of elapsed time")
+	for _, alert := range rule.ActiveAlerts(false) {
+		if alert.Labels().Get("instance") == "host1" {
+			require.Equal(t, 0.8, alert.GetValue(), "warn-only series tracks its value")
+		}
+	}
+
+	// 降为预警的告警发送恢复通知并回到 pending
+	values["host2"] = 0.75
+	alerts, err = rule.Eval(context.Background(), t0.Add(2*time.Hour), queryFn)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateInactive, alerts[0].State())
+	_, err = rule.Eval(context.Background(), t0.Add(3*time.Hour), queryFn)
+	require.NoError(t, err)
+	require.Equal(t, map[string]AlertState{"host1": AlertStatePending, "host2": AlertStatePending}, states())
+
+	_, err = NewRuleFromSpec(RuleSpec{Name: "x", Expr: "up", AlertType: AlertTypeMultiTier, WarnExpr: "up"})
+	require.ErrorIs(t, err, ErrUnsupportedAlertType)
+}
+
+func TestRule_IdentityLabels(t *testing.T) {
+	rule := newTestRule(t, "PodCrash", "restarts > 0", &AlertOpts{})
+	rule.IdentityLabels = []string{"pod"}
+
+	eval := func(node string) []IAlert {
+		vector := promql.Vector{{Metric: labels.FromStrings("pod", "api-0", "node", node), F: 1}}
+		alerts, err := rule.EvalVector(context.Background(), time.Now(), vector)
+		require.NoError(t, err)
+		return alerts
+	}
+
+	alerts := eval("node-a")
+	require.Len(t, alerts, 1)
+	alert := alerts[0]
+	require.Equal(t, "node-a", NewNotification(rule, alert).Labels["node"])
+
+	// 非身份标签变化：沿用同一告警，通知标签随之更新
+	eval("node-b")
+	require.Len(t, rule.ActiveAlerts(true), 1)
+	require.Same(t, alert, rule.ActiveAlerts(true)[0])
+	require.Equal(t, map[string]string{"alertname": "PodCrash", "pod": "api-0", "node": "node-b"},
+		NewNotification(rule, alert).Labels)
+
+	// 持久化后按身份标签恢复
+	am := NewAlertManager([]*Rule{rule}, time.Second, nil, &recordNotifier{}, NewMemoryStorage())
+	state, err := am.ExportState()
+	require.NoError(t, err)
+	require.NoError(t, am.ImportState(state))
+	eval("node-c")
+	require.Len(t, rule.ActiveAlerts(true), 1)
+}
+
+func TestRule_EvalVector_LevelResendDelay(t *testing.T) {
+	rule, err := NewRuleFromSpec(RuleSpec{
+		Name:            "HighCPU",
+		Expr:            "cpu_usage",
+		AlertType:       AlertTypeMultiTier,
+		ResendDelay:     time.Hour,
+		LevelThresholds: []float64{0.7, 0.85, 0.95},
+		LevelResendDelay: map[AlertState]time.Duration{
+			AlertStateL1: 15 * time.Minute,
+			AlertStateL3: time.Minute,
+		},
+	})
+	require.NoError(t, err)
+	vector := promql.Vector{
+		{Metric: labels.FromStrings("instance", "host1"), F: 0.75},
+		{Metric: labels.FromStrings("instance", "host2"), F: 0.9},
+		{Metric: labels.FromStrings("instance", "host3"), F: 0.99},
+	}
+
+	sent := make(map[string]int)
+	t0 := time.Now()
+	for i := 0; i <= 30; i++ {
+		alerts, err := rule.EvalVector(context.Background(), t0.Add(time.Duration(i)*time.Minute), vector)
+		require.NoError(t, err)
+		for _, alert := range alerts {
+			sent[alert.Labels().Get("instance")]++
+		}
+	}
+	// 首次进入级别各通知一次，之后按各级别的间隔重发，L2 未覆盖，使用 ResendDelay
+	require.Equal(t, map[string]int{"host1": 3, "host2": 1, "host3": 31}, sent)
+
+	for _, spec := range []RuleSpec{
+		{Name: "x", Expr: "up", LevelResendDelay: map[AlertState]time.Duration{AlertStateL1: time.Minute}},
+		{Name: "x", Expr: "up", AlertType: AlertTypeMultiTier, LevelResendDelay: map[AlertState]time.Duration{AlertStateFiring: time.Minute}},
+		{Name: "x", Expr: "up", AlertType: AlertTypeMultiTier, LevelResendDelay: map[AlertState]time.Duration{AlertStateL1: -time.Minute}},
+	} {
+		_, err := NewRuleFromSpec(spec)
+		require.Error(t, err, "spec %+v", spec)
+	}
+}
+
+func TestRule_EvalVector_FiringThreshold(t *testing.T) {
+	rule := newTestRule(t, "HighCPU", "cpu_usage > 0.8", &AlertOpts{})
+	rule.FiringThreshold = FiringThreshold{N: 3, M: 5}
+	vector := promql.Vector{{Metric: labels.FromStrings("instance", "host1"), F: 0.9}}
+
+	t0 := time.Now()
+	eval := func(i int, present bool) []IAlert {
+		v := promql.Vector{}
+		if present {
+			v = vector
+		}
+		alerts, err := rule.EvalVector(context.Background(), t0.Add(time.Duration(i)*time.Minute), v)
+		require.NoError(t, err)
+		return alerts
+	}
+
+	// 间歇出现，最近 5 次中始终不足 3 次
+	for i, present := range []bool{true, false, false, true, false, false, true, false} {
+		require.Empty(t, eval(i, present), "eval %d", i)
+		require.Empty(t, rule.active, "eval %d: flaky series should not create an alert", i)
+	}
+
+	// 最近 5 次为 F T F T T，第 3 次出现时触发
+	require.Empty(t, eval(8, true))
+	alerts := eval(9, true)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateFiring, alerts[0].State())
+
+	// 窗口内仍有 3 次出现，但本轮缺失按缺失处理
+	alerts = eval(10, false)
+	require.Len(t, alerts, 1)
+	require.Equal(t, AlertStateInactive, alerts[0].State())
+
+	// 长时间缺失后窗口不再保留
+	for i := 11; i < 16; i++ {
+		eval(i, false)
+	}
+	require.Empty(t, rule.outcomes)
+
+	_, err := NewRuleFromSpec(RuleSpec{Name: "r", Expr: "up", FiringThreshold: FiringThreshold{N: 4, M: 3}})
+	require.Error(t, err)
+	spec, err := NewRuleFromSpec(RuleSpec{Name: "r", Expr: "up", FiringThreshold: FiringThreshold{N: 2, M: 3}})
+	require.NoError(t, err)
+	require.Equal(t, FiringThreshold{N: 2, M: 3}, spec.FiringThreshold)
+}
@@ -1,11 +1,14 @@
 package alertmanager
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
-	"github.com/prometheus/prometheus/model/labels"
+	"log"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 // Storage 定义持久化存储接口
@@ -14,9 +17,26 @@ type Storage interface {
 	LoadAlerts(r *Rule) ([]IAlert, error)
 }
 
+// Format 文件存储的编码格式，同时决定文件扩展名
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatGob  Format = "gob"
+)
+
+var formats = []Format{FormatJSON, FormatGob}
+
 // FileStorage 实现文件系统存储
 type FileStorage struct {
 	path string
+
+	// Lenient 为 true 时，加载过程中跳过损坏的单条告警而不是整体失败
+	Lenient bool
+	// Format 保存时使用的编码格式，零值为 FormatJSON；加载时按文件扩展名自动识别
+	Format Format
+	// Codec 单条告警的编码，为空时使用 JSONCodec
+	Codec Codec
 }
 
 func NewFileStorage(path string) (*FileStorage, error) {
@@ -27,21 +47,23 @@ func NewFileStorage(path string) (*FileStorage, error) {
 }
 
 func (fs *FileStorage) SaveAlerts(r *Rule, alerts []IAlert) error {
+	codec := codecOrDefault(fs.Codec)
 	var raw [][]byte
 	for _, alert := range alerts {
-		data, err := alert.Marshal()
+		data, err := codec.Encode(alert)
 		if err != nil {
 			return fmt.Errorf("failed to marshal alert: %v", err)
 		}
 		raw = append(raw, data)
 	}
 
-	combined, err := json.Marshal(raw)
+	format := fs.format()
+	combined, err := encodeAlertList(format, raw)
 	if err != nil {
 		return fmt.Errorf("failed to marshal alert list: %v", err)
 	}
 
-	filename := filepath.Join(fs.path, fmt.Sprintf("%s.json", r.Name))
+	filename := fs.filename(r, format)
 	tmpFilename := filename + ".tmp"
 	if err := os.WriteFile(tmpFilename, combined, 0644); err != nil {
 		return fmt.Errorf("failed to write alerts to temp file: %w", err)
@@ -49,71 +71,153 @@ func (fs *FileStorage) SaveAlerts(r *Rule, alerts []IAlert) error {
 	if err := os.Rename(tmpFilename, filename); err != nil {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
+
+	// 删除其他格式的旧文件，避免加载到过期状态
+	for _, f := range formats {
+		if f == format {
+			continue
+		}
+		if err := os.Remove(fs.filename(r, f)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale alert file: %w", err)
+		}
+	}
 	return nil
 }
 
 func (fs *FileStorage) LoadAlerts(r *Rule) ([]IAlert, error) {
-	filename := filepath.Join(fs.path, fmt.Sprintf("%s.json", r.Name))
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
+	// 优先读取当前格式的文件，其次尝试其他格式
+	candidates := []Format{fs.format()}
+	for _, f := range formats {
+		if f != candidates[0] {
+			candidates = append(candidates, f)
+		}
+	}
+
+	var (
+		data   []byte
+		format Format
+		err    error
+	)
+	for _, format = range candidates {
+		data, err = os.ReadFile(fs.filename(r, format))
+		if err == nil {
+			break
 		}
-		return nil, fmt.Errorf("failed to read alert file: %v", err)
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read alert file: %v", err)
+		}
+	}
+	if err != nil {
+		return nil, nil
 	}
 
-	var rawList [][]byte
-	if err := json.Unmarshal(data, &rawList); err != nil {
+	rawList, err := decodeAlertList(format, data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal alert list: %v", err)
 	}
 
+	return decodeAlerts(codecOrDefault(fs.Codec), r, rawList, fs.Lenient)
+}
+
+// decodeAlerts 解码规则的全部告警。lenient 为 true 时跳过损坏或告警类型不受支持的单条告警并记录日志，
+// 否则遇到第一条错误即整体失败
+func decodeAlerts(codec Codec, r *Rule, rawList [][]byte, lenient bool) ([]IAlert, error) {
 	var alerts []IAlert
 	for _, raw := range rawList {
-		alert, err := NewAlert(r.AlertType, labels.EmptyLabels(), r.AlertOpts)
+		alert, err := codec.Decode(raw, r.AlertOpts)
 		if err != nil {
-			return nil, err
-		}
-		if err := alert.Restore(raw, r.AlertOpts); err != nil {
-			return nil, fmt.Errorf("failed to restore alert: %v", err)
+			if lenient {
+				log.Printf("Skipping unrestorable alert for rule %s: %v", r.Name, err)
+				continue
+			}
+			return nil, fmt.Errorf("failed to restore alert: %w", err)
 		}
 		alerts = append(alerts, alert)
 	}
 	return alerts, nil
 }
 
+func (fs *FileStorage) format() Format {
+	if fs.Format == "" {
+		return FormatJSON
+	}
+	return fs.Format
+}
+
+func (fs *FileStorage) filename(r *Rule, format Format) string {
+	return filepath.Join(fs.path, fmt.Sprintf("%s.%s", r.Name, format))
+}
+
+func encodeAlertList(format Format, raw [][]byte) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.Marshal(raw)
+	case FormatGob:
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(raw); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage format: %s", format)
+	}
+}
+
+func decodeAlertList(format Format, data []byte) ([][]byte, error) {
+	var raw [][]byte
+	switch format {
+	case FormatJSON:
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	case FormatGob:
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported storage format: %s", format)
+	}
+	return raw, nil
+}
+
+// MemoryStorage 内存存储，主要用于测试
 type MemoryStorage struct {
+	mtx    sync.RWMutex
 	alerts map[string][][]byte
+
+	// Lenient 为 true 时，加载过程中跳过无法恢复的单条告警而不是整体失败
+	Lenient bool
+	// Codec 单条告警的编码，为空时使用 JSONCodec
+	Codec Codec
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{alerts: make(map[string][][]byte)}
 }
 
 func (m *MemoryStorage) SaveAlerts(r *Rule, alerts []IAlert) error {
+	codec := codecOrDefault(m.Codec)
 	var raw [][]byte
 	for _, alert := range alerts {
-		data, err := alert.Marshal()
+		data, err := codec.Encode(alert)
 		if err != nil {
 			return err
 		}
 		raw = append(raw, data)
 	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
 	m.alerts[r.Name] = raw
 	return nil
 }
 
 func (m *MemoryStorage) LoadAlerts(r *Rule) ([]IAlert, error) {
+	m.mtx.RLock()
 	raw, exists := m.alerts[r.Name]
+	m.mtx.RUnlock()
 	if !exists {
 		return nil, nil
 	}
 
-	var alerts []IAlert
-	for _, data := range raw {
-		alert, err := NewAlert(r.AlertType, labels.EmptyLabels(), r.AlertOpts)
-		if err != nil {
-			return nil, err
-		}
-		if err := alert.Restore(data, r.AlertOpts); err != nil {
-			return nil, err
-		}
-		alerts = append(alerts, alert)
-	}
-	return alerts, nil
+	return decodeAlerts(codecOrDefault(m.Codec), r, raw, m.Lenient)
 }
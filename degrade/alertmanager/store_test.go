@@ -0,0 +1,151 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage_LoadAlerts_Lenient(t *testing.T) {
+	opts := &AlertOpts{}
+	rule := newTestRule(t, "disk", "disk_used > 0", opts)
+
+	var rawList [][]byte
+	for _, instance := range []string{"host1", "host2", "host3"} {
+		alert, err := NewAlert(AlertTypeBasic, labels.FromStrings("instance", instance), opts)
+		require.NoError(t, err)
+		_, err = alert.Transition(context.Background(), true, time.Now())
+		require.NoError(t, err)
+		data, err := alert.Marshal()
+		require.NoError(t, err)
+		rawList = append(rawList, data)
+	}
+	rawList = append(rawList[:1], append([][]byte{[]byte("{corrupt")}, rawList[1:]...)...)
+
+	dir := t.TempDir()
+	combined, err := json.Marshal(rawList)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "disk.json"), combined, 0644))
+
+	storage, err := NewFileStorage(dir)
+	require.NoError(t, err)
+
+	_, err = storage.LoadAlerts(rule)
+	require.Error(t, err, "strict mode should fail on corrupt entry")
+
+	storage.Lenient = true
+	alerts, err := storage.LoadAlerts(rule)
+	require.NoError(t, err)
+	require.Len(t, alerts, 3)
+	for _, alert := range alerts {
+		require.Equal(t, AlertStateFiring, alert.State())
+	}
+}
+
+func TestMemoryStorage_LoadAlerts_UnsupportedType(t *testing.T) {
+	opts := &AlertOpts{}
+	rule := newTestRule(t, "disk", "disk_used > 0", opts)
+
+	var alerts []IAlert
+	for _, instance := range []string{"host1", "host2", "host3"} {
+		alert, err := NewAlert(AlertTypeBasic, labels.FromStrings("instance", instance), opts)
+		require.NoError(t, err)
+		_, err = alert.Transition(context.Background(), true, time.Now())
+		require.NoError(t, err)
+		alerts = append(alerts, alert)
+	}
+	storage := NewMemoryStorage()
+	require.NoError(t, storage.SaveAlerts(rule, alerts))
+
+	// 将 host2 的告警类型改为不受支持的类型
+	var persisted map[string]any
+	require.NoError(t, json.Unmarshal(storage.alerts["disk"][1], &persisted))
+	persisted["type"] = "unknown"
+	data, err := json.Marshal(persisted)
+	require.NoError(t, err)
+	storage.alerts["disk"][1] = data
+
+	_, err = storage.LoadAlerts(rule)
+	require.ErrorIs(t, err, ErrUnsupportedAlertType, "strict mode fails the whole load")
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	storage.Lenient = true
+	loaded, err := storage.LoadAlerts(rule)
+	require.NoError(t, err)
+	var instances []string
+	for _, alert := range loaded {
+		instances = append(instances, alert.Labels().Get("instance"))
+	}
+	require.Equal(t, []string{"host1", "host3"}, instances)
+	require.Contains(t, logs.String(), "Skipping unrestorable alert for rule disk")
+	require.Contains(t, logs.String(), "unsupported alert type: unknown")
+}
+
+func TestFileStorage_Formats(t *testing.T) {
+	opts := &AlertOpts{HoldDuration: time.Minute}
+	rule := newTestRule(t, "cpu", "cpu_usage > 0.8", opts)
+
+	var alerts []IAlert
+	for _, instance := range []string{"host1", "host2"} {
+		alert, err := NewAlert(AlertTypeBasic, labels.FromStrings("instance", instance), opts)
+		require.NoError(t, err)
+		alert.SetValue(0.9)
+		_, err = alert.Transition(context.Background(), true, time.Now())
+		require.NoError(t, err)
+		alerts = append(alerts, alert)
+	}
+	marshalAll := func(alerts []IAlert) map[string]string {
+		out := make(map[string]string)
+		for _, alert := range alerts {
+			data, err := alert.Marshal()
+			require.NoError(t, err)
+			out[alert.Labels().Get("instance")] = string(data)
+		}
+		return out
+	}
+
+	for _, format := range []Format{FormatJSON, FormatGob} {
+		t.Run(string(format), func(t *testing.T) {
+			dir := t.TempDir()
+			storage, err := NewFileStorage(dir)
+			require.NoError(t, err)
+			storage.Format = format
+
+			require.NoError(t, storage.SaveAlerts(rule, alerts))
+			require.FileExists(t, filepath.Join(dir, "cpu."+string(format)))
+
+			loaded, err := storage.LoadAlerts(rule)
+			require.NoError(t, err)
+			require.Equal(t, marshalAll(alerts), marshalAll(loaded))
+		})
+	}
+
+	// 切换格式后仍可加载旧格式文件，保存后旧文件被清理
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir)
+	require.NoError(t, err)
+	storage.Format = FormatGob
+	require.NoError(t, storage.SaveAlerts(rule, alerts))
+
+	storage.Format = FormatJSON
+	loaded, err := storage.LoadAlerts(rule)
+	require.NoError(t, err)
+	require.Equal(t, marshalAll(alerts), marshalAll(loaded))
+
+	require.NoError(t, storage.SaveAlerts(rule, alerts[:1]))
+	require.NoFileExists(t, filepath.Join(dir, "cpu.gob"))
+	loaded, err = storage.LoadAlerts(rule)
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+}
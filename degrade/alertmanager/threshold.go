@@ -0,0 +1,78 @@
+package alertmanager
+
+import "fmt"
+
+// FiringThreshold 最近 M 次评估中至少 N 次满足条件时才视为满足，M 为 0 时不启用
+type FiringThreshold struct {
+	N int `yaml:"n" json:"n"`
+	M int `yaml:"m" json:"m"`
+}
+
+func (t FiringThreshold) enabled() bool {
+	return t.M > 0
+}
+
+func (t FiringThreshold) validate() error {
+	if t.M < 0 || (t.enabled() && (t.N <= 0 || t.N > t.M)) {
+		return fmt.Errorf("firing threshold %d of %d: want 0 < n <= m", t.N, t.M)
+	}
+	return nil
+}
+
+// outcomeRing 记录一个序列最近 M 次评估的结果
+type outcomeRing struct {
+	buf    []bool
+	next   int
+	size   int
+	active int  // buf 中满足条件的次数
+	seen   bool // 本轮评估是否已记录
+}
+
+func newOutcomeRing(m int) *outcomeRing {
+	return &outcomeRing{buf: make([]bool, m)}
+}
+
+func (o *outcomeRing) push(active bool) {
+	if o.size == len(o.buf) {
+		if o.buf[o.next] {
+			o.active--
+		}
+	} else {
+		o.size++
+	}
+	o.buf[o.next] = active
+	if active {
+		o.active++
+	}
+	o.next = (o.next + 1) % len(o.buf)
+}
+
+// recordOutcome 记录 fp 本轮满足条件，返回最近 M 次中是否至少 N 次满足
+func (r *Rule) recordOutcome(fp uint64) bool {
+	ring, ok := r.outcomes[fp]
+	if !ok {
+		if r.outcomes == nil {
+			r.outcomes = make(map[uint64]*outcomeRing)
+		}
+		ring = newOutcomeRing(r.FiringThreshold.M)
+		r.outcomes[fp] = ring
+	}
+	ring.push(true)
+	ring.seen = true
+	return ring.active >= r.FiringThreshold.N
+}
+
+// ageOutcomes 在一轮评估结束时为未出现在查询结果中的序列记录一次未满足，
+// 窗口内全部未满足的序列不再保留
+func (r *Rule) ageOutcomes() {
+	for fp, ring := range r.outcomes {
+		if ring.seen {
+			ring.seen = false
+			continue
+		}
+		ring.push(false)
+		if ring.active == 0 {
+			delete(r.outcomes, fp)
+		}
+	}
+}
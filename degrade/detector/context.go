@@ -0,0 +1,24 @@
+package detector
+
+import "context"
+
+// levelKey is the context key for the classified degrade level.
+type levelKey struct{}
+
+// ClassifyContext classifies a request and returns a context carrying the
+// level, so downstream handlers can read it with LevelFromContext.
+func (qc *QpsTierClassifier) ClassifyContext(ctx context.Context) (context.Context, int) {
+	level := qc.Classify()
+	return WithLevel(ctx, level), level
+}
+
+// WithLevel returns a copy of ctx carrying the given degrade level.
+func WithLevel(ctx context.Context, level int) context.Context {
+	return context.WithValue(ctx, levelKey{}, level)
+}
+
+// LevelFromContext returns the degrade level stored in ctx, if any.
+func LevelFromContext(ctx context.Context) (int, bool) {
+	level, ok := ctx.Value(levelKey{}).(int)
+	return level, ok
+}
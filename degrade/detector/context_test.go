@@ -0,0 +1,34 @@
+package detector
+
+import (
+	"context"
+	"testing"
+)
+
+type requestKey struct{}
+
+func TestQpsTierClassifier_ClassifyContext(t *testing.T) {
+	if _, ok := LevelFromContext(context.Background()); ok {
+		t.Fatal("expected no level in a bare context")
+	}
+
+	tier := NewQpsTierClassifier([]int{2, 4}) // 每层桶容量 2
+	want := []int{0, 0, 1, 1, 2}
+	parent := context.WithValue(context.Background(), requestKey{}, "r1")
+	for i, expected := range want {
+		ctx, level := tier.ClassifyContext(parent)
+		if level != expected {
+			t.Errorf("request %d: expected level %d, got %d", i, expected, level)
+		}
+		got, ok := LevelFromContext(ctx)
+		if !ok || got != level {
+			t.Errorf("request %d: expected level %d in context, got %d (ok=%v)", i, level, got, ok)
+		}
+		if ctx.Value(requestKey{}) != "r1" {
+			t.Errorf("request %d: expected derived context to keep parent values", i)
+		}
+	}
+	if _, ok := LevelFromContext(parent); ok {
+		t.Error("expected parent context to be unchanged")
+	}
+}
@@ -0,0 +1,72 @@
+package detector
+
+import (
+	"sync"
+	"time"
+)
+
+// KeyedQpsTierClassifier classifies requests per key, each key owning its own QPS tiers.
+type KeyedQpsTierClassifier struct {
+	tiers []int
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*keyedEntry
+	lastSweep time.Time
+}
+
+type keyedEntry struct {
+	classifier *QpsTierClassifier
+	lastSeen   time.Time
+}
+
+// NewKeyedQpsTierClassifier initializes a keyed classifier. Keys idle for longer than ttl are evicted;
+// a non-positive ttl disables eviction.
+func NewKeyedQpsTierClassifier(tiers []int, ttl time.Duration) *KeyedQpsTierClassifier {
+	NewQpsTierClassifier(tiers) // validate tiers eagerly
+
+	return &KeyedQpsTierClassifier{
+		tiers:     append([]int(nil), tiers...),
+		ttl:       ttl,
+		entries:   make(map[string]*keyedEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Classify returns the tier level for a request of the given key.
+func (kc *KeyedQpsTierClassifier) Classify(key string) int {
+	now := time.Now()
+
+	kc.mu.Lock()
+	kc.sweep(now)
+	entry, ok := kc.entries[key]
+	if !ok {
+		entry = &keyedEntry{classifier: NewQpsTierClassifier(kc.tiers)}
+		kc.entries[key] = entry
+	}
+	entry.lastSeen = now
+	kc.mu.Unlock()
+
+	return entry.classifier.Classify()
+}
+
+// Len returns the number of keys currently tracked.
+func (kc *KeyedQpsTierClassifier) Len() int {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	kc.sweep(time.Now())
+	return len(kc.entries)
+}
+
+// sweep evicts idle keys, at most once per ttl. Caller must hold kc.mu.
+func (kc *KeyedQpsTierClassifier) sweep(now time.Time) {
+	if kc.ttl <= 0 || now.Sub(kc.lastSweep) < kc.ttl {
+		return
+	}
+	for key, entry := range kc.entries {
+		if now.Sub(entry.lastSeen) >= kc.ttl {
+			delete(kc.entries, key)
+		}
+	}
+	kc.lastSweep = now
+}
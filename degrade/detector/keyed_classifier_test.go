@@ -0,0 +1,56 @@
+package detector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyedQpsTierClassifier_Independent(t *testing.T) {
+	kc := NewKeyedQpsTierClassifier([]int{5, 10}, time.Minute)
+
+	// Exhaust all tiers for the heavy key.
+	for i := 0; i < 20; i++ {
+		kc.Classify("heavy")
+	}
+	if level := kc.Classify("heavy"); level != 2 {
+		t.Errorf("Expected heavy key to exceed all tiers, got level %d", level)
+	}
+	if level := kc.Classify("light"); level != 0 {
+		t.Errorf("Expected light key to be classified independently at level 0, got %d", level)
+	}
+	if kc.Len() != 2 {
+		t.Errorf("Expected 2 tracked keys, got %d", kc.Len())
+	}
+}
+
+func TestKeyedQpsTierClassifier_Eviction(t *testing.T) {
+	kc := NewKeyedQpsTierClassifier([]int{5, 10}, 50*time.Millisecond)
+
+	kc.Classify("idle")
+	kc.Classify("active")
+
+	deadline := time.Now().Add(time.Second)
+	for kc.Len() > 1 && time.Now().Before(deadline) {
+		kc.Classify("active")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if kc.Len() != 1 {
+		t.Fatalf("Expected idle key to be evicted, got %d keys", kc.Len())
+	}
+	kc.mu.Lock()
+	_, ok := kc.entries["active"]
+	kc.mu.Unlock()
+	if !ok {
+		t.Error("Expected active key to be retained")
+	}
+}
+
+func TestKeyedQpsTierClassifier_InvalidTiers(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("Expected panic on unordered tiers")
+		}
+	}()
+	NewKeyedQpsTierClassifier([]int{10, 5}, time.Minute)
+}
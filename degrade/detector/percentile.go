@@ -0,0 +1,120 @@
+package detector
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// Buckets are log-spaced from percentileMinLatency with percentileBucketsPerOctave
+	// buckets per doubling, which keeps the relative error of an estimate under ~5%.
+	percentileMinLatency       = time.Microsecond
+	percentileBucketsPerOctave = 8
+	percentileOctaves          = 27 // 1µs .. ~134s
+	percentileNumBuckets       = percentileBucketsPerOctave * percentileOctaves
+
+	// percentileDecaySteps is how many times per window the counts are decayed.
+	percentileDecaySteps = 8
+)
+
+// PercentileTracker estimates latency percentiles from a fixed histogram of
+// log-spaced buckets. Older observations decay exponentially so estimates
+// follow the last window. Observe and Percentile do not allocate.
+type PercentileTracker struct {
+	mu        sync.Mutex
+	counts    [percentileNumBuckets]float64
+	total     float64
+	window    time.Duration
+	decay     float64 // factor applied to counts every window/percentileDecaySteps
+	lastDecay time.Time
+	now       func() time.Time
+}
+
+// NewPercentileTracker creates a tracker whose observations lose 1-1/e
+// (about 63%) of their weight over each window.
+func NewPercentileTracker(window time.Duration) *PercentileTracker {
+	if window <= 0 {
+		panic("window must be positive")
+	}
+	pt := &PercentileTracker{
+		window: window,
+		decay:  math.Exp(-1.0 / percentileDecaySteps),
+		now:    time.Now,
+	}
+	pt.lastDecay = pt.now()
+	return pt
+}
+
+// Observe records a single latency.
+func (pt *PercentileTracker) Observe(d time.Duration) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.maybeDecay()
+	pt.counts[bucketIndex(d)]++
+	pt.total++
+}
+
+// Percentile returns the estimated latency at quantile p (0 < p <= 1, e.g.
+// 0.99 for p99). It returns 0 when nothing has been observed.
+func (pt *PercentileTracker) Percentile(p float64) time.Duration {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.maybeDecay()
+	if pt.total <= 0 {
+		return 0
+	}
+	p = math.Min(math.Max(p, 0), 1)
+
+	rank := p * pt.total
+	var seen float64
+	for i, c := range pt.counts {
+		if c == 0 || seen+c < rank {
+			seen += c
+			continue
+		}
+		// Interpolate geometrically inside the bucket.
+		frac := (rank - seen) / c
+		lower := bucketLowerBound(i)
+		return time.Duration(lower * math.Pow(2, frac/percentileBucketsPerOctave))
+	}
+	return time.Duration(bucketLowerBound(percentileNumBuckets))
+}
+
+// maybeDecay applies the decay steps that elapsed since the last call.
+func (pt *PercentileTracker) maybeDecay() {
+	step := pt.window / percentileDecaySteps
+	if step <= 0 {
+		step = 1
+	}
+	elapsed := pt.now().Sub(pt.lastDecay)
+	if elapsed < step {
+		return
+	}
+	n := int(elapsed / step)
+	pt.lastDecay = pt.lastDecay.Add(time.Duration(n) * step)
+
+	factor := math.Pow(pt.decay, float64(n))
+	pt.total = 0
+	for i := range pt.counts {
+		pt.counts[i] *= factor
+		pt.total += pt.counts[i]
+	}
+}
+
+// bucketIndex maps a latency to its bucket, clamping to the first and last bucket.
+func bucketIndex(d time.Duration) int {
+	if d <= percentileMinLatency {
+		return 0
+	}
+	idx := int(math.Log2(float64(d)/float64(percentileMinLatency)) * percentileBucketsPerOctave)
+	if idx >= percentileNumBuckets {
+		return percentileNumBuckets - 1
+	}
+	return idx
+}
+
+// bucketLowerBound returns the lower bound of bucket i in nanoseconds.
+func bucketLowerBound(i int) float64 {
+	return float64(percentileMinLatency) * math.Pow(2, float64(i)/percentileBucketsPerOctave)
+}
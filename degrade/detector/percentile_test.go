@@ -0,0 +1,76 @@
+package detector
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func withinTolerance(got, want time.Duration, tol float64) bool {
+	return math.Abs(float64(got-want)) <= tol*float64(want)
+}
+
+func TestPercentileTracker_UniformDistribution(t *testing.T) {
+	pt := NewPercentileTracker(time.Minute)
+	// 1ms..1000ms uniformly, so pN is roughly N*10ms
+	for i := 1; i <= 1000; i++ {
+		pt.Observe(time.Duration(i) * time.Millisecond)
+	}
+
+	for _, tc := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.5, 500 * time.Millisecond},
+		{0.95, 950 * time.Millisecond},
+		{0.99, 990 * time.Millisecond},
+	} {
+		got := pt.Percentile(tc.p)
+		t.Logf("p%v = %v", tc.p*100, got)
+		if !withinTolerance(got, tc.want, 0.05) {
+			t.Errorf("p%v = %v, want %v ±5%%", tc.p*100, got, tc.want)
+		}
+	}
+}
+
+func TestPercentileTracker_Empty(t *testing.T) {
+	pt := NewPercentileTracker(time.Minute)
+	if got := pt.Percentile(0.99); got != 0 {
+		t.Errorf("expected 0 for empty tracker, got %v", got)
+	}
+}
+
+func TestPercentileTracker_Decay(t *testing.T) {
+	now := time.Now()
+	pt := NewPercentileTracker(time.Second)
+	pt.now = func() time.Time { return now }
+	pt.lastDecay = now
+
+	for i := 0; i < 1000; i++ {
+		pt.Observe(500 * time.Millisecond)
+	}
+	// A point mass can land anywhere in its bucket, so allow the bucket width.
+	if got := pt.Percentile(0.5); !withinTolerance(got, 500*time.Millisecond, 0.1) {
+		t.Fatalf("p50 = %v before decay, want ~500ms", got)
+	}
+
+	// After several windows the old slow samples carry almost no weight.
+	now = now.Add(10 * time.Second)
+	for i := 0; i < 100; i++ {
+		pt.Observe(5 * time.Millisecond)
+	}
+	if got := pt.Percentile(0.95); !withinTolerance(got, 5*time.Millisecond, 0.1) {
+		t.Errorf("p95 = %v after decay, want ~5ms", got)
+	}
+}
+
+func TestPercentileTracker_NoAlloc(t *testing.T) {
+	pt := NewPercentileTracker(time.Minute)
+	allocs := testing.AllocsPerRun(1000, func() {
+		pt.Observe(3 * time.Millisecond)
+		_ = pt.Percentile(0.99)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
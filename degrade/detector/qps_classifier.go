@@ -1,19 +1,51 @@
 package detector
 
 import (
-	"golang.org/x/time/rate"
+	"math"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
+// LevelSource exposes a classifier's state so it can be exported as metrics.
+type LevelSource interface {
+	// Level returns the most recently classified level.
+	Level() int
+	// Admissions returns the cumulative number of requests classified into
+	// each level; the last entry counts requests exceeding all tiers.
+	Admissions() []uint64
+}
+
 // QpsTierClassifier classifies requests based on QPS tiers.
 type QpsTierClassifier struct {
 	limiters  []*rate.Limiter // Rate limiters for each QPS tier
-	tiers     []int           // QPS thresholds
-	createdAt time.Time
+	tiers     []float64       // QPS thresholds
+	startedAt atomic.Int64    // UnixNano of the first classified request, 0 until then
+
+	lastLevel  atomic.Int64
+	admissions []atomic.Uint64 // Per-level counts, one extra for over-limit
+
+	// WarmUp is how long after the first classified request classification
+	// is not trusted.
+	// Limiters start with full buckets, so early bursts would all land in tier 0.
+	WarmUp time.Duration
+	// WarmUpLevel is returned by Classify during the warm-up period.
+	WarmUpLevel int
 }
 
 // NewQpsTierClassifier initializes a classifier with given QPS tiers.
 func NewQpsTierClassifier(tiers []int) *QpsTierClassifier {
+	floats := make([]float64, len(tiers))
+	for i, tier := range tiers {
+		floats[i] = float64(tier)
+	}
+	return NewQpsTierClassifierFloat(floats)
+}
+
+// NewQpsTierClassifierFloat initializes a classifier with fractional QPS
+// tiers, e.g. 0.5 for an operation allowed once every two seconds.
+func NewQpsTierClassifierFloat(tiers []float64) *QpsTierClassifier {
 	if len(tiers) == 0 {
 		panic("tiers cannot be empty")
 	}
@@ -23,7 +55,7 @@ func NewQpsTierClassifier(tiers []int) *QpsTierClassifier {
 		}
 	}
 
-	deltas := make([]int, len(tiers))
+	deltas := make([]float64, len(tiers))
 	deltas[0] = tiers[0]
 	for i := 1; i < len(tiers); i++ {
 		deltas[i] = tiers[i] - tiers[i-1]
@@ -31,22 +63,99 @@ func NewQpsTierClassifier(tiers []int) *QpsTierClassifier {
 
 	limiters := make([]*rate.Limiter, len(deltas))
 	for i, delta := range deltas {
-		limiters[i] = rate.NewLimiter(rate.Limit(delta), delta)
+		// Sub-unit rates still need a burst of one to admit anything.
+		limiters[i] = rate.NewLimiter(rate.Limit(delta), int(math.Ceil(delta)))
 	}
 
 	return &QpsTierClassifier{
-		limiters:  limiters,
-		tiers:     tiers,
-		createdAt: time.Now(),
+		limiters:   limiters,
+		tiers:      append([]float64(nil), tiers...),
+		admissions: make([]atomic.Uint64, len(limiters)+1),
 	}
 }
 
-// Classify returns the tier level for a request based on QPS.
+// Classify returns the tier level for a request based on QPS. During the
+// warm-up period requests still drain the limiters but WarmUpLevel is returned.
 func (qc *QpsTierClassifier) Classify() int {
+	return qc.ClassifyAt(time.Now())
+}
+
+// ClassifyAt classifies a request arriving at t instead of now, so a recorded
+// request timeline can be replayed deterministically. Timestamps should be
+// non-decreasing; the limiters treat earlier times as the latest one seen.
+// The warm-up period is also measured against t, starting from the first
+// classified timestamp, so timelines recorded before construction replay
+// the same way.
+func (qc *QpsTierClassifier) ClassifyAt(t time.Time) int {
+	level := qc.classify(t)
+	if qc.warmingUp(t) {
+		level = qc.WarmUpLevel
+	}
+	qc.record(level)
+	return level
+}
+
+func (qc *QpsTierClassifier) classify(t time.Time) int {
 	for level, limiter := range qc.limiters {
-		if limiter.Allow() {
+		if limiter.AllowN(t, 1) {
 			return level
 		}
 	}
 	return len(qc.limiters) // Request exceeds all limits
 }
+
+// warmingUp reports whether now is still within the warm-up period, which
+// starts at the first classified request.
+func (qc *QpsTierClassifier) warmingUp(now time.Time) bool {
+	if qc.WarmUp <= 0 {
+		return false
+	}
+	qc.startedAt.CompareAndSwap(0, now.UnixNano())
+	return now.Sub(time.Unix(0, qc.startedAt.Load())) < qc.WarmUp
+}
+
+// ClassifyWithHeadroom returns the tier level for a request along with the
+// tokens left in the admitting tier's limiter. Headroom is 0 when the request
+// exceeds all tiers or during the warm-up period.
+func (qc *QpsTierClassifier) ClassifyWithHeadroom() (int, float64) {
+	now := time.Now()
+	for level, limiter := range qc.limiters {
+		if limiter.AllowN(now, 1) {
+			if qc.warmingUp(now) {
+				qc.record(qc.WarmUpLevel)
+				return qc.WarmUpLevel, 0
+			}
+			qc.record(level)
+			return level, limiter.TokensAt(now)
+		}
+	}
+	if qc.warmingUp(now) {
+		qc.record(qc.WarmUpLevel)
+		return qc.WarmUpLevel, 0
+	}
+	qc.record(len(qc.limiters))
+	return len(qc.limiters), 0
+}
+
+// record tracks the classified level for Level and Admissions.
+func (qc *QpsTierClassifier) record(level int) {
+	qc.lastLevel.Store(int64(level))
+	if level >= 0 && level < len(qc.admissions) {
+		qc.admissions[level].Add(1)
+	}
+}
+
+// Level returns the most recently classified level.
+func (qc *QpsTierClassifier) Level() int {
+	return int(qc.lastLevel.Load())
+}
+
+// Admissions returns the cumulative number of requests classified into each
+// level; the last entry counts requests exceeding all tiers.
+func (qc *QpsTierClassifier) Admissions() []uint64 {
+	counts := make([]uint64, len(qc.admissions))
+	for i := range qc.admissions {
+		counts[i] = qc.admissions[i].Load()
+	}
+	return counts
+}
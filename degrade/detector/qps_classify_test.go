@@ -88,3 +88,135 @@ func TestQpsTierClassifier_Concurrency(t *testing.T) {
 		t.Error("Expected some requests to exceed all levels under concurrency")
 	}
 }
+
+func TestQpsTierClassifier_ClassifyWithHeadroom(t *testing.T) {
+	tier := NewQpsTierClassifier([]int{10, 20})
+
+	prev := 10.0
+	for i := 0; i < 10; i++ {
+		level, headroom := tier.ClassifyWithHeadroom()
+		if level != 0 {
+			t.Fatalf("Expected level 0 while tier 0 has tokens, got %d", level)
+		}
+		if headroom >= prev {
+			t.Errorf("Expected headroom to shrink, got %.3f after %.3f", headroom, prev)
+		}
+		prev = headroom
+	}
+	if prev > 0.5 {
+		t.Errorf("Expected headroom near zero at saturation, got %.3f", prev)
+	}
+
+	level, headroom := tier.ClassifyWithHeadroom()
+	if level != 1 {
+		t.Errorf("Expected overflow into level 1, got %d", level)
+	}
+	if headroom < 8 {
+		t.Errorf("Expected level 1 to have most of its burst left, got %.3f", headroom)
+	}
+
+	for i := 0; i < 20; i++ {
+		tier.ClassifyWithHeadroom()
+	}
+	level, headroom = tier.ClassifyWithHeadroom()
+	if level != 2 || headroom != 0 {
+		t.Errorf("Expected (2, 0) when all tiers are exhausted, got (%d, %.3f)", level, headroom)
+	}
+}
+
+func TestQpsTierClassifier_WarmUp(t *testing.T) {
+	tier := NewQpsTierClassifier([]int{10, 20})
+	tier.WarmUp = 100 * time.Millisecond
+	tier.WarmUpLevel = 2
+
+	// Full buckets would admit this burst at tier 0; warm-up reports the default instead.
+	for i := 0; i < 5; i++ {
+		if level := tier.Classify(); level != 2 {
+			t.Fatalf("request %d during warm-up: expected level 2, got %d", i, level)
+		}
+	}
+	if level, headroom := tier.ClassifyWithHeadroom(); level != 2 || headroom != 0 {
+		t.Fatalf("expected (2, 0) during warm-up, got (%d, %v)", level, headroom)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if level := tier.Classify(); level != 0 {
+		t.Errorf("expected level 0 after warm-up at low rate, got %d", level)
+	}
+}
+
+func TestQpsTierClassifierFloat_FractionalTiers(t *testing.T) {
+	tier := NewQpsTierClassifierFloat([]float64{0.5, 1.0, 2.0}) // 增量 0.5、0.5、1.0 QPS
+
+	// 每个级别的突发容量为 1
+	for want := 0; want <= 3; want++ {
+		if level := tier.Classify(); level != want {
+			t.Fatalf("burst request: expected level %d, got %d", want, level)
+		}
+	}
+
+	// 1 秒后 0.5 QPS 的级别只恢复了半个令牌，只有 1.0 QPS 的级别可以放行
+	time.Sleep(1100 * time.Millisecond)
+	if level := tier.Classify(); level != 2 {
+		t.Fatalf("after 1s: expected level 2, got %d", level)
+	}
+
+	// 2 秒后 0.5 QPS 的级别恢复一个令牌
+	time.Sleep(1000 * time.Millisecond)
+	if level := tier.Classify(); level != 0 {
+		t.Fatalf("after 2s: expected level 0, got %d", level)
+	}
+}
+
+func TestQpsTierClassifierFloat_UnorderedTiers(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("Expected panic on unordered tiers")
+		}
+	}()
+	NewQpsTierClassifierFloat([]float64{1.0, 0.5})
+}
+
+func TestQpsTierClassifier_ClassifyAt(t *testing.T) {
+	tier := NewQpsTierClassifier([]int{2, 4}) // 两层各 2 QPS，突发容量 2
+	t0 := time.Now()
+
+	timeline := []struct {
+		offset time.Duration
+		want   int
+	}{
+		// 满桶突发：两层各放行 2 个，之后超限
+		{0, 0}, {0, 0}, {0, 1}, {0, 1}, {0, 2},
+		// 0.5 秒后每层恢复 1 个令牌
+		{500 * time.Millisecond, 0},
+		{500 * time.Millisecond, 1},
+		{500 * time.Millisecond, 2},
+		// 再过 0.25 秒只恢复半个令牌
+		{750 * time.Millisecond, 2},
+		// 2 秒后桶已满但不超过突发容量
+		{3 * time.Second, 0}, {3 * time.Second, 0}, {3 * time.Second, 1},
+	}
+	for i, req := range timeline {
+		if level := tier.ClassifyAt(t0.Add(req.offset)); level != req.want {
+			t.Errorf("request %d at +%v: expected level %d, got %d", i, req.offset, req.want, level)
+		}
+	}
+	if got := tier.Admissions(); got[0] != 5 || got[1] != 4 || got[2] != 3 {
+		t.Errorf("unexpected admissions %v", got)
+	}
+
+	// 预热期同样按给定时间判断，从第一次分类的时间开始计算，回放早于构造时间的记录也不受影响
+	warm := NewQpsTierClassifier([]int{2})
+	warm.WarmUp = time.Minute
+	warm.WarmUpLevel = 1
+	recorded := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if level := warm.ClassifyAt(recorded); level != 1 {
+		t.Errorf("expected warm-up level at the first request, got %d", level)
+	}
+	if level := warm.ClassifyAt(recorded.Add(30 * time.Second)); level != 1 {
+		t.Errorf("expected warm-up level within the period, got %d", level)
+	}
+	if level := warm.ClassifyAt(recorded.Add(2 * time.Minute)); level != 0 {
+		t.Errorf("expected classification after warm-up, got %d", level)
+	}
+}
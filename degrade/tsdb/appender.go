@@ -1,6 +1,7 @@
 package tsdb
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/prometheus/prometheus/model/exemplar"
@@ -9,10 +10,24 @@ import (
 	"github.com/prometheus/prometheus/model/metadata"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
 )
 
+// ErrTooManySeries 提交后序列数会超过 InMemoryDB.MaxSeries
+var ErrTooManySeries = errors.New("too many series")
+
+// InMemoryAppender 缓存追加的样本，Commit 时统一校验并写入，任一样本非法则整批丢弃。
+// Append 返回序列引用（标签哈希），后续以该引用追加可跳过标签校验和哈希计算
 type InMemoryAppender struct {
-	db *InMemoryDB
+	db      *InMemoryDB
+	pending []pendingSample
+	refs    map[storage.SeriesRef]labels.Labels // 本 appender 已校验过的序列
+}
+
+type pendingSample struct {
+	ref    uint64
+	labels labels.Labels
+	sample chunks.Sample
 }
 
 func (a *InMemoryAppender) SetOptions(*storage.AppendOptions) {
@@ -25,8 +40,7 @@ func NewInMemoryAppender(db *InMemoryDB) storage.Appender {
 	}
 }
 
-func (a *InMemoryAppender) getOrCreateSeries(l labels.Labels) *InMemorySeries {
-	key := l.Hash()
+func (a *InMemoryAppender) getOrCreateSeries(key uint64, l labels.Labels) *InMemorySeries {
 	series, ok := a.db.series[key]
 	if !ok {
 		series = &InMemorySeries{Labels: l}
@@ -36,15 +50,59 @@ func (a *InMemoryAppender) getOrCreateSeries(l labels.Labels) *InMemorySeries {
 	return series
 }
 
+// resolveRef 解析序列引用：ref 有效时直接使用对应序列的标签，否则校验 l 并计算新的引用
+func (a *InMemoryAppender) resolveRef(ref storage.SeriesRef, l labels.Labels) (storage.SeriesRef, labels.Labels, error) {
+	if ref != 0 {
+		if known, ok := a.refs[ref]; ok {
+			return ref, known, nil
+		}
+		a.db.mutex.RLock()
+		series, ok := a.db.series[uint64(ref)]
+		a.db.mutex.RUnlock()
+		if ok {
+			a.remember(ref, series.Labels)
+			return ref, series.Labels, nil
+		}
+	}
+
+	l, err := a.validateLabels(l)
+	if err != nil {
+		return 0, l, err
+	}
+	ref = storage.SeriesRef(l.Hash())
+	a.remember(ref, l)
+	return ref, l, nil
+}
+
+func (a *InMemoryAppender) remember(ref storage.SeriesRef, l labels.Labels) {
+	if a.refs == nil {
+		a.refs = make(map[storage.SeriesRef]labels.Labels)
+	}
+	a.refs[ref] = l
+}
+
+func (a *InMemoryAppender) validateLabels(l labels.Labels) (labels.Labels, error) {
+	l = l.WithoutEmpty()
+	if l.IsEmpty() {
+		return l, fmt.Errorf("empty labelset: %w", tsdb.ErrInvalidSample)
+	}
+	if a.db.RequireMetricName && !l.Has(labels.MetricName) {
+		return l, fmt.Errorf("missing %s in %s: %w", labels.MetricName, l, tsdb.ErrInvalidSample)
+	}
+
+	if lbl, dup := l.HasDuplicateLabelNames(); dup {
+		return l, fmt.Errorf(`label name "%s" is not unique: %w`, lbl, tsdb.ErrInvalidSample)
+	}
+	return l, nil
+}
+
 func (a *InMemoryAppender) AppendHistogram(
-	_ storage.SeriesRef,
+	ref storage.SeriesRef,
 	l labels.Labels,
 	t int64,
 	h *histogram.Histogram,
 	fh *histogram.FloatHistogram,
 ) (storage.SeriesRef, error) {
-	a.db.mutex.Lock()
-	defer a.db.mutex.Unlock()
 	if h != nil {
 		if err := h.Validate(); err != nil {
 			return 0, err
@@ -57,49 +115,34 @@ func (a *InMemoryAppender) AppendHistogram(
 		}
 	}
 
-	l = l.WithoutEmpty()
-	if l.IsEmpty() {
-		return 0, fmt.Errorf("empty labelset: %w", tsdb.ErrInvalidSample)
-	}
-
-	if lbl, dup := l.HasDuplicateLabelNames(); dup {
-		return 0, fmt.Errorf(`label name "%s" is not unique: %w`, lbl, tsdb.ErrInvalidSample)
+	ref, l, err := a.resolveRef(ref, l)
+	if err != nil {
+		return 0, err
 	}
 
-	series := a.getOrCreateSeries(l)
-
 	switch {
 	case h != nil:
-		series.Samples = append(series.Samples, newSample(t, 0, h, nil))
+		a.pending = append(a.pending, pendingSample{ref: uint64(ref), labels: l, sample: newSample(t, 0, h, nil)})
 	case fh != nil:
-		series.Samples = append(series.Samples, newSample(t, 0, nil, fh))
+		a.pending = append(a.pending, pendingSample{ref: uint64(ref), labels: l, sample: newSample(t, 0, nil, fh)})
 	}
 
-	return 0, nil
+	return ref, nil
 }
 
 func (a *InMemoryAppender) Append(
-	_ storage.SeriesRef,
+	ref storage.SeriesRef,
 	l labels.Labels,
 	t int64,
 	v float64,
 ) (storage.SeriesRef, error) {
-	a.db.mutex.Lock()
-	defer a.db.mutex.Unlock()
-
-	l = l.WithoutEmpty()
-	if l.IsEmpty() {
-		return 0, fmt.Errorf("empty labelset: %w", tsdb.ErrInvalidSample)
+	ref, l, err := a.resolveRef(ref, l)
+	if err != nil {
+		return 0, err
 	}
 
-	if lbl, dup := l.HasDuplicateLabelNames(); dup {
-		return 0, fmt.Errorf(`label name "%s" is not unique: %w`, lbl, tsdb.ErrInvalidSample)
-	}
-
-	series := a.getOrCreateSeries(l)
-
-	series.Samples = append(series.Samples, newSample(t, v, nil, nil))
-	return 0, nil
+	a.pending = append(a.pending, pendingSample{ref: uint64(ref), labels: l, sample: newSample(t, v, nil, nil)})
+	return ref, nil
 }
 
 func (a *InMemoryAppender) UpdateMetadata(
@@ -136,5 +179,50 @@ func (a *InMemoryAppender) AppendExemplar(
 ) (storage.SeriesRef, error) {
 	return ref, nil
 }
-func (a *InMemoryAppender) Commit() error   { return nil }
-func (a *InMemoryAppender) Rollback() error { return nil }
+
+// Commit 校验并写入缓存的样本：同一序列的时间戳必须严格递增且晚于已存储样本，
+// 新增序列后总数不能超过 MaxSeries。校验失败时不写入任何样本，错误中包含首个非法序列
+func (a *InMemoryAppender) Commit() error {
+	pending := a.pending
+	a.pending = nil
+
+	a.db.mutex.Lock()
+	defer a.db.mutex.Unlock()
+
+	lastTs := make(map[uint64]int64)
+	newSeries := 0
+	for _, p := range pending {
+		key := p.ref
+		last, ok := lastTs[key]
+		if !ok {
+			if series, exists := a.db.series[key]; exists && len(series.Samples) > 0 {
+				last, ok = series.Samples[len(series.Samples)-1].T(), true
+			} else if !exists {
+				newSeries++
+				if a.db.MaxSeries > 0 && len(a.db.series)+newSeries > a.db.MaxSeries {
+					return fmt.Errorf("series %s: %w (limit %d)", p.labels, ErrTooManySeries, a.db.MaxSeries)
+				}
+			}
+		}
+		t := p.sample.T()
+		switch {
+		case ok && t == last:
+			return fmt.Errorf("series %s at %d: %w", p.labels, t, storage.ErrDuplicateSampleForTimestamp)
+		case ok && t < last:
+			return fmt.Errorf("series %s at %d: %w", p.labels, t, storage.ErrOutOfOrderSample)
+		}
+		lastTs[key] = t
+	}
+
+	for _, p := range pending {
+		series := a.getOrCreateSeries(p.ref, p.labels)
+		series.Samples = append(series.Samples, p.sample)
+	}
+	return nil
+}
+
+// Rollback 丢弃缓存的样本
+func (a *InMemoryAppender) Rollback() error {
+	a.pending = nil
+	return nil
+}
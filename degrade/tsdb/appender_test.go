@@ -0,0 +1,173 @@
+package tsdb
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryAppender_CommitAllOrNothing(t *testing.T) {
+	host1 := labels.FromStrings("__name__", "cpu_usage", "instance", "host1")
+	host2 := labels.FromStrings("__name__", "cpu_usage", "instance", "host2")
+
+	db := NewInMemoryDB()
+	app := db.Appender()
+	_, err := app.Append(0, host1, 1000, 1)
+	require.NoError(t, err)
+	require.Empty(t, db.GetSeries(), "samples are not visible before commit")
+	require.NoError(t, app.Commit())
+	require.Len(t, db.GetSeries(), 1)
+
+	cases := []struct {
+		name    string
+		samples []int64 // host2 samples; host1 always gets a valid 2000
+		err     error
+	}{
+		{name: "out of order within batch", samples: []int64{3000, 2500}, err: storage.ErrOutOfOrderSample},
+		{name: "duplicate timestamp", samples: []int64{3000, 3000}, err: storage.ErrDuplicateSampleForTimestamp},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			app := db.Appender()
+			_, err := app.Append(0, host1, 2000, 2)
+			require.NoError(t, err)
+			for _, ts := range c.samples {
+				_, err := app.Append(0, host2, ts, 1)
+				require.NoError(t, err)
+			}
+			err = app.Commit()
+			require.ErrorIs(t, err, c.err)
+			require.Contains(t, err.Error(), `instance="host2"`)
+
+			require.Len(t, db.GetSeries(), 1, "no series should be created on failure")
+			require.Len(t, db.GetSeries()[host1.Hash()].Samples, 1, "valid samples in the batch are not applied")
+		})
+	}
+
+	// 早于已存储样本
+	app = db.Appender()
+	_, err = app.Append(0, host1, 500, 1)
+	require.NoError(t, err)
+	err = app.Commit()
+	require.ErrorIs(t, err, storage.ErrOutOfOrderSample)
+	require.Contains(t, err.Error(), `instance="host1"`)
+}
+
+func TestInMemoryAppender_MaxSeries(t *testing.T) {
+	db := NewInMemoryDB()
+	db.MaxSeries = 2
+
+	app := db.Appender()
+	for _, host := range []string{"host1", "host2", "host3"} {
+		_, err := app.Append(0, labels.FromStrings("__name__", "up", "instance", host), 1000, 1)
+		require.NoError(t, err)
+	}
+	err := app.Commit()
+	require.ErrorIs(t, err, ErrTooManySeries)
+	require.Contains(t, err.Error(), `instance="host3"`)
+	require.Empty(t, db.GetSeries())
+
+	app = db.Appender()
+	_, err = app.Append(0, labels.FromStrings("__name__", "up", "instance", "host1"), 1000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Rollback())
+	require.NoError(t, app.Commit())
+	require.Empty(t, db.GetSeries(), "rolled back samples are discarded")
+}
+
+func TestInMemoryAppender_RequireMetricName(t *testing.T) {
+	nameless := labels.FromStrings("instance", "host1")
+
+	db := NewInMemoryDB()
+	app := db.Appender()
+	_, err := app.Append(0, nameless, 1000, 1)
+	require.NoError(t, err, "lenient by default")
+	require.NoError(t, app.Commit())
+
+	db = NewInMemoryDB()
+	db.RequireMetricName = true
+	app = db.Appender()
+	_, err = app.Append(0, nameless, 1000, 1)
+	require.ErrorIs(t, err, tsdb.ErrInvalidSample)
+	require.Contains(t, err.Error(), "__name__")
+
+	_, err = app.Append(0, labels.FromStrings("__name__", "cpu_usage", "instance", "host1"), 1000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+	require.Len(t, db.GetSeries(), 1)
+}
+
+func TestInMemoryAppender_SeriesRef(t *testing.T) {
+	host1 := labels.FromStrings("__name__", "cpu_usage", "instance", "host1")
+	host2 := labels.FromStrings("__name__", "cpu_usage", "instance", "host2")
+
+	db := NewInMemoryDB()
+	app := db.Appender()
+	ref1, err := app.Append(0, host1, 1000, 1)
+	require.NoError(t, err)
+	require.NotZero(t, ref1)
+	ref2, err := app.Append(0, host2, 1000, 2)
+	require.NoError(t, err)
+	require.NotEqual(t, ref1, ref2)
+
+	// 以引用追加时忽略传入的标签
+	got, err := app.Append(ref1, labels.EmptyLabels(), 2000, 3)
+	require.NoError(t, err)
+	require.Equal(t, ref1, got)
+	require.NoError(t, app.Commit())
+
+	// 新的 appender 也可复用已提交序列的引用
+	app = db.Appender()
+	got, err = app.Append(ref2, labels.EmptyLabels(), 2000, 4)
+	require.NoError(t, err)
+	require.Equal(t, ref2, got)
+	require.NoError(t, app.Commit())
+
+	series := db.GetSeries()
+	require.Len(t, series, 2)
+	require.Equal(t, []float64{1, 3}, sampleValues(series[host1.Hash()]))
+	require.Equal(t, []float64{2, 4}, sampleValues(series[host2.Hash()]))
+
+	// 未知引用回退到按标签追加
+	app = db.Appender()
+	_, err = app.Append(12345, labels.EmptyLabels(), 3000, 5)
+	require.ErrorIs(t, err, tsdb.ErrInvalidSample)
+	got, err = app.Append(12345, host1, 3000, 5)
+	require.NoError(t, err)
+	require.Equal(t, ref1, got)
+}
+
+func sampleValues(s *InMemorySeries) []float64 {
+	values := make([]float64, 0, len(s.Samples))
+	for _, smpl := range s.Samples {
+		values = append(values, smpl.F())
+	}
+	return values
+}
+
+func BenchmarkInMemoryAppender_Append(b *testing.B) {
+	lbs := labels.FromStrings("__name__", "http_requests_total", "instance", "host1", "job", "api", "method", "GET", "status", "200")
+	for _, useRef := range []bool{false, true} {
+		name := "labels"
+		if useRef {
+			name = "ref"
+		}
+		b.Run(name, func(b *testing.B) {
+			app := NewInMemoryDB().Appender()
+			var ref storage.SeriesRef
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r, err := app.Append(ref, lbs, int64(i), 1)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if useRef {
+					ref = r
+				}
+			}
+		})
+	}
+}
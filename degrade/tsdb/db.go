@@ -2,6 +2,7 @@ package tsdb
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/prometheus/model/histogram"
@@ -79,6 +80,18 @@ type InMemorySeries struct {
 type InMemoryDB struct {
 	series map[uint64]*InMemorySeries
 	mutex  sync.RWMutex
+
+	// MaxSeries 序列数上限，<=0 表示不限制
+	MaxSeries int
+	// RequireMetricName 追加缺少 __name__ 的样本时返回错误，用于尽早发现测试数据错误
+	RequireMetricName bool
+
+	// IngestInterval StartIngest 的提交周期，<=0 时为 1s
+	IngestInterval time.Duration
+	// IngestBatchSize StartIngest 单批最多样本数，也是 channel 的缓冲大小，<=0 时为 1000
+	IngestBatchSize int
+
+	ingesting atomic.Bool
 }
 
 func NewInMemoryDB() *InMemoryDB {
@@ -91,6 +104,27 @@ func (db *InMemoryDB) GetSeries() map[uint64]*InMemorySeries {
 	return db.series
 }
 
+// LatestTimestamp 返回指标名为 metric 的所有序列中最新样本的时间戳，指标不存在时返回 false
+func (db *InMemoryDB) LatestTimestamp(metric string) (int64, bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	var (
+		latest int64
+		found  bool
+	)
+	for _, series := range db.series {
+		if len(series.Samples) == 0 || series.Labels.Get(labels.MetricName) != metric {
+			continue
+		}
+		// 提交时保证样本按时间递增，最后一个即最新
+		if ts := series.Samples[len(series.Samples)-1].T(); !found || ts > latest {
+			latest, found = ts, true
+		}
+	}
+	return latest, found
+}
+
 func (db *InMemoryDB) Querier(mint, maxt int64) (storage.Querier, error) {
 	return NewQuerier(mint, maxt, db), nil
 }
@@ -0,0 +1,47 @@
+package tsdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryDB_LatestTimestamp(t *testing.T) {
+	db := NewInMemoryDB()
+	app := db.Appender()
+	now := time.Now()
+
+	// 与示例数据相同的写入方式，每台主机每分钟一个点
+	for i := 0; i < 6; i++ {
+		ts := now.Add(-time.Duration(5-i) * time.Minute).UnixMilli()
+		for _, host := range []string{"host1", "host2", "host3"} {
+			_, err := app.Append(0, labels.FromStrings("__name__", "cpu_usage", "instance", host, "job", "node"), ts, 0.3)
+			require.NoError(t, err)
+		}
+	}
+	// host1 的内存数据两分钟前停止上报，host2 一分钟前停止上报
+	for i := 0; i < 4; i++ {
+		ts := now.Add(-time.Duration(5-i) * time.Minute).UnixMilli()
+		_, err := app.Append(0, labels.FromStrings("__name__", "memory_usage", "instance", "host1", "job", "node"), ts, 0.6)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 6; i++ {
+		ts := now.Add(-time.Duration(6-i) * time.Minute).UnixMilli()
+		_, err := app.Append(0, labels.FromStrings("__name__", "memory_usage", "instance", "host2", "job", "node"), ts, 0.7)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	ts, ok := db.LatestTimestamp("cpu_usage")
+	require.True(t, ok)
+	require.Equal(t, now.UnixMilli(), ts)
+
+	ts, ok = db.LatestTimestamp("memory_usage")
+	require.True(t, ok)
+	require.Equal(t, now.Add(-time.Minute).UnixMilli(), ts)
+
+	_, ok = db.LatestTimestamp("disk_used")
+	require.False(t, ok)
+}
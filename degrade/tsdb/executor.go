@@ -76,6 +76,13 @@ func (e *PromQLExecutor) ExecuteInstantQuery(ctx context.Context, query string,
 	}
 }
 
+// AsQueryFunc 返回可直接作为 alertmanager.QueryFunc 使用的即时查询函数
+func (e *PromQLExecutor) AsQueryFunc() func(ctx context.Context, query string, ts time.Time) (promql.Vector, error) {
+	return func(ctx context.Context, query string, ts time.Time) (promql.Vector, error) {
+		return e.ExecuteInstantQuery(ctx, query, ts)
+	}
+}
+
 // ExecuteRangeQuery 执行范围查询
 func (e *PromQLExecutor) ExecuteRangeQuery(
 	ctx context.Context,
@@ -105,6 +112,42 @@ func (e *PromQLExecutor) ExecuteRangeQuery(
 	}
 }
 
+// StreamRangeQuery 执行范围查询并逐条序列回调 fn，回调返回错误时停止并返回该错误。
+// 引擎内部仍会物化完整结果，但每条序列回调后即释放引用，导出时调用方无需同时持有整个矩阵。
+// series 仅在回调期间有效，查询关闭后其点切片会被引擎复用
+func (e *PromQLExecutor) StreamRangeQuery(
+	ctx context.Context,
+	query string,
+	start, end time.Time,
+	step time.Duration,
+	fn func(series promql.Series) error,
+) error {
+	qry, err := e.engine.NewRangeQuery(ctx, e.queryable, nil, query, start, end, step)
+	if err != nil {
+		return fmt.Errorf("query parse error: %w", err)
+	}
+	defer qry.Close()
+
+	res := qry.Exec(ctx)
+	if res.Err != nil {
+		return fmt.Errorf("query execution error: %w", res.Err)
+	}
+	matrix, ok := res.Value.(promql.Matrix)
+	if !ok {
+		return fmt.Errorf("unsupported result type for range query: %T", res.Value)
+	}
+	for i := range matrix {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(matrix[i]); err != nil {
+			return err
+		}
+		matrix[i] = promql.Series{}
+	}
+	return nil
+}
+
 // QueryResultFormatter 格式化查询结果
 type QueryResultFormatter struct{}
 
@@ -0,0 +1,104 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ongniud/other/degrade/alertmanager"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+type chanNotifier struct {
+	once sync.Once
+	ch   chan []*alertmanager.Notification
+}
+
+func (n *chanNotifier) Notify(_ context.Context, notifications []*alertmanager.Notification) error {
+	n.once.Do(func() { n.ch <- notifications })
+	return nil
+}
+
+func TestPromQLExecutor_AsQueryFunc(t *testing.T) {
+	db := NewInMemoryDB()
+	app := db.Appender()
+	now := time.Now()
+	_, err := app.Append(0, labels.FromStrings("__name__", "cpu_usage", "instance", "host1"), now.Add(-time.Minute).UnixMilli(), 0.9)
+	require.NoError(t, err)
+	_, err = app.Append(0, labels.FromStrings("__name__", "cpu_usage", "instance", "host2"), now.Add(-time.Minute).UnixMilli(), 0.2)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	rule, err := alertmanager.NewRule("HighCPU", "cpu_usage > 0.8", 0, 0, 0, labels.EmptyLabels(), labels.EmptyLabels())
+	require.NoError(t, err)
+	rule.AlertType = alertmanager.AlertTypeBasic
+
+	notifier := &chanNotifier{ch: make(chan []*alertmanager.Notification, 1)}
+	executor := NewPromQLExecutor(db)
+	am := alertmanager.NewAlertManager(
+		[]*alertmanager.Rule{rule},
+		20*time.Millisecond,
+		executor.AsQueryFunc(),
+		notifier,
+		alertmanager.NewMemoryStorage(),
+	)
+	require.NoError(t, am.Run())
+	defer am.Stop()
+
+	select {
+	case notifications := <-notifier.ch:
+		require.Len(t, notifications, 1)
+		require.Equal(t, "HighCPU", notifications[0].Rule)
+		require.Equal(t, "firing", notifications[0].Status)
+		require.Equal(t, "host1", notifications[0].Labels["instance"])
+		require.Equal(t, 0.9, notifications[0].Value)
+	case <-time.After(5 * time.Second):
+		t.Fatal("rule did not fire against stored data")
+	}
+}
+
+func TestPromQLExecutor_StreamRangeQuery(t *testing.T) {
+	db := NewInMemoryDB()
+	app := db.Appender()
+	start := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute).UnixMilli()
+		for j, host := range []string{"host1", "host2", "host3"} {
+			_, err := app.Append(0, labels.FromStrings("__name__", "qps", "instance", host), ts, float64(i*10+j))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, app.Commit())
+
+	executor := NewPromQLExecutor(db)
+	end := start.Add(4 * time.Minute)
+	got := make(map[string][]float64)
+	err := executor.StreamRangeQuery(context.Background(), "qps", start, end, time.Minute, func(series promql.Series) error {
+		instance := series.Metric.Get("instance")
+		require.NotContains(t, got, instance, "each series is streamed once")
+		for _, p := range series.Floats {
+			got[instance] = append(got[instance], p.F)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]float64{
+		"host1": {0, 10, 20, 30, 40},
+		"host2": {1, 11, 21, 31, 41},
+		"host3": {2, 12, 22, 32, 42},
+	}, got)
+
+	// 回调出错时停止
+	stop := errors.New("stop")
+	calls := 0
+	err = executor.StreamRangeQuery(context.Background(), "qps", start, end, time.Minute, func(promql.Series) error {
+		calls++
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 1, calls)
+}
@@ -0,0 +1,124 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+const (
+	defaultIngestInterval  = time.Second
+	defaultIngestBatchSize = 1000
+)
+
+// ErrIngestRunning 同一 InMemoryDB 上已有进行中的 StartIngest
+var ErrIngestRunning = errors.New("ingest already running")
+
+// Sample 通过 StartIngest 推送的浮点样本，T 为毫秒时间戳
+type Sample struct {
+	Labels labels.Labels
+	T      int64
+	V      float64
+}
+
+// LabelsFromMap 由 map 构造按名称排序的标签集，用于构造 Sample 和测试数据
+func LabelsFromMap(m map[string]string) labels.Labels {
+	return labels.FromMap(m)
+}
+
+// StartIngest 启动后台写入协程，从返回的 channel 接收样本，
+// 每 IngestInterval 或攒满 IngestBatchSize 个样本时提交一次。
+// channel 缓冲大小为 IngestBatchSize，写入跟不上时发送方阻塞。
+// ctx 结束或调用方关闭 channel 时提交已接收的样本后退出，ctx 结束后不应再发送
+func (db *InMemoryDB) StartIngest(ctx context.Context) (chan<- Sample, error) {
+	if !db.ingesting.CompareAndSwap(false, true) {
+		return nil, ErrIngestRunning
+	}
+	interval := db.IngestInterval
+	if interval <= 0 {
+		interval = defaultIngestInterval
+	}
+	batchSize := db.IngestBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultIngestBatchSize
+	}
+
+	ch := make(chan Sample, batchSize)
+	go func() {
+		defer db.ingesting.Store(false)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		batch := make([]Sample, 0, batchSize)
+		flush := func() {
+			db.commitBatch(batch)
+			batch = batch[:0]
+		}
+		for {
+			select {
+			case s, ok := <-ch:
+				if !ok {
+					flush()
+					return
+				}
+				batch = append(batch, s)
+				if len(batch) >= batchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			case <-ctx.Done():
+				// 提交已进入缓冲的样本
+			drain:
+				for {
+					select {
+					case s, ok := <-ch:
+						if !ok {
+							break drain
+						}
+						batch = append(batch, s)
+					default:
+						break drain
+					}
+				}
+				flush()
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// commitBatch 整批提交失败时逐个样本重试，只丢弃非法的样本
+func (db *InMemoryDB) commitBatch(batch []Sample) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := db.appendSamples(batch); err == nil {
+		return
+	}
+	dropped := 0
+	for i := range batch {
+		if err := db.appendSamples(batch[i : i+1]); err != nil {
+			dropped++
+			log.Printf("Dropping ingested sample: %v", err)
+		}
+	}
+	if dropped > 0 {
+		log.Printf("Ingest dropped %d of %d samples", dropped, len(batch))
+	}
+}
+
+func (db *InMemoryDB) appendSamples(samples []Sample) error {
+	app := db.Appender()
+	for _, s := range samples {
+		if _, err := app.Append(0, s.Labels, s.T, s.V); err != nil {
+			_ = app.Rollback()
+			return err
+		}
+	}
+	return app.Commit()
+}
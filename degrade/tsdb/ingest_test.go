@@ -0,0 +1,72 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryDB_StartIngest(t *testing.T) {
+	db := NewInMemoryDB()
+	db.IngestInterval = 50 * time.Millisecond
+	db.IngestBatchSize = 100
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := db.StartIngest(ctx)
+	require.NoError(t, err)
+	_, err = db.StartIngest(ctx)
+	require.ErrorIs(t, err, ErrIngestRunning)
+
+	lbs := labels.FromStrings("__name__", "qps", "instance", "host1")
+	for i := 1; i <= 10; i++ {
+		ch <- Sample{Labels: lbs, T: int64(i) * 1000, V: float64(i)}
+	}
+	// 不足一批，按周期提交后可查询
+	require.Eventually(t, func() bool {
+		ts, ok := db.LatestTimestamp("qps")
+		return ok && ts == 10000
+	}, time.Second, 10*time.Millisecond)
+
+	// 乱序样本只丢弃自身，不影响同批其他样本
+	ch <- Sample{Labels: lbs, T: 5000, V: 0}
+	ch <- Sample{Labels: lbs, T: 11000, V: 11}
+	require.Eventually(t, func() bool {
+		ts, _ := db.LatestTimestamp("qps")
+		return ts == 11000
+	}, time.Second, 10*time.Millisecond)
+
+	// 取消时提交剩余样本并允许重新启动
+	ch <- Sample{Labels: lbs, T: 12000, V: 12}
+	cancel()
+	require.Eventually(t, func() bool {
+		ts, _ := db.LatestTimestamp("qps")
+		return ts == 12000 && !db.ingesting.Load()
+	}, time.Second, 10*time.Millisecond)
+
+	db.mutex.RLock()
+	require.Len(t, db.series[lbs.Hash()].Samples, 12)
+	db.mutex.RUnlock()
+
+	ch, err = db.StartIngest(context.Background())
+	require.NoError(t, err)
+	close(ch)
+}
+
+func TestLabelsFromMap(t *testing.T) {
+	want := labels.FromStrings("__name__", "http_requests_total", "instance", "host1", "job", "api", "method", "GET")
+	for i := 0; i < 10; i++ {
+		// map 遍历顺序随机，多次构造结果应一致
+		got := LabelsFromMap(map[string]string{
+			"method":   "GET",
+			"job":      "api",
+			"__name__": "http_requests_total",
+			"instance": "host1",
+		})
+		require.True(t, labels.Equal(want, got), "got %s", got)
+	}
+	require.True(t, LabelsFromMap(nil).IsEmpty())
+}
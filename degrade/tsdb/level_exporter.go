@@ -0,0 +1,77 @@
+package tsdb
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/ongniud/other/degrade/detector"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+const (
+	// DegradeLevelMetric 分级器当前级别
+	DegradeLevelMetric = "degrade_level"
+	// DegradeAdmissionsMetric 各级别累计分类请求数，tier 标签为级别
+	DegradeAdmissionsMetric = "degrade_admissions_total"
+)
+
+// LevelExporter 定期将分级器的级别和各级别计数写入 InMemoryDB，使降级规则可以通过 PromQL 查询
+type LevelExporter struct {
+	db       *InMemoryDB
+	source   detector.LevelSource
+	interval time.Duration
+	labels   labels.Labels // 附加到导出序列上的标签，用于区分多个分级器
+}
+
+func NewLevelExporter(db *InMemoryDB, source detector.LevelSource, interval time.Duration, lbs labels.Labels) *LevelExporter {
+	return &LevelExporter{
+		db:       db,
+		source:   source,
+		interval: interval,
+		labels:   lbs,
+	}
+}
+
+// Run 按 interval 周期导出，直到 ctx 结束
+func (e *LevelExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case ts := <-ticker.C:
+			if err := e.Export(ts); err != nil {
+				log.Printf("Failed to export degrade level: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Export 以 ts 为时间戳写入一次当前状态
+func (e *LevelExporter) Export(ts time.Time) error {
+	app := e.db.Appender()
+	t := ts.UnixMilli()
+	if _, err := app.Append(0, e.series(DegradeLevelMetric), t, float64(e.source.Level())); err != nil {
+		_ = app.Rollback()
+		return err
+	}
+	for tier, n := range e.source.Admissions() {
+		if _, err := app.Append(0, e.series(DegradeAdmissionsMetric, "tier", strconv.Itoa(tier)), t, float64(n)); err != nil {
+			_ = app.Rollback()
+			return err
+		}
+	}
+	return app.Commit()
+}
+
+func (e *LevelExporter) series(name string, extra ...string) labels.Labels {
+	builder := labels.NewBuilder(e.labels)
+	builder.Set(labels.MetricName, name)
+	for i := 0; i+1 < len(extra); i += 2 {
+		builder.Set(extra[i], extra[i+1])
+	}
+	return builder.Labels()
+}
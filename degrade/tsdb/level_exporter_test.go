@@ -0,0 +1,49 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ongniud/other/degrade/detector"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelExporter(t *testing.T) {
+	classifier := detector.NewQpsTierClassifier([]int{2, 4})
+	for i := 0; i < 5; i++ {
+		classifier.Classify()
+	}
+	// 突发 5 个请求：前 2 个进入 0 级，接下来 2 个进入 1 级，最后 1 个超限
+	require.Equal(t, 2, classifier.Level())
+	require.Equal(t, []uint64{2, 2, 1}, classifier.Admissions())
+
+	db := NewInMemoryDB()
+	exporter := NewLevelExporter(db, classifier, 10*time.Millisecond, labels.FromStrings("service", "api"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		exporter.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		_, ok := db.LatestTimestamp(DegradeLevelMetric)
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	ts, _ := db.LatestTimestamp(DegradeLevelMetric)
+	executor := NewPromQLExecutor(db)
+	vector, err := executor.ExecuteInstantQuery(context.Background(), `degrade_level{service="api"}`, time.UnixMilli(ts))
+	require.NoError(t, err)
+	require.Len(t, vector, 1)
+	require.Equal(t, float64(2), vector[0].F)
+
+	vector, err = executor.ExecuteInstantQuery(context.Background(), `degrade_admissions_total{tier="1"}`, time.UnixMilli(ts))
+	require.NoError(t, err)
+	require.Len(t, vector, 1)
+	require.Equal(t, float64(2), vector[0].F)
+}
@@ -0,0 +1,77 @@
+package tsdb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ongniud/other/degrade/alertmanager"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// AlertNotificationsMetric 各规则累计通知数，按 rule 和 status 标签区分
+const AlertNotificationsMetric = "alert_notifications_total"
+
+// TSDBNotifier 将每条通知计入 InMemoryDB 中的计数器，便于用 PromQL 查询告警量，
+// 如 increase(alert_notifications_total{status="firing"}[1h])。
+// 不实际发送通知，需要同时发送时与其他 Notifier 组合使用
+type TSDBNotifier struct {
+	db *InMemoryDB
+
+	mtx    sync.Mutex
+	counts map[string]float64 // 按 rule、status 累计的通知数
+	lastT  int64              // 上次写入的毫秒时间戳
+	now    func() time.Time
+}
+
+func NewTSDBNotifier(db *InMemoryDB) *TSDBNotifier {
+	return &TSDBNotifier{
+		db:     db,
+		counts: make(map[string]float64),
+		now:    time.Now,
+	}
+}
+
+// Notify 为本批通知涉及的每个 rule、status 写入一个累计值样本，写入失败时计数不变
+func (n *TSDBNotifier) Notify(_ context.Context, notifications []*alertmanager.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	// 持锁完成写入，保证同一序列的样本时间戳与计数单调递增
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	var (
+		order  []string
+		series = make(map[string]labels.Labels)
+		counts = make(map[string]float64)
+	)
+	for _, notification := range notifications {
+		lbs := labels.FromStrings(labels.MetricName, AlertNotificationsMetric, "rule", notification.Rule, "status", notification.Status)
+		key := lbs.String()
+		if _, ok := series[key]; !ok {
+			order = append(order, key)
+			series[key] = lbs
+			counts[key] = n.counts[key]
+		}
+		counts[key]++
+	}
+
+	app := n.db.Appender()
+	// 同一毫秒内多次通知时顺延 1ms，避免同一序列出现重复时间戳
+	t := max(n.now().UnixMilli(), n.lastT+1)
+	for _, key := range order {
+		if _, err := app.Append(0, series[key], t, counts[key]); err != nil {
+			_ = app.Rollback()
+			return err
+		}
+	}
+	if err := app.Commit(); err != nil {
+		return err
+	}
+	for key, v := range counts {
+		n.counts[key] = v
+	}
+	n.lastT = t
+	return nil
+}
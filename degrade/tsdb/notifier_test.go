@@ -0,0 +1,65 @@
+package tsdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ongniud/other/degrade/alertmanager"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSDBNotifier(t *testing.T) {
+	db := NewInMemoryDB()
+	notifier := NewTSDBNotifier(db)
+	ctx := context.Background()
+
+	// 与业务写入并发进行
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		base := time.Now().Add(-time.Hour)
+		for i := 0; i < 100; i++ {
+			app := db.Appender()
+			if _, err := app.Append(0, labels.FromStrings("__name__", "cpu_usage", "instance", "host1"), base.Add(time.Duration(i)*time.Second).UnixMilli(), 0.5); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := app.Commit(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, notifier.Notify(ctx, []*alertmanager.Notification{
+			{Rule: "HighCPU", Status: "firing"},
+			{Rule: "HighMem", Status: "firing"},
+			{Rule: "HighCPU", Status: "firing"},
+		}))
+	}
+	require.NoError(t, notifier.Notify(ctx, []*alertmanager.Notification{{Rule: "HighCPU", Status: "inactive"}}))
+	require.NoError(t, notifier.Notify(ctx, nil))
+	wg.Wait()
+
+	executor := NewPromQLExecutor(db)
+	vector, err := executor.ExecuteInstantQuery(ctx, AlertNotificationsMetric, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	got := make(map[string]float64)
+	for _, sample := range vector {
+		got[sample.Metric.Get("rule")+"/"+sample.Metric.Get("status")] = sample.F
+	}
+	require.Equal(t, map[string]float64{
+		"HighCPU/firing":   20,
+		"HighMem/firing":   10,
+		"HighCPU/inactive": 1,
+	}, got)
+
+	vector, err = executor.ExecuteInstantQuery(ctx, `sum by (status) (alert_notifications_total)`, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	require.Len(t, vector, 2)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunks"
 	"github.com/prometheus/prometheus/util/annotations"
 )
 
@@ -75,8 +76,15 @@ func (q *InMemoryQuerier) Select(
 		if !matchLabels(s.Labels, matchers) {
 			continue
 		}
-		if len(s.Samples) > 0 {
-			result = append(result, storage.NewListSeries(s.Labels, s.Samples))
+		// 只返回查询时间范围内的样本
+		var samples []chunks.Sample
+		for _, sample := range s.Samples {
+			if sample.T() >= q.mint && sample.T() <= q.maxt {
+				samples = append(samples, sample)
+			}
+		}
+		if len(samples) > 0 {
+			result = append(result, storage.NewListSeries(s.Labels, samples))
 		}
 	}
 	return &inMemorySeriesSet{series: result}
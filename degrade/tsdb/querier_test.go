@@ -0,0 +1,62 @@
+package tsdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/require"
+)
+
+func selectTimestamps(t *testing.T, db *InMemoryDB, mint, maxt int64) map[string][]int64 {
+	q, err := db.Querier(mint, maxt)
+	require.NoError(t, err)
+	defer q.Close()
+
+	out := make(map[string][]int64)
+	set := q.Select(context.Background(), false, nil, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "cpu_usage"))
+	for set.Next() {
+		series := set.At()
+		it := series.Iterator(nil)
+		for it.Next() == chunkenc.ValFloat {
+			ts, _ := it.At()
+			out[series.Labels().Get("instance")] = append(out[series.Labels().Get("instance")], ts)
+		}
+		require.NoError(t, it.Err())
+	}
+	require.NoError(t, set.Err())
+	return out
+}
+
+func TestInMemoryQuerier_TimeBounds(t *testing.T) {
+	db := NewInMemoryDB()
+	app := db.Appender()
+	for ts := int64(1000); ts <= 5000; ts += 1000 {
+		_, err := app.Append(0, labels.FromStrings("__name__", "cpu_usage", "instance", "host1"), ts, float64(ts))
+		require.NoError(t, err)
+	}
+	_, err := app.Append(0, labels.FromStrings("__name__", "cpu_usage", "instance", "host2"), 5000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	// 查询范围覆盖全部样本
+	require.Equal(t, map[string][]int64{
+		"host1": {1000, 2000, 3000, 4000, 5000},
+		"host2": {5000},
+	}, selectTimestamps(t, db, 0, 10000))
+
+	// 部分重叠，只返回范围内样本
+	require.Equal(t, map[string][]int64{
+		"host1": {2000, 3000, 4000},
+	}, selectTimestamps(t, db, 1500, 4500))
+
+	// 边界包含
+	require.Equal(t, map[string][]int64{
+		"host1": {4000, 5000},
+		"host2": {5000},
+	}, selectTimestamps(t, db, 4000, 5000))
+
+	// 无重叠
+	require.Empty(t, selectTimestamps(t, db, 6000, 9000))
+}
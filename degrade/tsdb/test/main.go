@@ -51,7 +51,9 @@ func main() {
 		appender.Append(0, labels.FromStrings("__name__", "disk_total", "instance", "host1", "job", "node", "device", "sda1"), t.UnixMilli(), 100.0)
 	}
 
-	appender.Commit()
+	if err := appender.Commit(); err != nil {
+		panic(err)
+	}
 
 	formatter := tsdb.QueryResultFormatter{}
 
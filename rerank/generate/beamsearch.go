@@ -3,8 +3,11 @@ package generate
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"math"
+	"math/rand"
 	"sort"
+	"time"
 
 	"github.com/ongniud/other/rerank/generate/common"
 )
@@ -13,6 +16,16 @@ type Unit struct {
 	ID    string
 	Tag   string
 	Score float64
+	// Confidence 相关性分数的置信度，质量分按 Score*Confidence 计算；0 表示未设置，按 1 处理
+	Confidence float64
+}
+
+// quality 按置信度折算后的质量分
+func (u *Unit) quality() float64 {
+	if u.Confidence == 0 {
+		return u.Score
+	}
+	return u.Score * u.Confidence
 }
 
 type TagData struct {
@@ -26,7 +39,16 @@ type Candidate struct {
 	Counts map[string]int
 	IDs    map[string]struct{}
 
-	Win *common.CounterWindow
+	Win   *common.CounterWindow
+	IDWin *common.CounterWindow
+
+	// Origin 设置 TrackProvenance 时记录产生该候选的扩展，沿 Parent 可回溯整个构造过程
+	Origin *Origin
+	// EarlyStopped 搜索超出 TimeBudget 提前结束，序列可能短于 seqLength
+	EarlyStopped bool
+
+	acc      scoreAcc // 增量打分的累计量
+	tiebreak uint64   // 设置 Seed 时相同分数候选的排序键
 }
 
 func (c *Candidate) Clone() *Candidate {
@@ -47,13 +69,77 @@ func (c *Candidate) Clone() *Candidate {
 	}
 
 	return &Candidate{
-		Units:  units,
-		Refs:   refs,
-		Counts: counts,
-		Score:  c.Score,
-		IDs:    ids,
-		Win:    c.Win.Clone(),
+		Units:        units,
+		Refs:         refs,
+		Counts:       counts,
+		Score:        c.Score,
+		IDs:          ids,
+		Win:          c.Win.Clone(),
+		IDWin:        c.IDWin.Clone(),
+		Origin:       c.Origin,
+		EarlyStopped: c.EarlyStopped,
+		acc:          c.acc,
+	}
+}
+
+// Origin 一次扩展的记录。只引用上一步的 Origin 而不引用父候选，
+// 同一前缀的候选共享祖先记录，不会让已淘汰候选的窗口和计数随结果一起存活
+type Origin struct {
+	Parent *Origin // 父候选的扩展记录，由空序列扩展而来时为 nil
+	Step   int     // 从 0 开始的扩展步数
+	Tag    string
+	UnitID string
+	Score  float64 // 扩展后候选的分数
+}
+
+// Provenance 按扩展顺序返回候选的构造过程，未开启 TrackProvenance 时返回 nil
+func (c *Candidate) Provenance() []*Origin {
+	var chain []*Origin
+	for o := c.Origin; o != nil; o = o.Parent {
+		chain = append(chain, o)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// Len 候选序列实际长度
+func (c *Candidate) Len() int {
+	return len(c.Units)
+}
+
+// TagHistogram 统计序列中各 tag 的单元个数
+func (c *Candidate) TagHistogram() map[string]int {
+	hist := make(map[string]int)
+	for _, u := range c.Units {
+		hist[u.Tag]++
+	}
+	return hist
+}
+
+// RankedItem 候选序列中一个位置的展开结果，用于序列化为服务响应
+type RankedItem struct {
+	Position       int     `json:"position"` // 从 0 开始
+	UnitID         string  `json:"unitId"`
+	Tag            string  `json:"tag"`
+	Score          float64 `json:"score"`          // unit 的相关性分数
+	CandidateScore float64 `json:"candidateScore"` // 所在候选的总分
+}
+
+// Flatten 按序列顺序展开候选
+func (c *Candidate) Flatten() []RankedItem {
+	items := make([]RankedItem, len(c.Units))
+	for i, u := range c.Units {
+		items[i] = RankedItem{
+			Position:       i,
+			UnitID:         u.ID,
+			Tag:            u.Tag,
+			Score:          u.Score,
+			CandidateScore: c.Score,
+		}
 	}
+	return items
 }
 
 type Window struct {
@@ -61,6 +147,56 @@ type Window struct {
 	Limit int
 }
 
+// Options 打分相关的可选配置
+type Options struct {
+	// TargetDistribution 目标 tag 分布（如 tag1:0.6, tag2:0.4），无需归一化
+	TargetDistribution map[string]float64
+	// TargetWeight 偏离目标分布的惩罚权重，<=0 时取 1
+	TargetWeight float64
+	// DisableContinuityPenalty 关闭同 tag 连续出现的惩罚
+	DisableContinuityPenalty bool
+	// TailPenaltyWeight 序列尾部同 tag 连续出现的额外惩罚权重，按位置线性加权
+	TailPenaltyWeight float64
+	// PositionDiscount 质量分按位置折损（DCG 风格，权重 1/log2(pos+2)），越靠前贡献越大，仅用于 QualityMean
+	PositionDiscount bool
+	// QualityAggregation 各位置质量分的聚合方式，为空时按 QualityMean
+	QualityAggregation QualityAggregation
+	// RareTagBonus 稀缺 tag 奖励强度，unit 所属 tag 在其之前出现越少奖励越大
+	RareTagBonus float64
+	// IDWindow unit ID 的滑动窗口约束，设置后同一 ID 允许重复出现，但窗口内出现次数不超过 Limit
+	IDWindow *Window
+	// MinSeqLength 最小序列长度，>0 时丢弃短于该长度的候选；TimeBudget 提前结束时不生效
+	MinSeqLength int
+	// Shared 跨多次 Generate 共享的 tag 预算，结束后扣除最优候选的使用量
+	Shared *SharedConstraint
+	// Tracer 诊断回调，记录每一步生成、裁剪的候选以及被跳过的 tag
+	Tracer Tracer
+	// DiversityNonEmptyTags 多样性熵按有 unit 的 tag 数归一化，而非传入的全部 tag 数
+	DiversityNonEmptyTags bool
+	// DiversityBase 多样性熵归一化使用的 tag 数，>0 时优先于 DiversityNonEmptyTags
+	DiversityBase int
+	// ForbiddenAdjacent 不允许相邻的 tag 对（不区分顺序），如 {"ad", "ad"} 禁止两个广告相邻
+	ForbiddenAdjacent [][2]string
+	// MinTagGap 同一 tag 相邻两次出现的最小位置间隔，如 3 表示位置 i 之后最早在 i+3 再次出现
+	MinTagGap map[string]int
+	// DedupCandidates 每步按 unit ID 多重集合与末尾 tag 去重，等价的候选只保留分数最高的一个，
+	// 避免 beam 被仅顺序不同的候选占满
+	DedupCandidates bool
+	// Seed 非 0 时用该种子的伪随机数打破相同分数候选的排序，默认按 unit ID 字典序；
+	// 相同种子的结果可复现，不同种子可使分数相同的结果互有差异
+	Seed int64
+	// PostProcess 对每个返回的候选做业务调整（如将指定 unit 置顶），在搜索与排序完成后调用，
+	// 不影响打分与剪枝；返回 nil 时保留原候选
+	PostProcess func(*Candidate) *Candidate
+	// RescorePostProcessed 按调整后的序列重新计算 Score，默认保留搜索时的分数；结果顺序不变
+	RescorePostProcessed bool
+	// TrackProvenance 为每个候选记录 Origin，用于回溯和渲染搜索树
+	TrackProvenance bool
+	// TimeBudget 搜索耗时软上限，>0 时每步开始前检查，超出后不再扩展，
+	// 返回已有的最优候选并标记 EarlyStopped；至少完成第一步扩展
+	TimeBudget time.Duration
+}
+
 type BeamSearcher struct {
 	seqCount  int
 	seqLength int
@@ -68,7 +204,10 @@ type BeamSearcher struct {
 
 	maxPerTag map[string]int // 每个 tag 最大使用次数
 
-	win *Window
+	win  *Window
+	opts *Options
+
+	batchScore bool // 每次扩展都完整重算分数，用于校验增量打分
 }
 
 func (s *BeamSearcher) Generate(ctx context.Context, tags map[string]*TagData) ([]*Candidate, error) {
@@ -87,121 +226,482 @@ func (s *BeamSearcher) Generate(ctx context.Context, tags map[string]*TagData) (
 		}
 		initial.Win = win
 	}
+	if s.opts != nil && s.opts.IDWindow != nil {
+		win, err := common.NewCounterWindow(s.opts.IDWindow.Size, s.opts.IDWindow.Limit)
+		if err != nil {
+			return nil, err
+		}
+		initial.IDWin = win
+	}
+
+	st := &genState{
+		tags:   tags,
+		keys:   sortedTagKeys(tags),
+		limits: s.tagLimits(),
+	}
+	if s.opts != nil && s.opts.Seed != 0 {
+		st.rng = rand.New(rand.NewSource(s.opts.Seed))
+	}
+
+	start := time.Now()
+	earlyStopped := false
 
 	candidates := []*Candidate{initial}
 	for i := 0; i < s.seqLength; i++ {
+		if i > 0 && s.overBudget(start) {
+			earlyStopped = true
+			break
+		}
 		var beams []*Candidate
+		extended := false
 		for _, can := range candidates {
-			newCans := s.genCans(can, tags)
+			newCans := s.genCans(i, can, st)
 			if newCans != nil {
 				beams = append(beams, newCans...)
+				extended = true
 				continue
 			}
-			return nil, fmt.Errorf("%s", "no candidates")
+			// 无法继续扩展的候选原样保留，以部分序列参与排序
+			beams = append(beams, can)
+		}
+		if !extended {
+			// 所有候选都无法继续扩展，提前结束
+			break
+		}
+		if s.opts != nil && s.opts.DedupCandidates {
+			beams = s.dedup(i, beams, st.rng != nil)
 		}
 
-		if len(beams) > s.beamWidth {
-			sort.Slice(beams, func(i, j int) bool {
-				return beams[i].Score > beams[j].Score
-			})
-			candidates = beams[:s.beamWidth]
+		// 最后一步保留全部扩展结果，最终按 seqCount 截断，使结果数不受 beamWidth 限制
+		width := s.beamWidth
+		if i == s.seqLength-1 && s.seqCount > width {
+			width = s.seqCount
+		}
+		if len(beams) > width {
+			sortCandidates(beams, st.rng != nil)
+			if s.tracing() {
+				for _, can := range beams[width:] {
+					s.trace(TraceEvent{Step: i, Kind: TracePruned, Candidate: can})
+				}
+			}
+			candidates = beams[:width]
 		} else {
 			candidates = beams
 		}
 	}
 
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].Score > candidates[j].Score
-	})
+	if candidates[0].Len() == 0 {
+		if allTagsEmpty(tags) {
+			return nil, ErrAllTagsEmpty
+		}
+		return nil, st.stuck.err()
+	}
+
+	// 超出 TimeBudget 时返回已有的部分序列，不按最小长度过滤
+	if minLen := s.minSeqLength(); minLen > 0 && !earlyStopped {
+		filtered := candidates[:0]
+		for _, can := range candidates {
+			if can.Len() >= minLen {
+				filtered = append(filtered, can)
+			}
+		}
+		if len(filtered) == 0 {
+			return nil, fmt.Errorf("no candidates reach min length %d: %w", minLen, st.stuck.err())
+		}
+		candidates = filtered
+	}
+
+	sortCandidates(candidates, st.rng != nil)
 
 	if len(candidates) > s.seqCount {
 		candidates = candidates[:s.seqCount]
 	}
 
+	if earlyStopped {
+		for _, can := range candidates {
+			can.EarlyStopped = true
+		}
+	}
+
+	if s.opts != nil && s.opts.PostProcess != nil {
+		for i, can := range candidates {
+			processed := s.opts.PostProcess(can)
+			if processed == nil {
+				continue
+			}
+			if s.opts.RescorePostProcessed {
+				processed.Score = s.calcScore(tags, processed)
+			}
+			candidates[i] = processed
+		}
+	}
+
+	if s.opts != nil && s.opts.Shared != nil && len(candidates) > 0 {
+		s.opts.Shared.Commit(candidates[0])
+	}
+
 	return candidates, nil
 }
 
-func (s *BeamSearcher) genCans(can *Candidate, tags map[string]*TagData) []*Candidate {
-	var beams []*Candidate
-	for tagKey, tagData := range tags {
+// tagLimits 合并 maxPerTag 与共享预算，得到本次搜索各 tag 的使用上限
+func (s *BeamSearcher) tagLimits() map[string]int {
+	limits := make(map[string]int, len(s.maxPerTag))
+	for tag, n := range s.maxPerTag {
+		if n > 0 {
+			limits[tag] = n
+		}
+	}
+	if s.opts != nil && s.opts.Shared != nil {
+		for tag, left := range s.opts.Shared.limits() {
+			if n, ok := limits[tag]; !ok || left < n {
+				limits[tag] = left
+			}
+		}
+	}
+	return limits
+}
+
+// overBudget 自 start 起的耗时是否超出 TimeBudget
+func (s *BeamSearcher) overBudget(start time.Time) bool {
+	return s.opts != nil && s.opts.TimeBudget > 0 && time.Since(start) > s.opts.TimeBudget
+}
+
+func (s *BeamSearcher) minSeqLength() int {
+	if s.opts == nil {
+		return 0
+	}
+	return s.opts.MinSeqLength
+}
+
+// dedup 按分数排序后去掉与更高分候选等价的候选
+func (s *BeamSearcher) dedup(step int, beams []*Candidate, seeded bool) []*Candidate {
+	sortCandidates(beams, seeded)
+	seen := make(map[uint64]struct{}, len(beams))
+	kept := beams[:0]
+	for _, can := range beams {
+		key := dedupKey(can)
+		if _, dup := seen[key]; dup {
+			s.trace(TraceEvent{Step: step, Kind: TracePruned, Candidate: can})
+			continue
+		}
+		seen[key] = struct{}{}
+		kept = append(kept, can)
+	}
+	return kept
+}
+
+// dedupKey 候选的规范哈希：排序后的 unit ID 加末尾 tag，末尾 tag 影响后续的连续惩罚和相邻约束
+func dedupKey(can *Candidate) uint64 {
+	ids := make([]string, len(can.Units))
+	for i, u := range can.Units {
+		ids[i] = u.ID
+	}
+	sort.Strings(ids)
+
+	h := fnv.New64a()
+	for _, id := range ids {
+		h.Write([]byte(id))
+		h.Write([]byte{0xff})
+	}
+	if n := len(can.Units); n > 0 {
+		h.Write([]byte(can.Units[n-1].Tag))
+	}
+	return h.Sum64()
+}
+
+// genState 单次 Generate 内各步扩展共享的状态
+type genState struct {
+	tags   map[string]*TagData
+	keys   []string // 按字典序排列的 tag，使扩展顺序与 map 遍历顺序无关
+	limits map[string]int
+	rng    *rand.Rand // 设置 Seed 时生成 tiebreak
+	stuck  skipSet    // 无法扩展的候选遇到的跳过原因，用于区分无候选的错误
+}
+
+func sortedTagKeys(tags map[string]*TagData) []string {
+	keys := make([]string, 0, len(tags))
+	for tag := range tags {
+		keys = append(keys, tag)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortCandidates 按分数降序排列，分数相同时按 tiebreak（设置 Seed 时）或 unit 序列字典序排列
+func sortCandidates(cans []*Candidate, seeded bool) {
+	sort.SliceStable(cans, func(i, j int) bool {
+		a, b := cans[i], cans[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if seeded && a.tiebreak != b.tiebreak {
+			return a.tiebreak < b.tiebreak
+		}
+		return lexicalLess(a, b)
+	})
+}
+
+// lexicalLess 逐位置比较 unit ID（相同则比较 tag），前缀较短者在前
+func lexicalLess(a, b *Candidate) bool {
+	for k := 0; k < len(a.Units) && k < len(b.Units); k++ {
+		ua, ub := a.Units[k], b.Units[k]
+		if ua.ID != ub.ID {
+			return ua.ID < ub.ID
+		}
+		if ua.Tag != ub.Tag {
+			return ua.Tag < ub.Tag
+		}
+	}
+	return len(a.Units) < len(b.Units)
+}
+
+func (s *BeamSearcher) genCans(step int, can *Candidate, st *genState) []*Candidate {
+	tags, limits := st.tags, st.limits
+	var (
+		beams   []*Candidate
+		skipped skipSet
+	)
+	for _, tagKey := range st.keys {
+		tagData := tags[tagKey]
+		skip := func(reason string) {
+			skipped |= skipBit(reason)
+			s.trace(TraceEvent{Step: step, Kind: TraceTagSkipped, Candidate: can, Tag: tagKey, Reason: reason})
+		}
+
 		count := can.Counts[tagKey]
-		if s.maxPerTag[tagKey] > 0 && count >= s.maxPerTag[tagKey] {
+		if limit, ok := limits[tagKey]; ok && count >= limit {
+			skip(SkipMaxPerTag)
+			continue
+		}
+
+		if s.forbiddenAfter(can, tagKey) {
+			skip(SkipForbiddenAdjacent)
+			continue
+		}
+
+		if s.tooClose(can, tagKey) {
+			skip(SkipMinTagGap)
 			continue
 		}
 
 		if !can.Win.Try([]string{tagKey}) {
+			skip(SkipWindow)
 			continue
 		}
 
 		units := tagData.Units
 		ref := can.Refs[tagKey]
 		if ref >= len(units) {
+			skip(SkipExhausted)
 			continue
 		}
 
 		for ref < len(units) {
 			unit := units[ref]
-			if _, ok := can.IDs[unit.ID]; ok {
+			if can.IDWin != nil {
+				// ID 窗口内已达上限，等待后续位置再放置
+				if !can.IDWin.Try([]string{unit.ID}) {
+					skip(SkipIDWindow)
+					break
+				}
+			} else if _, ok := can.IDs[unit.ID]; ok {
 				ref++
 				continue
 			}
 
 			newCan := can.Clone()
-			newCan.Units = append(newCan.Units, &Unit{ID: unit.ID, Tag: tagKey, Score: unit.Score})
+			newCan.Units = append(newCan.Units, &Unit{ID: unit.ID, Tag: tagKey, Score: unit.Score, Confidence: unit.Confidence})
 			newCan.Refs[tagKey] = ref + 1
 			newCan.Counts[tagKey] = count + 1
 			newCan.IDs[unit.ID] = struct{}{}
-			newCan.Score = s.calcScore(tags, newCan)
+			if s.batchScore {
+				newCan.Score = s.calcScore(tags, newCan)
+			} else {
+				newCan.Score = s.appendScore(tags, newCan)
+			}
 			newCan.Win.Add([]string{tagKey})
+			newCan.IDWin.Add([]string{unit.ID})
+			if st.rng != nil {
+				newCan.tiebreak = st.rng.Uint64()
+			}
+			if s.opts != nil && s.opts.TrackProvenance {
+				newCan.Origin = &Origin{Parent: can.Origin, Step: step, Tag: tagKey, UnitID: unit.ID, Score: newCan.Score}
+			}
 			beams = append(beams, newCan)
+			s.trace(TraceEvent{Step: step, Kind: TraceGenerated, Candidate: newCan, Tag: tagKey})
 			break
 		}
+		if ref >= len(units) {
+			// 剩余 unit 均与已选 ID 重复
+			skip(SkipExhausted)
+		}
 	}
 
+	if len(beams) == 0 {
+		st.stuck |= skipped
+	}
 	return beams
 }
 
+// forbiddenAfter 判断 tag 紧跟在候选末尾是否构成禁止相邻的 tag 对
+func (s *BeamSearcher) forbiddenAfter(can *Candidate, tag string) bool {
+	if s.opts == nil || len(s.opts.ForbiddenAdjacent) == 0 || len(can.Units) == 0 {
+		return false
+	}
+	prev := can.Units[len(can.Units)-1].Tag
+	for _, pair := range s.opts.ForbiddenAdjacent {
+		if (pair[0] == prev && pair[1] == tag) || (pair[0] == tag && pair[1] == prev) {
+			return true
+		}
+	}
+	return false
+}
+
+// tooClose 判断 tag 放在候选末尾时是否与其上一次出现的距离小于 MinTagGap
+func (s *BeamSearcher) tooClose(can *Candidate, tag string) bool {
+	if s.opts == nil {
+		return false
+	}
+	gap := s.opts.MinTagGap[tag]
+	if gap <= 1 {
+		return false
+	}
+	pos := len(can.Units)
+	for i := pos - 1; i >= 0 && pos-i < gap; i-- {
+		if can.Units[i].Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *BeamSearcher) calcScore(tags map[string]*TagData, can *Candidate) float64 {
 	seq := can.Units
 	if len(seq) == 0 {
 		return 0
 	}
 
-	// 1. 质量分（归一化处理）
-	quality := 0.0
-	for _, u := range seq {
-		quality += u.Score
+	// 1. 质量分（按 QualityAggregation 聚合，默认为平均质量分）
+	var qa scoreAcc
+	for i, u := range seq {
+		qa.addQuality(u.quality(), i)
 	}
-	quality /= float64(len(seq)) // 平均质量分
+	quality := s.aggregateQuality(&qa, len(seq))
 
 	// 2. 多样性分（考虑标签分布均匀性）
-	diversity := 0.0
-	if len(tags) > 1 {
-		tagCount := make(map[string]int)
+	tagCount := make(map[string]int)
+	for _, u := range seq {
+		tagCount[u.Tag]++
+	}
+	diversity := entropyDiversity(tagCount, len(seq), s.diversityBase(tags))
+
+	// 3. 连续性惩罚（指数增长）
+	penalty := 0.0
+	if s.opts == nil || !s.opts.DisableContinuityPenalty {
+		continuous := 1
+		for i := 1; i < len(seq); i++ {
+			if seq[i].Tag == seq[i-1].Tag {
+				continuous++
+				penalty += 0.1 * math.Pow(1.5, float64(continuous))
+			} else {
+				continuous = 1
+			}
+		}
+	}
+
+	// 稀缺 tag 奖励（每个 unit 奖励 1/(1+该 tag 在之前出现的次数)）
+	bonus := 0.0
+	if s.opts != nil && s.opts.RareTagBonus > 0 {
+		seen := make(map[string]int)
 		for _, u := range seq {
-			tagCount[u.Tag]++
+			bonus += 1 / float64(1+seen[u.Tag])
+			seen[u.Tag]++
 		}
-		// 计算熵值作为多样性度量
-		entropy := 0.0
-		total := float64(len(seq))
-		for _, count := range tagCount {
-			p := float64(count) / total
-			entropy -= p * math.Log(p)
+		bonus = s.opts.RareTagBonus * bonus / float64(len(seq))
+	}
+
+	// 4. 尾部连续惩罚（越靠后权重越大）
+	if s.opts != nil && s.opts.TailPenaltyWeight > 0 && len(seq) > 1 {
+		for i := 1; i < len(seq); i++ {
+			if seq[i].Tag == seq[i-1].Tag {
+				penalty += s.opts.TailPenaltyWeight * float64(i) / float64(len(seq)-1)
+			}
 		}
-		diversity = entropy / math.Log(float64(len(tags)))
 	}
 
-	// 3. 连续性惩罚（指数增长）
-	penalty := 0.0
-	continuous := 1
-	for i := 1; i < len(seq); i++ {
-		if seq[i].Tag == seq[i-1].Tag {
-			continuous++
-			penalty += 0.1 * math.Pow(1.5, float64(continuous))
-		} else {
-			continuous = 1
+	// 5. 目标分布惩罚（L1 距离）
+	if s.opts != nil && len(s.opts.TargetDistribution) > 0 {
+		weight := s.opts.TargetWeight
+		if weight <= 0 {
+			weight = 1
 		}
+		penalty += weight * targetDistance(tagCount, len(seq), s.opts.TargetDistribution)
 	}
 
-	return 0.5*quality + 0.5*diversity - penalty
+	return 0.5*quality + 0.5*diversity + bonus - penalty
+}
+
+// diversityBase 多样性归一化的 tag 数
+func (s *BeamSearcher) diversityBase(tags map[string]*TagData) int {
+	if s.opts == nil {
+		return len(tags)
+	}
+	if s.opts.DiversityBase > 0 {
+		return s.opts.DiversityBase
+	}
+	if !s.opts.DiversityNonEmptyTags {
+		return len(tags)
+	}
+	n := 0
+	for _, data := range tags {
+		if data != nil && len(data.Units) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// entropyDiversity 以 tag 分布的熵作为多样性度量，按 base 个 tag 的最大熵归一化
+func entropyDiversity(tagCount map[string]int, n int, base int) float64 {
+	if base <= 1 || n == 0 {
+		return 0
+	}
+	entropy := 0.0
+	total := float64(n)
+	for _, count := range tagCount {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / total
+		entropy -= p * math.Log(p)
+	}
+	return math.Min(entropy/math.Log(float64(base)), 1)
+}
+
+// targetDistance 计算序列 tag 分布与目标分布的 L1 距离，取值范围 [0, 2]
+func targetDistance(tagCount map[string]int, n int, target map[string]float64) float64 {
+	sum := 0.0
+	for _, w := range target {
+		if w > 0 {
+			sum += w
+		}
+	}
+	if sum == 0 || n == 0 {
+		return 0
+	}
+	total := float64(n)
+
+	dist := 0.0
+	for tag, w := range target {
+		if w < 0 {
+			w = 0
+		}
+		dist += math.Abs(float64(tagCount[tag])/total - w/sum)
+	}
+	for tag, count := range tagCount {
+		if _, ok := target[tag]; !ok {
+			dist += float64(count) / total
+		}
+	}
+	return dist
 }
@@ -0,0 +1,55 @@
+package generate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func benchmarkTags() map[string]*TagData {
+	spec := make(map[string][]float64)
+	for i := 0; i < 8; i++ {
+		scores := make([]float64, 40)
+		for j := range scores {
+			scores[j] = float64((i*7+j*3)%10) / 10
+		}
+		spec[fmt.Sprintf("tag%d", i)] = scores
+	}
+	return newTestTags(spec)
+}
+
+func BenchmarkGenerate_IncrementalScore(b *testing.B) {
+	tags := benchmarkTags()
+	bs := &BeamSearcher{seqCount: 5, seqLength: 100, beamWidth: 5, opts: &Options{TailPenaltyWeight: 0.1}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := bs.Generate(context.Background(), tags); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerate_BatchScore(b *testing.B) {
+	tags := benchmarkTags()
+	bs := &BeamSearcher{seqCount: 5, seqLength: 100, beamWidth: 5, opts: &Options{TailPenaltyWeight: 0.1}, batchScore: true}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := bs.Generate(context.Background(), tags); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerate_Dedup(b *testing.B) {
+	tags := benchmarkTags()
+	for _, dedup := range []bool{false, true} {
+		b.Run(fmt.Sprintf("dedup=%v", dedup), func(b *testing.B) {
+			bs := &BeamSearcher{seqCount: 5, seqLength: 30, beamWidth: 20, opts: &Options{DedupCandidates: dedup}}
+			for i := 0; i < b.N; i++ {
+				if _, err := bs.Generate(context.Background(), tags); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -2,9 +2,12 @@ package generate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"testing"
+	"time"
 )
 
 func TestGenCansBasic(t *testing.T) {
@@ -67,3 +70,988 @@ func TestGenCansBasic(t *testing.T) {
 		}
 	}
 }
+
+func newTestTags(spec map[string][]float64) map[string]*TagData {
+	tags := make(map[string]*TagData, len(spec))
+	for tag, scores := range spec {
+		data := &TagData{}
+		for i, score := range scores {
+			data.Units = append(data.Units, &Unit{ID: fmt.Sprintf("%s-%d", tag, i), Score: score})
+		}
+		tags[tag] = data
+	}
+	return tags
+}
+
+func TestTargetDistribution(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		"tag2": {1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+	})
+
+	base := &BeamSearcher{seqCount: 1, seqLength: 10, beamWidth: 5}
+	beams, err := base.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseCounts := beams[0].TagHistogram()
+
+	bs := &BeamSearcher{
+		seqCount:  1,
+		seqLength: 10,
+		beamWidth: 5,
+		opts: &Options{
+			TargetDistribution: map[string]float64{"tag1": 0.8, "tag2": 0.2},
+			TargetWeight:       5,
+		},
+	}
+	beams, err = bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := beams[0].TagHistogram()
+
+	t.Logf("baseline: %v, targeted: %v", baseCounts, counts)
+	if counts["tag1"] <= baseCounts["tag1"] {
+		t.Errorf("expected target distribution to increase tag1 share, got %d <= %d", counts["tag1"], baseCounts["tag1"])
+	}
+	if counts["tag1"] < 7 {
+		t.Errorf("expected roughly 80%% tag1, got %d/10", counts["tag1"])
+	}
+}
+
+func adjacentSameTag(can *Candidate) int {
+	n := 0
+	for i := 1; i < len(can.Units); i++ {
+		if can.Units[i].Tag == can.Units[i-1].Tag {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDisableContinuityPenalty(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 3, 3, 3, 3, 3},
+		"tag2": {1, 1, 1, 1, 1, 1},
+	})
+
+	on := &BeamSearcher{seqCount: 1, seqLength: 6, beamWidth: 5}
+	beams, err := on.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withPenalty := adjacentSameTag(beams[0])
+
+	off := &BeamSearcher{seqCount: 1, seqLength: 6, beamWidth: 5, opts: &Options{DisableContinuityPenalty: true}}
+	beams, err = off.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutPenalty := adjacentSameTag(beams[0])
+
+	t.Logf("adjacent same-tag pairs: with penalty %d, without %d", withPenalty, withoutPenalty)
+	if withoutPenalty <= withPenalty {
+		t.Errorf("expected more same-tag clustering without penalty, got %d <= %d", withoutPenalty, withPenalty)
+	}
+
+	// 关闭惩罚后，连续同 tag 序列的得分不应被扣减
+	seq := &Candidate{Units: []*Unit{{Tag: "tag1", Score: 1}, {Tag: "tag1", Score: 1}, {Tag: "tag1", Score: 1}}}
+	if got := off.calcScore(tags, seq); got != 0.5 {
+		t.Errorf("expected unpenalized score 0.5, got %v", got)
+	}
+}
+
+func TestTailPenalty(t *testing.T) {
+	tags := newTestTags(map[string][]float64{"tag1": {1}, "tag2": {1}, "tag3": {1}})
+	seq := func(tags ...string) *Candidate {
+		can := &Candidate{}
+		for _, tag := range tags {
+			can.Units = append(can.Units, &Unit{Tag: tag, Score: 1})
+		}
+		return can
+	}
+	cleanTail := seq("tag1", "tag1", "tag2", "tag3")
+	dirtyTail := seq("tag2", "tag3", "tag1", "tag1")
+
+	base := &BeamSearcher{}
+	if a, b := base.calcScore(tags, cleanTail), base.calcScore(tags, dirtyTail); a != b {
+		t.Fatalf("expected equal scores without tail penalty, got %v and %v", a, b)
+	}
+
+	bs := &BeamSearcher{opts: &Options{TailPenaltyWeight: 0.5}}
+	clean, dirty := bs.calcScore(tags, cleanTail), bs.calcScore(tags, dirtyTail)
+	if clean <= dirty {
+		t.Errorf("expected clean tail to rank higher, got %v <= %v", clean, dirty)
+	}
+}
+
+func TestMinSeqLength(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {1, 1, 1},
+		"tag2": {1},
+	})
+	newSearcher := func(opts *Options) *BeamSearcher {
+		return &BeamSearcher{seqCount: 10, seqLength: 5, beamWidth: 10, win: &Window{Size: 2, Limit: 1}, opts: opts}
+	}
+
+	beams, err := newSearcher(&Options{MinSeqLength: 1}).Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lengths := make(map[int]bool)
+	for _, can := range beams {
+		lengths[can.Len()] = true
+	}
+	if !lengths[2] || !lengths[3] {
+		t.Fatalf("expected candidates of length 2 and 3, got %v", lengths)
+	}
+
+	beams, err = newSearcher(&Options{MinSeqLength: 3}).Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, can := range beams {
+		if can.Len() < 3 {
+			t.Errorf("expected candidates shorter than 3 to be dropped, got length %d", can.Len())
+		}
+	}
+
+	if _, err := newSearcher(&Options{MinSeqLength: 4}).Generate(context.Background(), tags); err == nil {
+		t.Error("expected error when no candidate reaches MinSeqLength")
+	}
+}
+
+func TestPositionDiscount(t *testing.T) {
+	tags := newTestTags(map[string][]float64{"tag1": {1}, "tag2": {1}})
+	high, low := &Unit{ID: "h", Tag: "tag1", Score: 3}, &Unit{ID: "l", Tag: "tag2", Score: 1}
+	highFirst := &Candidate{Units: []*Unit{high, low}}
+	lowFirst := &Candidate{Units: []*Unit{low, high}}
+
+	base := &BeamSearcher{}
+	if a, b := base.calcScore(tags, highFirst), base.calcScore(tags, lowFirst); a != b {
+		t.Fatalf("expected order-independent scores without discount, got %v and %v", a, b)
+	}
+
+	bs := &BeamSearcher{opts: &Options{PositionDiscount: true}}
+	if a, b := bs.calcScore(tags, highFirst), bs.calcScore(tags, lowFirst); a <= b {
+		t.Errorf("expected high-score unit first to rank higher, got %v <= %v", a, b)
+	}
+}
+
+func TestIDWindow(t *testing.T) {
+	unit := func(id string) *Unit { return &Unit{ID: id, Score: 1} }
+	tags := map[string]*TagData{
+		"tag1": {Units: []*Unit{unit("x"), unit("y"), unit("x"), unit("y")}},
+		"tag2": {Units: []*Unit{unit("a"), unit("x"), unit("b"), unit("c")}},
+	}
+	bs := &BeamSearcher{
+		seqCount:  10,
+		seqLength: 6,
+		beamWidth: 10,
+		opts:      &Options{IDWindow: &Window{Size: 3, Limit: 1}, MinSeqLength: 1},
+	}
+	beams, err := bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repeated := false
+	for _, can := range beams {
+		last := make(map[string]int)
+		for pos, u := range can.Units {
+			if prev, ok := last[u.ID]; ok {
+				repeated = true
+				if pos-prev < 3 {
+					t.Errorf("id %s repeated at positions %d and %d, want spacing >= 3", u.ID, prev, pos)
+				}
+			}
+			last[u.ID] = pos
+		}
+	}
+	if !repeated {
+		t.Error("expected repeated ids to be placed with spacing")
+	}
+}
+
+func TestSeqCountExceedsBeamWidth(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 2, 1},
+		"tag2": {3, 2, 1},
+		"tag3": {3, 2, 1},
+		"tag4": {3, 2, 1},
+	})
+	bs := &BeamSearcher{seqCount: 10, seqLength: 3, beamWidth: 3}
+	beams, err := bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(beams) != 10 {
+		t.Fatalf("expected 10 results, got %d", len(beams))
+	}
+
+	seen := make(map[string]bool)
+	for i, can := range beams {
+		key := ""
+		for _, u := range can.Units {
+			key += u.ID + ","
+		}
+		if seen[key] {
+			t.Errorf("duplicate sequence %s", key)
+		}
+		seen[key] = true
+		if i > 0 && can.Score > beams[i-1].Score {
+			t.Errorf("results not sorted by score at %d", i)
+		}
+	}
+}
+
+func TestRareTagBonus(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 3, 3, 3, 3, 3},
+		"tag2": {1, 1, 1, 1, 1, 1},
+	})
+
+	base := &BeamSearcher{seqCount: 1, seqLength: 6, beamWidth: 5, opts: &Options{DisableContinuityPenalty: true}}
+	beams, err := base.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseRare := beams[0].TagHistogram()["tag2"]
+
+	bs := &BeamSearcher{seqCount: 1, seqLength: 6, beamWidth: 5, opts: &Options{DisableContinuityPenalty: true, RareTagBonus: 3}}
+	beams, err = bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rare := beams[0].TagHistogram()["tag2"]
+
+	t.Logf("rare tag units: baseline %d, with bonus %d", baseRare, rare)
+	if rare <= baseRare {
+		t.Errorf("expected bonus to increase rare tag representation, got %d <= %d", rare, baseRare)
+	}
+}
+
+func TestCandidateTagHistogram(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 2, 1},
+		"tag2": {3, 2},
+		"tag3": {1},
+	})
+	bs := &BeamSearcher{seqCount: 2, seqLength: 5, beamWidth: 3}
+	beams, err := bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, can := range beams {
+		hist := can.TagHistogram()
+		total := 0
+		for _, n := range hist {
+			total += n
+		}
+		if total != len(can.Units) {
+			t.Errorf("beam %d: histogram total %d, want %d", i, total, len(can.Units))
+		}
+		for tag, n := range hist {
+			got := 0
+			for _, u := range can.Units {
+				if u.Tag == tag {
+					got++
+				}
+			}
+			if got != n {
+				t.Errorf("beam %d: tag %s has %d units, histogram says %d", i, tag, got, n)
+			}
+		}
+	}
+}
+
+func TestSharedConstraint(t *testing.T) {
+	shared := NewSharedConstraint(map[string]int{"ad": 3})
+	newSearcher := func() *BeamSearcher {
+		return &BeamSearcher{seqCount: 2, seqLength: 5, beamWidth: 3, opts: &Options{Shared: shared}}
+	}
+
+	total := 0
+	for i, category := range []string{"news", "video"} {
+		tags := newTestTags(map[string][]float64{
+			"ad":     {10, 10, 10, 10, 10},
+			category: {1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		})
+		beams, err := newSearcher().Generate(context.Background(), tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, can := range beams {
+			if got := can.TagHistogram()["ad"] + total; got > 3 {
+				t.Errorf("search %d: candidate brings ads to %d, exceeding global cap 3", i, got)
+			}
+		}
+		total += beams[0].TagHistogram()["ad"]
+		t.Logf("search %d: ads=%d total=%d", i, beams[0].TagHistogram()["ad"], total)
+	}
+
+	if total == 0 || total > 3 {
+		t.Errorf("expected combined ads in (0, 3], got %d", total)
+	}
+	if left, ok := shared.Remaining("ad"); !ok || left != 3-total {
+		t.Errorf("expected remaining budget %d, got %d (limited=%v)", 3-total, left, ok)
+	}
+	if _, ok := shared.Remaining("news"); ok {
+		t.Error("tags outside the budget should be unlimited")
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var events []TraceEvent
+	bs := &BeamSearcher{
+		seqCount:  1,
+		seqLength: 3,
+		beamWidth: 1,
+		maxPerTag: map[string]int{"tag1": 1},
+		win:       &Window{Size: 2, Limit: 1},
+		opts: &Options{
+			Tracer: func(ev TraceEvent) { events = append(events, ev) },
+		},
+	}
+	tags := newTestTags(map[string][]float64{
+		"tag1":  {3, 3},
+		"tag2":  {2},
+		"tag3":  {1, 1},
+		"empty": {},
+	})
+	if _, err := bs.Generate(context.Background(), tags); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[TraceKind]int)
+	reasons := make(map[string]int)
+	for _, ev := range events {
+		kinds[ev.Kind]++
+		if ev.Kind == TraceTagSkipped {
+			reasons[ev.Reason]++
+		}
+		if ev.Step < 0 || ev.Step >= 3 {
+			t.Errorf("unexpected step %d", ev.Step)
+		}
+	}
+	t.Logf("kinds: %v, reasons: %v", kinds, reasons)
+
+	if kinds[TraceGenerated] == 0 {
+		t.Error("expected generated events")
+	}
+	if kinds[TracePruned] == 0 {
+		t.Error("expected beam width 1 to prune candidates")
+	}
+	for _, reason := range []string{SkipMaxPerTag, SkipWindow, SkipExhausted} {
+		if reasons[reason] == 0 {
+			t.Errorf("expected a tag skipped for %s", reason)
+		}
+	}
+}
+
+func TestDiversityNonEmptyTags(t *testing.T) {
+	spec := map[string][]float64{
+		"tag1": {1, 1, 1},
+		"tag2": {1, 1, 1},
+	}
+	for i := 0; i < 6; i++ {
+		spec[fmt.Sprintf("empty%d", i)] = nil
+	}
+	tags := newTestTags(spec)
+
+	// 两个 tag 交替出现，已是可达到的最大多样性
+	can := &Candidate{Units: []*Unit{{ID: "a", Tag: "tag1", Score: 1}, {ID: "b", Tag: "tag2", Score: 1}}}
+
+	base := &BeamSearcher{opts: &Options{DisableContinuityPenalty: true}}
+	deflated := base.calcScore(tags, can)
+
+	bs := &BeamSearcher{opts: &Options{DisableContinuityPenalty: true, DiversityNonEmptyTags: true}}
+	score := bs.calcScore(tags, can)
+	// 质量分 1、多样性 1
+	if math.Abs(score-1) > 1e-9 {
+		t.Errorf("expected full diversity score 1, got %.4f", score)
+	}
+	if score <= deflated {
+		t.Errorf("expected empty tags not to deflate diversity: %.4f <= %.4f", score, deflated)
+	}
+
+	fixed := &BeamSearcher{opts: &Options{DisableContinuityPenalty: true, DiversityBase: 2}}
+	if got := fixed.calcScore(tags, can); math.Abs(got-score) > 1e-9 {
+		t.Errorf("expected DiversityBase 2 to match non-empty count, got %.4f want %.4f", got, score)
+	}
+}
+
+func TestForbiddenAdjacent(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"ad":    {5, 5, 5, 5, 5},
+		"video": {5, 5, 5, 5, 5},
+		"feed":  {1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+	})
+	bs := &BeamSearcher{
+		seqCount:  5,
+		seqLength: 10,
+		beamWidth: 5,
+		opts: &Options{
+			DisableContinuityPenalty: true,
+			ForbiddenAdjacent:        [][2]string{{"ad", "ad"}, {"video", "ad"}},
+		},
+	}
+	beams, err := bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, can := range beams {
+		for j := 1; j < len(can.Units); j++ {
+			prev, cur := can.Units[j-1].Tag, can.Units[j].Tag
+			if (prev == "ad" && cur == "ad") || (prev == "ad" && cur == "video") || (prev == "video" && cur == "ad") {
+				t.Errorf("beam %d: forbidden pair %s,%s at position %d", i, prev, cur, j)
+			}
+		}
+		if can.TagHistogram()["ad"] == 0 {
+			t.Errorf("beam %d: expected ads to still be placed", i)
+		}
+	}
+}
+
+func TestEarlyTermination(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 2},
+		"tag2": {1},
+	})
+	var steps int
+	bs := &BeamSearcher{
+		seqCount:  3,
+		seqLength: 10,
+		beamWidth: 5,
+		opts: &Options{Tracer: func(ev TraceEvent) {
+			if ev.Step+1 > steps {
+				steps = ev.Step + 1
+			}
+		}},
+	}
+	beams, err := bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatalf("expected partial sequences instead of error, got %v", err)
+	}
+	if len(beams) == 0 {
+		t.Fatal("expected at least one candidate")
+	}
+	if beams[0].Len() != 3 {
+		t.Errorf("expected best candidate to use all 3 units, got %d", beams[0].Len())
+	}
+	// 第 4 步发现无法扩展后即结束，不再空转剩余步数
+	if steps != 4 {
+		t.Errorf("expected search to stop after step 4, ran %d steps", steps)
+	}
+
+	if _, err := bs.Generate(context.Background(), map[string]*TagData{}); err == nil {
+		t.Error("expected error when no unit can be placed at all")
+	}
+}
+
+func TestIncrementalScoreMatchesBatch(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 2.5, 2, 1.5, 1, 0.5},
+		"tag2": {2.8, 2.2, 1.6, 1.1},
+		"tag3": {1.9, 1.7, 1.2},
+		"tag4": {},
+	})
+	for name, opts := range map[string]*Options{
+		"default": nil,
+		"all": {
+			TargetDistribution:    map[string]float64{"tag1": 0.5, "tag2": 0.3},
+			TailPenaltyWeight:     0.3,
+			PositionDiscount:      true,
+			RareTagBonus:          0.2,
+			DiversityNonEmptyTags: true,
+		},
+		"no continuity": {DisableContinuityPenalty: true, DiversityBase: 5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var generated int
+			bs := &BeamSearcher{seqCount: 3, seqLength: 10, beamWidth: 4}
+			traced := &Options{}
+			if opts != nil {
+				*traced = *opts
+			}
+			traced.Tracer = func(ev TraceEvent) {
+				if ev.Kind != TraceGenerated {
+					return
+				}
+				generated++
+				if batch := bs.calcScore(tags, ev.Candidate); math.Abs(batch-ev.Candidate.Score) > 1e-9 {
+					t.Errorf("step %d: incremental score %.12f, batch %.12f", ev.Step, ev.Candidate.Score, batch)
+				}
+			}
+			bs.opts = traced
+			if _, err := bs.Generate(context.Background(), tags); err != nil {
+				t.Fatal(err)
+			}
+			if generated == 0 {
+				t.Fatal("expected generated candidates")
+			}
+		})
+	}
+}
+
+func TestSeedTieBreaking(t *testing.T) {
+	// 各 tag 完全对称，tag 的排列顺序不同而分数相同
+	tags := newTestTags(map[string][]float64{
+		"tag1": {1, 1, 1},
+		"tag2": {1, 1, 1},
+		"tag3": {1, 1, 1},
+		"tag4": {1, 1, 1},
+	})
+	run := func(seed int64) string {
+		bs := &BeamSearcher{seqCount: 3, seqLength: 4, beamWidth: 4, opts: &Options{Seed: seed}}
+		beams, err := bs.Generate(context.Background(), tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, can := range beams {
+			for _, u := range can.Units {
+				out = append(out, u.ID)
+			}
+			out = append(out, "|")
+		}
+		return fmt.Sprint(out)
+	}
+
+	// 默认按字典序，结果确定且最优序列从 tag1 开始
+	if a, b := run(0), run(0); a != b {
+		t.Errorf("default ordering is not deterministic:\n%s\n%s", a, b)
+	}
+	if got := run(0); got[:7] != "[tag1-0" {
+		t.Errorf("expected lexical tie-breaking to start with tag1-0, got %s", got)
+	}
+
+	if a, b := run(42), run(42); a != b {
+		t.Errorf("same seed produced different results:\n%s\n%s", a, b)
+	}
+	differs := false
+	for seed := int64(1); seed <= 10 && !differs; seed++ {
+		differs = run(seed) != run(42)
+	}
+	if !differs {
+		t.Error("expected different seeds to break ties differently")
+	}
+}
+
+func TestMinTagGap(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {9, 9, 9, 9, 9},
+		"tag2": {1, 1, 1, 1, 1},
+		"tag3": {1, 1, 1, 1, 1},
+	})
+	gaps := map[string]int{"tag1": 3, "tag2": 2}
+	bs := &BeamSearcher{
+		seqCount:  5,
+		seqLength: 9,
+		beamWidth: 8,
+		opts: &Options{
+			DisableContinuityPenalty: true,
+			MinTagGap:                gaps,
+		},
+	}
+	beams, err := bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, can := range beams {
+		last := make(map[string]int)
+		for pos, u := range can.Units {
+			if prev, ok := last[u.Tag]; ok && pos-prev < gaps[u.Tag] {
+				t.Errorf("beam %d: %s at positions %d and %d, want gap >= %d", i, u.Tag, prev, pos, gaps[u.Tag])
+			}
+			last[u.Tag] = pos
+		}
+		if can.TagHistogram()["tag1"] != 3 {
+			t.Errorf("beam %d: expected tag1 placed every 3 positions, got %v", i, can.TagHistogram())
+		}
+	}
+}
+
+func TestDedupCandidates(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 2.5, 2, 1.5},
+		"tag2": {2.8, 2.2, 1.6},
+		"tag3": {1.9, 1.7, 1.2},
+	})
+	run := func(dedup bool) []*Candidate {
+		bs := &BeamSearcher{seqCount: 100, seqLength: 4, beamWidth: 100, opts: &Options{DedupCandidates: dedup}}
+		beams, err := bs.Generate(context.Background(), tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return beams
+	}
+	plain, deduped := run(false), run(true)
+
+	if len(deduped) >= len(plain) {
+		t.Errorf("expected dedup to reduce candidates, got %d vs %d", len(deduped), len(plain))
+	}
+	seen := make(map[uint64]bool)
+	for _, can := range deduped {
+		key := dedupKey(can)
+		if seen[key] {
+			t.Errorf("duplicate candidate survived dedup: %v", can.Units)
+		}
+		seen[key] = true
+	}
+	if math.Abs(plain[0].Score-deduped[0].Score) > 1e-9 {
+		t.Errorf("top score changed: %.6f vs %.6f", plain[0].Score, deduped[0].Score)
+	}
+	for i := range plain[0].Units {
+		if plain[0].Units[i].ID != deduped[0].Units[i].ID {
+			t.Errorf("top sequence changed at %d: %s vs %s", i, plain[0].Units[i].ID, deduped[0].Units[i].ID)
+		}
+	}
+}
+
+func TestPostProcess(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 2, 1},
+		"tag2": {2.5, 1.5},
+	})
+	// 将第一个 tag2 的 unit 置顶，搜索时首位是分数更高的 tag1
+	pinFirst := func(can *Candidate) *Candidate {
+		out := can.Clone()
+		for i, u := range out.Units {
+			if u.Tag == "tag2" {
+				out.Units = append([]*Unit{u}, append(out.Units[:i:i], out.Units[i+1:]...)...)
+				break
+			}
+		}
+		return out
+	}
+	run := func(opts *Options) []*Candidate {
+		bs := &BeamSearcher{seqCount: 3, seqLength: 4, beamWidth: 4, opts: opts}
+		beams, err := bs.Generate(context.Background(), tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return beams
+	}
+
+	plain := run(&Options{})
+	processed := run(&Options{PostProcess: pinFirst})
+	if len(processed) != len(plain) {
+		t.Fatalf("expected %d candidates, got %d", len(plain), len(processed))
+	}
+	if plain[0].Units[0].Tag == "tag2" {
+		t.Fatal("test needs tag2 to not lead the top beam before post-processing")
+	}
+	for i, can := range processed {
+		if plain[i].TagHistogram()["tag2"] > 0 && can.Units[0].Tag != "tag2" {
+			t.Errorf("beam %d: expected a tag2 unit pinned first, got %s", i, can.Units[0].ID)
+		}
+		if can.Len() != plain[i].Len() {
+			t.Errorf("beam %d: expected length %d, got %d", i, plain[i].Len(), can.Len())
+		}
+		if can.Score != plain[i].Score {
+			t.Errorf("beam %d: expected score kept at %.6f, got %.6f", i, plain[i].Score, can.Score)
+		}
+	}
+
+	rescored := run(&Options{PostProcess: pinFirst, RescorePostProcessed: true})
+	bs := &BeamSearcher{}
+	for i, can := range rescored {
+		if want := bs.calcScore(tags, can); math.Abs(can.Score-want) > 1e-9 {
+			t.Errorf("beam %d: expected rescored %.6f, got %.6f", i, want, can.Score)
+		}
+	}
+}
+
+func TestTrackProvenance(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 2, 1},
+		"tag2": {2.5, 1.5, 0.5},
+	})
+	run := func(track bool) []*Candidate {
+		bs := &BeamSearcher{seqCount: 3, seqLength: 4, beamWidth: 3, opts: &Options{TrackProvenance: track}}
+		beams, err := bs.Generate(context.Background(), tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return beams
+	}
+
+	for _, can := range run(false) {
+		if can.Origin != nil {
+			t.Fatal("expected no provenance unless TrackProvenance is set")
+		}
+	}
+
+	bs := &BeamSearcher{}
+	for i, can := range run(true) {
+		chain := can.Provenance()
+		if len(chain) != can.Len() {
+			t.Fatalf("beam %d: expected %d provenance steps, got %d", i, can.Len(), len(chain))
+		}
+		// 逐步回放扩展记录，重建出相同的序列和每一步的分数
+		replay := &Candidate{}
+		for step, o := range chain {
+			if o.Step != step {
+				t.Errorf("beam %d: expected step %d, got %d", i, step, o.Step)
+			}
+			if step > 0 && o.Parent != chain[step-1] {
+				t.Errorf("beam %d: step %d does not link to its parent", i, step)
+			}
+			replay.Units = append(replay.Units, &Unit{ID: o.UnitID, Tag: o.Tag, Score: tagUnitScore(tags, o.Tag, o.UnitID)})
+			if want := bs.calcScore(tags, replay); math.Abs(o.Score-want) > 1e-9 {
+				t.Errorf("beam %d step %d: expected score %.6f, got %.6f", i, step, want, o.Score)
+			}
+		}
+		for pos, u := range can.Units {
+			if replay.Units[pos].ID != u.ID || replay.Units[pos].Tag != u.Tag {
+				t.Errorf("beam %d: position %d rebuilt as %s, want %s", i, pos, replay.Units[pos].ID, u.ID)
+			}
+		}
+		if chain[len(chain)-1].Score != can.Score {
+			t.Errorf("beam %d: last step score %.6f differs from candidate %.6f", i, chain[len(chain)-1].Score, can.Score)
+		}
+	}
+}
+
+func tagUnitScore(tags map[string]*TagData, tag, id string) float64 {
+	for _, u := range tags[tag].Units {
+		if u.ID == id {
+			return u.Score
+		}
+	}
+	return math.NaN()
+}
+
+func TestUnitConfidence(t *testing.T) {
+	tags := map[string]*TagData{
+		"tag1": {Units: []*Unit{{ID: "uncertain", Score: 3, Confidence: 0.2}}},
+		"tag2": {Units: []*Unit{{ID: "reliable", Score: 2, Confidence: 0.9}}},
+		"tag3": {Units: []*Unit{{ID: "unset", Score: 1.5}}},
+	}
+	bs := &BeamSearcher{seqCount: 3, seqLength: 1, beamWidth: 3}
+	beams, err := bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, can := range beams {
+		order = append(order, can.Units[0].ID)
+	}
+	// 质量分分别为 3*0.2、2*0.9、1.5*1
+	if want := []string{"reliable", "unset", "uncertain"}; fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("expected order %v, got %v", want, order)
+	}
+	if beams[0].Units[0].Confidence != 0.9 {
+		t.Errorf("expected confidence copied into the candidate, got %v", beams[0].Units[0].Confidence)
+	}
+
+	// 多步序列中增量打分与完整重算一致
+	bs = &BeamSearcher{seqCount: 2, seqLength: 3, beamWidth: 3, opts: &Options{PositionDiscount: true}}
+	beams, err = bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, can := range beams {
+		if batch := bs.calcScore(tags, can); math.Abs(batch-can.Score) > 1e-9 {
+			t.Errorf("beam %d: incremental score %.12f, batch %.12f", i, can.Score, batch)
+		}
+	}
+}
+
+func TestTimeBudget(t *testing.T) {
+	spec := make(map[string][]float64)
+	for i := 0; i < 20; i++ {
+		scores := make([]float64, 200)
+		for j := range scores {
+			scores[j] = float64((i*31+j*17)%100) / 100
+		}
+		spec[fmt.Sprintf("tag%d", i)] = scores
+	}
+	tags := newTestTags(spec)
+
+	bs := &BeamSearcher{seqCount: 5, seqLength: 50, beamWidth: 50, opts: &Options{TimeBudget: time.Nanosecond}}
+	beams, err := bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(beams) == 0 || len(beams) > 5 {
+		t.Fatalf("expected 1 to 5 candidates, got %d", len(beams))
+	}
+	for i, can := range beams {
+		if !can.EarlyStopped {
+			t.Errorf("beam %d: expected EarlyStopped to be set", i)
+		}
+		// 超时后至少完成第一步，返回的是有效的部分序列
+		if can.Len() == 0 || can.Len() >= 50 {
+			t.Errorf("beam %d: expected a partial sequence, got length %d", i, can.Len())
+		}
+		seen := make(map[string]bool)
+		for _, u := range can.Units {
+			if seen[u.ID] {
+				t.Errorf("beam %d: unit %s used more than once", i, u.ID)
+			}
+			seen[u.ID] = true
+		}
+		if want := bs.calcScore(tags, can); math.Abs(can.Score-want) > 1e-9 {
+			t.Errorf("beam %d: expected score %v, got %v", i, want, can.Score)
+		}
+	}
+	for i := 1; i < len(beams); i++ {
+		if beams[i].Score > beams[i-1].Score {
+			t.Errorf("results not sorted by score: %v > %v", beams[i].Score, beams[i-1].Score)
+		}
+	}
+
+	// 提前结束时不因 MinSeqLength 丢弃部分序列
+	bs = &BeamSearcher{seqCount: 5, seqLength: 50, beamWidth: 50, opts: &Options{TimeBudget: time.Nanosecond, MinSeqLength: 40}}
+	beams, err = bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatalf("expected partial candidates with MinSeqLength, got %v", err)
+	}
+	if len(beams) == 0 || !beams[0].EarlyStopped || beams[0].Len() >= 40 {
+		t.Fatalf("expected early-stopped candidates shorter than MinSeqLength, got %d candidates", len(beams))
+	}
+
+	// 预算充足时完整搜索
+	bs = &BeamSearcher{seqCount: 2, seqLength: 4, beamWidth: 4, opts: &Options{TimeBudget: time.Minute}}
+	beams, err = bs.Generate(context.Background(), newTestTags(map[string][]float64{"tag1": {3, 2, 1}, "tag2": {2, 1}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, can := range beams {
+		if can.EarlyStopped || can.Len() != 4 {
+			t.Errorf("expected full search within budget, got EarlyStopped=%v len=%d", can.EarlyStopped, can.Len())
+		}
+	}
+}
+
+func TestCandidateFlatten(t *testing.T) {
+	tags := newTestTags(map[string][]float64{
+		"tag1": {3, 2, 1},
+		"tag2": {2.5, 1.5},
+	})
+	bs := &BeamSearcher{seqCount: 1, seqLength: 4, beamWidth: 3}
+	beams, err := bs.Generate(context.Background(), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	best := beams[0]
+	items := best.Flatten()
+	if len(items) != best.Len() {
+		t.Fatalf("expected %d items, got %d", best.Len(), len(items))
+	}
+	for i, item := range items {
+		u := best.Units[i]
+		want := RankedItem{Position: i, UnitID: u.ID, Tag: u.Tag, Score: u.Score, CandidateScore: best.Score}
+		if item != want {
+			t.Errorf("position %d: expected %+v, got %+v", i, want, item)
+		}
+		if item.Tag == "" || item.UnitID == "" {
+			t.Errorf("position %d: expected tag and unit ID, got %+v", i, item)
+		}
+	}
+
+	if got := (&Candidate{}).Flatten(); len(got) != 0 {
+		t.Errorf("expected empty candidate to flatten to nothing, got %v", got)
+	}
+}
+
+func TestNoCandidatesErrors(t *testing.T) {
+	run := func(bs *BeamSearcher, tags map[string]*TagData) error {
+		_, err := bs.Generate(context.Background(), tags)
+		return err
+	}
+
+	// 所有 tag 都没有 unit
+	err := run(&BeamSearcher{seqCount: 1, seqLength: 3, beamWidth: 2}, newTestTags(map[string][]float64{"tag1": {}, "tag2": {}}))
+	if !errors.Is(err, ErrAllTagsEmpty) {
+		t.Errorf("expected ErrAllTagsEmpty for empty tags, got %v", err)
+	}
+	if !errors.Is(err, ErrNoCandidates) {
+		t.Errorf("expected specific cause to wrap ErrNoCandidates, got %v", err)
+	}
+
+	// 窗口内同一 tag 只允许出现一次，单个 tag 无法达到最小长度
+	tags := newTestTags(map[string][]float64{"tag1": {3, 2, 1}})
+	err = run(&BeamSearcher{seqCount: 1, seqLength: 3, beamWidth: 2, win: &Window{Size: 3, Limit: 1}, opts: &Options{MinSeqLength: 2}}, tags)
+	if !errors.Is(err, ErrWindowBlocked) {
+		t.Errorf("expected ErrWindowBlocked when the window blocks, got %v", err)
+	}
+
+	// unit 用完
+	err = run(&BeamSearcher{seqCount: 1, seqLength: 3, beamWidth: 2, opts: &Options{MinSeqLength: 3}}, newTestTags(map[string][]float64{"tag1": {3, 2}}))
+	if !errors.Is(err, ErrUnitsExhausted) {
+		t.Errorf("expected ErrUnitsExhausted when units run out, got %v", err)
+	}
+
+	// 共享预算已用完，第一步即无法扩展
+	shared := NewSharedConstraint(map[string]int{"tag1": 1})
+	bs := &BeamSearcher{seqCount: 1, seqLength: 1, beamWidth: 2, opts: &Options{Shared: shared}}
+	if err := run(bs, tags); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(bs, tags); !errors.Is(err, ErrUnitsExhausted) {
+		t.Errorf("expected ErrUnitsExhausted when the budget runs out, got %v", err)
+	}
+}
+
+func TestQualityAggregation(t *testing.T) {
+	// a 与 c、d 禁止相邻：含 a 的最优序列只能搭配低分的 b
+	tags := newTestTags(map[string][]float64{
+		"a": {1.0},
+		"b": {0.02},
+		"c": {0.4},
+		"d": {0.4},
+	})
+	best := func(agg QualityAggregation) string {
+		bs := &BeamSearcher{seqCount: 1, seqLength: 2, beamWidth: 10, opts: &Options{
+			QualityAggregation: agg,
+			ForbiddenAdjacent:  [][2]string{{"a", "c"}, {"a", "d"}},
+		}}
+		beams, err := bs.Generate(context.Background(), tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hist := beams[0].TagHistogram()
+		if hist["a"] == 1 && hist["b"] == 1 {
+			return "ab"
+		}
+		if hist["c"] == 1 && hist["d"] == 1 {
+			return "cd"
+		}
+		t.Fatalf("%s: unexpected result %v", agg, hist)
+		return ""
+	}
+
+	// 平均分 0.51 > 0.4，含弱项的序列胜出
+	if got := best(""); got != "ab" {
+		t.Errorf("mean: expected ab, got %s", got)
+	}
+	if got := best(QualitySum); got != "ab" {
+		t.Errorf("sum: expected ab, got %s", got)
+	}
+	// 最低分 0.02 < 0.4，含弱项的序列被淘汰
+	if got := best(QualityMin); got != "cd" {
+		t.Errorf("min: expected cd, got %s", got)
+	}
+	// 几何平均 sqrt(0.02) ≈ 0.14 < 0.4
+	if got := best(QualityGeoMean); got != "cd" {
+		t.Errorf("geomean: expected cd, got %s", got)
+	}
+
+	// 增量打分与完整重算一致
+	for _, agg := range []QualityAggregation{QualityMean, QualityMin, QualityGeoMean, QualitySum} {
+		bs := &BeamSearcher{seqCount: 3, seqLength: 3, beamWidth: 4, opts: &Options{QualityAggregation: agg}}
+		beams, err := bs.Generate(context.Background(), tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, can := range beams {
+			if want := bs.calcScore(tags, can); math.Abs(can.Score-want) > 1e-9 {
+				t.Errorf("%s: incremental score %v, batch %v", agg, can.Score, want)
+			}
+		}
+	}
+}
@@ -7,6 +7,7 @@ type CounterWindow struct {
 	limit      int
 	elems      [][]string
 	elemCounts map[string]int
+	members    []*CounterWindow // 非空时为 MergeWindows 合并的窗口，自身不计数
 }
 
 func NewCounterWindow(size, limit int) (*CounterWindow, error) {
@@ -20,11 +21,53 @@ func NewCounterWindow(size, limit int) (*CounterWindow, error) {
 	}, nil
 }
 
+// MergeWindows 合并多个窗口用于层级约束（如分区内与全局）。
+// 各窗口保留自己的 size 和 limit 并独立计数：Try/TryAll 仅在每个窗口都接受时返回 true，
+// 即以最严格的约束为准；Add/Adapt 推入全部窗口，各自按自身大小淘汰。
+// nil 窗口被忽略，全部为 nil 时返回 nil（接受任何插入）
+func MergeWindows(ws ...*CounterWindow) *CounterWindow {
+	var members []*CounterWindow
+	for _, w := range ws {
+		if w != nil {
+			members = append(members, w)
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return &CounterWindow{members: members}
+}
+
 // Try 尝试插入
 func (w *CounterWindow) Try(keys []string) bool {
 	if w == nil || keys == nil {
 		return true
 	}
+	if w.members != nil {
+		for _, m := range w.members {
+			if !m.Try(keys) {
+				return false
+			}
+		}
+		return true
+	}
+	return w.check(keys)
+}
+
+// TryAll 检查整组 keys 作为一个槽位插入后，每个 key 的计数是否都不超过上限。
+// 同一槽位中重复的 key 会累加计数，例如 limit=2 时窗口中已有一个 a，则 [a, a] 不可插入。
+func (w *CounterWindow) TryAll(keys []string) bool {
+	if w == nil || keys == nil {
+		return true
+	}
+	if w.members != nil {
+		for _, m := range w.members {
+			if !m.TryAll(keys) {
+				return false
+			}
+		}
+		return true
+	}
 	return w.check(keys)
 }
 
@@ -33,6 +76,12 @@ func (w *CounterWindow) Add(keys []string) {
 	if w == nil {
 		return
 	}
+	if w.members != nil {
+		for _, m := range w.members {
+			m.Add(keys)
+		}
+		return
+	}
 	if keys == nil {
 		w.push(nil)
 		return
@@ -45,16 +94,54 @@ func (w *CounterWindow) Adapt(keys []string) {
 	if w == nil {
 		return
 	}
+	if w.members != nil {
+		for _, m := range w.members {
+			m.Adapt(keys)
+		}
+		return
+	}
 	if keys == nil {
 		w.push(nil)
 		return
 	}
-	for !w.check(keys) {
+	for !w.check(keys) && len(w.elems) > 0 {
 		w.pop()
 	}
 	w.push(keys)
 }
 
+// WouldEvict 预估 Adapt(keys) 会弹出的槽位数（含窗口满时的溢出弹出），不修改窗口；
+// 合并窗口返回各窗口中的最大值
+func (w *CounterWindow) WouldEvict(keys []string) int {
+	if w == nil {
+		return 0
+	}
+	if w.members != nil {
+		n := 0
+		for _, m := range w.members {
+			n = max(n, m.WouldEvict(keys))
+		}
+		return n
+	}
+	n := 0
+	if keys != nil {
+		counts := make(map[string]int, len(w.elemCounts))
+		for k, v := range w.elemCounts {
+			counts[k] = v
+		}
+		for n < len(w.elems) && !fits(counts, keys, w.limit) {
+			for _, k := range w.elems[n] {
+				counts[k]--
+			}
+			n++
+		}
+	}
+	if len(w.elems)-n+1 > w.size-1 {
+		n++
+	}
+	return n
+}
+
 func (w *CounterWindow) pop() {
 	if len(w.elems) == 0 {
 		return
@@ -88,20 +175,22 @@ func (w *CounterWindow) push(ks []string) {
 }
 
 func (w *CounterWindow) check(ks []string) bool {
-	accept := true
-	for _, k := range ks {
-		if !w.checkThreshold(k) {
-			accept = false
-			break
-		}
-	}
-	return accept
+	return fits(w.elemCounts, ks, w.limit)
 }
 
-func (w *CounterWindow) checkThreshold(e string) bool {
-	cnt, ok := w.elemCounts[e]
-	if ok && cnt+1 > w.limit {
-		return false
+// fits 判断 ks 作为一个槽位加入 counts 后各 key 计数是否都不超过 limit
+func fits(counts map[string]int, ks []string, limit int) bool {
+	if len(ks) == 1 {
+		return counts[ks[0]]+1 <= limit
+	}
+	adds := make(map[string]int, len(ks))
+	for _, k := range ks {
+		adds[k]++
+	}
+	for k, n := range adds {
+		if counts[k]+n > limit {
+			return false
+		}
 	}
 	return true
 }
@@ -110,6 +199,13 @@ func (w *CounterWindow) Clone() *CounterWindow {
 	if w == nil {
 		return nil
 	}
+	if w.members != nil {
+		members := make([]*CounterWindow, len(w.members))
+		for i, m := range w.members {
+			members[i] = m.Clone()
+		}
+		return &CounterWindow{members: members}
+	}
 	elems := make([][]string, len(w.elems))
 	for i, arr := range w.elems {
 		if arr == nil {
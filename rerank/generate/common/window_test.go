@@ -0,0 +1,134 @@
+package common
+
+import "testing"
+
+func TestCounterWindow_TryAll(t *testing.T) {
+	w, err := NewCounterWindow(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.TryAll([]string{"a", "a"}) {
+		t.Error("expected [a a] to fit in an empty window with limit 2")
+	}
+	if w.TryAll([]string{"a", "a", "a"}) {
+		t.Error("expected [a a a] to exceed limit 2 on its own")
+	}
+
+	w.Add([]string{"a"})
+	if w.TryAll([]string{"a", "a"}) {
+		t.Error("expected repeated keys in one slot to count together")
+	}
+	if !w.TryAll([]string{"a", "b"}) {
+		t.Error("expected [a b] to fit with one a in the window")
+	}
+
+	w.Add([]string{"b", "b"})
+	if w.TryAll([]string{"a", "b"}) {
+		t.Error("expected [a b] to be rejected once b is at its limit")
+	}
+	if !w.TryAll([]string{"a", "c"}) {
+		t.Error("expected [a c] to fit")
+	}
+	if !w.TryAll(nil) {
+		t.Error("expected nil keys to always fit")
+	}
+
+	var nilWin *CounterWindow
+	if !nilWin.TryAll([]string{"a"}) {
+		t.Error("expected nil window to accept everything")
+	}
+}
+
+func TestCounterWindow_AdaptOversizedSlot(t *testing.T) {
+	w, err := NewCounterWindow(3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Add([]string{"a"})
+	// 单个槽位自身超过上限时，清空窗口后仍强制插入而不是死循环
+	w.Adapt([]string{"b", "b"})
+	if len(w.elems) != 1 || w.elemCounts["a"] != 0 || w.elemCounts["b"] != 2 {
+		t.Errorf("unexpected window state: elems=%v counts=%v", w.elems, w.elemCounts)
+	}
+}
+
+func TestCounterWindow_WouldEvict(t *testing.T) {
+	slots := [][]string{{"a"}, {"b"}, {"a", "c"}, {"b"}, {"c", "c"}, nil, {"a"}}
+	probes := [][]string{{"a"}, {"b"}, {"c"}, {"a", "a"}, {"b", "c"}, {"d"}, {"c", "c", "c"}, nil}
+
+	for _, probe := range probes {
+		w, err := NewCounterWindow(5, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, slot := range slots {
+			before := len(w.elems)
+			want := w.WouldEvict(probe)
+
+			preview := w.Clone()
+			preview.Adapt(probe)
+			if got := before + 1 - len(preview.elems); got != want {
+				t.Errorf("after %d slots, probe %v: WouldEvict=%d, Adapt evicted %d", i, probe, want, got)
+			}
+			if len(w.elems) != before {
+				t.Fatalf("WouldEvict mutated the window")
+			}
+			w.Adapt(slot)
+		}
+	}
+
+	var nilWin *CounterWindow
+	if n := nilWin.WouldEvict([]string{"a"}); n != 0 {
+		t.Errorf("expected nil window to evict nothing, got %d", n)
+	}
+}
+
+func TestMergeWindows(t *testing.T) {
+	section, err := NewCounterWindow(3, 2) // 分区内：最近 2 个槽位中同一 key 至多 2 次
+	if err != nil {
+		t.Fatal(err)
+	}
+	global, err := NewCounterWindow(5, 1) // 全局：最近 4 个槽位中同一 key 至多 1 次
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := MergeWindows(section, nil, global)
+
+	if !merged.Try([]string{"a"}) {
+		t.Error("expected a to fit in empty windows")
+	}
+	merged.Add([]string{"a"})
+	if !section.Try([]string{"a"}) {
+		t.Error("expected the section window alone to accept a second a")
+	}
+	if merged.Try([]string{"a"}) {
+		t.Error("expected the merged window to follow the stricter global limit")
+	}
+	if merged.TryAll([]string{"b", "b"}) {
+		t.Error("expected [b b] to exceed the global limit")
+	}
+
+	// 分区窗口已滑出 a，全局窗口仍记得
+	merged.Add([]string{"b"})
+	merged.Add([]string{"c"})
+	if section.elemCounts["a"] != 0 || global.elemCounts["a"] != 1 {
+		t.Errorf("expected windows to evict by their own size: section=%v global=%v", section.elemCounts, global.elemCounts)
+	}
+	if merged.Try([]string{"a"}) {
+		t.Error("expected a to stay rejected while the global window holds it")
+	}
+	if got := merged.WouldEvict([]string{"a"}); got != global.WouldEvict([]string{"a"}) {
+		t.Errorf("expected WouldEvict to report the largest member eviction, got %d", got)
+	}
+
+	clone := merged.Clone()
+	clone.Add([]string{"d"})
+	if global.elemCounts["d"] != 0 {
+		t.Error("expected Clone to copy member windows")
+	}
+
+	if MergeWindows(nil, nil) != nil {
+		t.Error("expected merging only nil windows to give a nil window")
+	}
+}
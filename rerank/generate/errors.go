@@ -0,0 +1,59 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoCandidates 没有可返回的候选，下列具体原因均包装该错误
+var ErrNoCandidates = errors.New("no candidates")
+
+var (
+	// ErrAllTagsEmpty 所有 tag 都没有 unit
+	ErrAllTagsEmpty = fmt.Errorf("%w: all tags empty", ErrNoCandidates)
+	// ErrWindowBlocked 候选因 tag 或 unit ID 滑动窗口已满无法继续扩展，可放宽窗口
+	ErrWindowBlocked = fmt.Errorf("%w: blocked by window", ErrNoCandidates)
+	// ErrUnitsExhausted 候选因 unit 已用完或 tag 达到使用上限无法继续扩展，可补充 unit
+	ErrUnitsExhausted = fmt.Errorf("%w: units exhausted", ErrNoCandidates)
+)
+
+// skipSet 记录无法扩展的候选遇到的跳过原因
+type skipSet uint8
+
+const (
+	skipWindowed skipSet = 1 << iota
+	skipExhausted
+	skipOther
+)
+
+func skipBit(reason string) skipSet {
+	switch reason {
+	case SkipWindow, SkipIDWindow:
+		return skipWindowed
+	case SkipExhausted, SkipMaxPerTag:
+		return skipExhausted
+	default:
+		return skipOther
+	}
+}
+
+// err 按原因返回错误，窗口优先于 unit 用尽：放宽窗口可能让候选继续扩展
+func (s skipSet) err() error {
+	switch {
+	case s&skipWindowed != 0:
+		return ErrWindowBlocked
+	case s&skipExhausted != 0:
+		return ErrUnitsExhausted
+	default:
+		return ErrNoCandidates
+	}
+}
+
+func allTagsEmpty(tags map[string]*TagData) bool {
+	for _, data := range tags {
+		if data != nil && len(data.Units) > 0 {
+			return false
+		}
+	}
+	return true
+}
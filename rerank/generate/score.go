@@ -0,0 +1,123 @@
+package generate
+
+import "math"
+
+// QualityAggregation 各位置质量分的聚合方式
+type QualityAggregation string
+
+const (
+	QualityMean    QualityAggregation = "mean"    // 算术平均
+	QualityMin     QualityAggregation = "min"     // 最小值，序列中不能有明显的弱项
+	QualityGeoMean QualityAggregation = "geomean" // 几何平均，对低分离群值的惩罚强于算术平均，存在非正分数时为 0
+	QualitySum     QualityAggregation = "sum"     // 求和，不按长度归一化
+)
+
+// scoreAcc 候选序列打分的累计量，追加 unit 时 O(1) 更新，
+// 多样性和目标分布基于 Candidate.Counts 计算，与 tag 数相关而与序列长度无关
+type scoreAcc struct {
+	qualitySum  float64 // 质量分之和
+	weightedSum float64 // 位置折损后的质量分之和
+	weightNorm  float64 // 位置折损权重之和
+	run         int     // 末尾同 tag 连续长度
+	continuity  float64 // 连续性惩罚
+	tailPosSum  float64 // 与前一个 unit 同 tag 的位置之和，用于尾部惩罚
+	rareSum     float64 // 稀缺 tag 奖励之和
+	minQuality  float64 // 最低质量分
+	logSum      float64 // 正质量分的对数之和，用于几何平均
+	nonPositive int     // 非正质量分的个数
+}
+
+// addQuality 计入位置 pos（从 0 开始）上的质量分
+func (acc *scoreAcc) addQuality(q float64, pos int) {
+	w := 1 / math.Log2(float64(pos+2))
+	acc.qualitySum += q
+	acc.weightedSum += w * q
+	acc.weightNorm += w
+	if pos == 0 || q < acc.minQuality {
+		acc.minQuality = q
+	}
+	if q > 0 {
+		acc.logSum += math.Log(q)
+	} else {
+		acc.nonPositive++
+	}
+}
+
+// aggregateQuality 按 QualityAggregation 聚合 n 个 unit 的质量分，PositionDiscount 仅作用于算术平均
+func (s *BeamSearcher) aggregateQuality(acc *scoreAcc, n int) float64 {
+	var agg QualityAggregation
+	if s.opts != nil {
+		agg = s.opts.QualityAggregation
+	}
+	switch agg {
+	case QualityMin:
+		return acc.minQuality
+	case QualityGeoMean:
+		if acc.nonPositive > 0 {
+			return 0
+		}
+		return math.Exp(acc.logSum / float64(n))
+	case QualitySum:
+		return acc.qualitySum
+	default:
+		if s.opts != nil && s.opts.PositionDiscount {
+			return acc.weightedSum / acc.weightNorm
+		}
+		return acc.qualitySum / float64(n)
+	}
+}
+
+// appendScore 在 can 末尾追加 unit 且 Counts 已更新后，增量更新累计量并返回新分数，
+// 结果与 calcScore 一致（浮点误差内）
+func (s *BeamSearcher) appendScore(tags map[string]*TagData, can *Candidate) float64 {
+	n := len(can.Units)
+	if n == 0 {
+		return 0
+	}
+	u := can.Units[n-1]
+	acc := &can.acc
+
+	acc.addQuality(u.quality(), n-1)
+
+	if n > 1 && can.Units[n-2].Tag == u.Tag {
+		acc.run++
+		acc.continuity += 0.1 * math.Pow(1.5, float64(acc.run))
+		acc.tailPosSum += float64(n - 1)
+	} else {
+		acc.run = 1
+	}
+	acc.rareSum += 1 / float64(can.Counts[u.Tag])
+
+	// 1. 质量分
+	quality := s.aggregateQuality(acc, n)
+
+	// 2. 多样性分
+	diversity := entropyDiversity(can.Counts, n, s.diversityBase(tags))
+
+	// 3. 连续性惩罚
+	penalty := 0.0
+	if s.opts == nil || !s.opts.DisableContinuityPenalty {
+		penalty = acc.continuity
+	}
+
+	bonus := 0.0
+	if s.opts != nil && s.opts.RareTagBonus > 0 {
+		bonus = s.opts.RareTagBonus * acc.rareSum / float64(n)
+	}
+
+	// 4. 尾部连续惩罚
+	if s.opts != nil && s.opts.TailPenaltyWeight > 0 && n > 1 {
+		penalty += s.opts.TailPenaltyWeight * acc.tailPosSum / float64(n-1)
+	}
+
+	// 5. 目标分布惩罚
+	if s.opts != nil && len(s.opts.TargetDistribution) > 0 {
+		weight := s.opts.TargetWeight
+		if weight <= 0 {
+			weight = 1
+		}
+		penalty += weight * targetDistance(can.Counts, n, s.opts.TargetDistribution)
+	}
+
+	return 0.5*quality + 0.5*diversity + bonus - penalty
+}
@@ -0,0 +1,65 @@
+package generate
+
+import "sync"
+
+// SharedConstraint 跨多次 Generate 共享的 tag 使用预算，用于多路搜索的全局约束
+// （如各品类分别搜索，但广告总数不超过 3）
+// 每次 Generate 返回的候选均不超出剩余预算，结束后从预算中扣除得分最高候选的使用量，
+// 共享同一约束的搜索应顺序执行
+type SharedConstraint struct {
+	mtx    sync.Mutex
+	budget map[string]int // 每个 tag 的全局上限
+	used   map[string]int // 已提交的使用量
+}
+
+// NewSharedConstraint 创建共享预算，未出现在 budget 中的 tag 不受限制
+func NewSharedConstraint(budget map[string]int) *SharedConstraint {
+	b := make(map[string]int, len(budget))
+	for tag, n := range budget {
+		b[tag] = n
+	}
+	return &SharedConstraint{
+		budget: b,
+		used:   make(map[string]int),
+	}
+}
+
+// Remaining 返回 tag 的剩余预算，tag 不受限制时返回 false
+func (c *SharedConstraint) Remaining(tag string) (int, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.remaining(tag)
+}
+
+func (c *SharedConstraint) remaining(tag string) (int, bool) {
+	limit, ok := c.budget[tag]
+	if !ok {
+		return 0, false
+	}
+	if left := limit - c.used[tag]; left > 0 {
+		return left, true
+	}
+	return 0, true
+}
+
+// limits 返回所有受限 tag 的剩余预算快照
+func (c *SharedConstraint) limits() map[string]int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	limits := make(map[string]int, len(c.budget))
+	for tag := range c.budget {
+		limits[tag], _ = c.remaining(tag)
+	}
+	return limits
+}
+
+// Commit 将候选中受限 tag 的使用量计入预算
+func (c *SharedConstraint) Commit(can *Candidate) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, u := range can.Units {
+		if _, ok := c.budget[u.Tag]; ok {
+			c.used[u.Tag]++
+		}
+	}
+}
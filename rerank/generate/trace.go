@@ -0,0 +1,42 @@
+package generate
+
+// TraceKind 追踪事件类型
+type TraceKind string
+
+const (
+	TraceGenerated  TraceKind = "generated"   // 扩展出新候选
+	TracePruned     TraceKind = "pruned"      // 超出 beamWidth 被裁剪
+	TraceTagSkipped TraceKind = "tag_skipped" // tag 因约束无法扩展
+)
+
+// tag 被跳过的原因
+const (
+	SkipMaxPerTag         = "max_per_tag"        // 达到 maxPerTag 或共享预算上限
+	SkipWindow            = "window"             // tag 滑动窗口已满
+	SkipForbiddenAdjacent = "forbidden_adjacent" // 与上一个 unit 的 tag 禁止相邻
+	SkipMinTagGap         = "min_tag_gap"        // 与同 tag 上一个 unit 的距离小于 MinTagGap
+	SkipIDWindow          = "id_window"          // 下一个 unit 的 ID 窗口已满
+	SkipExhausted         = "exhausted"          // tag 下没有可用的 unit
+)
+
+// TraceEvent Generate 过程中的一条诊断记录
+type TraceEvent struct {
+	Step      int // 从 0 开始的扩展步数
+	Kind      TraceKind
+	Candidate *Candidate // generated/pruned 时为对应候选，tag_skipped 时为被扩展的候选
+	Tag       string     // generated/tag_skipped 时为对应 tag
+	Reason    string     // tag_skipped 时为 Skip* 原因
+}
+
+// Tracer 接收追踪事件，在 Generate 所在的协程中同步调用
+type Tracer func(ev TraceEvent)
+
+func (s *BeamSearcher) trace(ev TraceEvent) {
+	if s.opts != nil && s.opts.Tracer != nil {
+		s.opts.Tracer(ev)
+	}
+}
+
+func (s *BeamSearcher) tracing() bool {
+	return s.opts != nil && s.opts.Tracer != nil
+}